@@ -0,0 +1,36 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff 计算重连前的等待时间。
+// 每次重连失败后，等待时间的上限从 initial 起翻倍，直到 max；实际等待时间在上限的一半到上限之间随机，
+// 以免服务端重启后大量客户端同时重连。
+type Backoff struct {
+	initial time.Duration
+	max     time.Duration
+	attempt int
+}
+
+func NewBackoff(initial time.Duration, max time.Duration) *Backoff {
+	return &Backoff{initial: initial, max: max}
+}
+
+// Next 返回下一次重连前的等待时间。
+func (b *Backoff) Next() time.Duration {
+	d := b.initial << b.attempt
+	if d <= 0 || d >= b.max {
+		d = b.max
+	} else {
+		b.attempt++
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// Reset 在连接成功后重置等待时间。
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
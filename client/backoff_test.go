@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBackoff 测试等待时间的上限从初始值起翻倍直到最大值，实际等待时间在上限的一半到上限之间，重置后从初始值重新开始。
+func TestBackoff(t *testing.T) {
+	b := NewBackoff(time.Second, 8*time.Second)
+	for round := 0; round < 2; round++ {
+		for _, limit := range []time.Duration{
+			time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second, 8 * time.Second,
+		} {
+			d := b.Next()
+			assert.GreaterOrEqual(t, d, limit/2)
+			assert.LessOrEqual(t, d, limit)
+		}
+		b.Reset()
+	}
+
+	// 多次失败后等待时间不超过最大值，移位溢出时也是如此。
+	for i := 0; i < 100; i++ {
+		d := b.Next()
+		assert.GreaterOrEqual(t, d, 500*time.Millisecond)
+		assert.LessOrEqual(t, d, 8*time.Second)
+	}
+	b = NewBackoff(time.Second, time.Duration(1<<62))
+	for i := 0; i < 100; i++ {
+		assert.Greater(t, b.Next(), time.Duration(0))
+	}
+}
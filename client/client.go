@@ -2,8 +2,11 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"crypto/md5"
+	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -11,6 +14,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/vistart/project20240227/server/common"
@@ -26,17 +30,29 @@ type ClientInterface interface {
 }
 
 type Client struct {
-	id         string
-	clientType int
-	PowerMode  *PowerMode
+	id            string
+	clientType    int
+	secret        string
+	lastEventID   string       // 最后收到的事件ID。重连时提交给服务端。
+	reportQueue   *ReportQueue // 未能报告的功率。
+	reportRetryAt time.Time    // 补报失败后，在此之前不再尝试。
+	commands      *CommandHistory
+	idleTimeout   time.Duration // 未收到服务端任何事件的最长时间。
+	Appliance     *Appliance
 	ClientInterface
 }
 
-func NewClient(id string, clientType int, powerFactor int) *Client {
+// NewClient 实例化客户端。appliance 为模拟的设备，reportQueue 用于保存未能报告的功率。
+// 连接后超过 idleTimeout 未收到服务端的任何事件时，视为连接中断。
+func NewClient(id string, clientType int, secret string, appliance *Appliance, reportQueue *ReportQueue, idleTimeout time.Duration) *Client {
 	return &Client{
-		id:         id,
-		clientType: clientType,
-		PowerMode:  NewPowerMode(powerFactor),
+		id:          id,
+		clientType:  clientType,
+		secret:      secret,
+		reportQueue: reportQueue,
+		commands:    NewCommandHistory(commandHistorySize),
+		idleTimeout: idleTimeout,
+		Appliance:   appliance,
 	}
 }
 
@@ -47,23 +63,46 @@ func (c *Client) ID() string {
 func (c *Client) Type() int {
 	return c.clientType
 }
-func (c *Client) Register(exitFunc func()) {
+
+// ErrDisconnected 表示服务端要求客户端断开连接，此时不应重连。
+var ErrDisconnected = errors.New("disconnected by server")
+
+// ErrIdleTimeout 表示超过 idleTimeout 未收到服务端的任何事件。服务端定期广播时间戳，
+// 长时间没有数据说明连接已中断（例如对端已消失的半开连接），此时应重连。
+var ErrIdleTimeout = errors.New("no event received from server")
+
+// Register 向服务端注册，并持续接收服务端发来的事件，直到连接断开。
+// 重连时携带最后收到的事件ID，服务端据此重放断开期间错过的命令。服务端发送 disconnect 事件时返回 ErrDisconnected。
+// 注册成功后调用 onConnected。超过 idleTimeout 未收到任何数据时中断连接，返回 ErrIdleTimeout。
+func (c *Client) Register(onConnected func()) error {
 	client := &http.Client{}
 
-	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/client/register", apiSocket), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s/client/register", apiSocket), nil)
 	if err != nil {
-		log.Println(err)
-		return
+		return err
 	}
+	// 每收到一行数据重置计时，超时后取消请求，使阻塞的读取返回。
+	var idle atomic.Bool
+	timer := time.AfterFunc(c.idleTimeout, func() {
+		idle.Store(true)
+		cancel()
+	})
+	defer timer.Stop()
 
-	c.SetHeader(req)
+	c.SetHeader(req, nil)
+	if len(c.lastEventID) > 0 {
+		req.Header.Set(common.RequestLastEventID, c.lastEventID)
+	}
 
 	// 发送请求
 	resp, err := client.Do(req)
+	if idle.Load() {
+		return ErrIdleTimeout
+	}
 	if err != nil {
-		// 处理错误
-		log.Println(err)
-		return
+		return err
 	}
 
 	// 读取响应正文
@@ -75,11 +114,15 @@ func (c *Client) Register(exitFunc func()) {
 		}
 	}(body)
 
-	defer exitFunc()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("register failed: %s", resp.Status)
+	}
+	onConnected()
 
 	scanner := bufio.NewScanner(resp.Body)
-	var event, data string
+	var event, data, id string
 	for scanner.Scan() {
+		timer.Reset(c.idleTimeout)
 		line := scanner.Text()
 		if strings.HasPrefix(line, "event:") {
 			// 提取事件类型并去掉 "event:" 前缀
@@ -87,58 +130,221 @@ func (c *Client) Register(exitFunc func()) {
 		} else if strings.HasPrefix(line, "data:") {
 			// 提取数据行并去掉 "data:" 前缀
 			data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		} else if strings.HasPrefix(line, "id:") {
+			// 提取事件ID并去掉 "id:" 前缀
+			id = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
 		} else if line == "" {
 			// 空行表示事件结束，进行下一步处理
+			if len(id) > 0 {
+				c.lastEventID = id
+			}
 			if event != "" || data != "" {
 				//fmt.Println("Event:", event)
 				//fmt.Println("Data:", data)
 				e := c.ProcessEvent(event, data)
-				fmt.Println(event, e.MarshalData())
+				if e != nil {
+					fmt.Println(event, e.MarshalData())
+				}
 				if _, ok := e.(*common.EventDisconnect); ok {
-					return
+					return ErrDisconnected
 				}
 			}
 			// 重置事件和数据，准备接收下一个事件
-			event, data = "", ""
+			event, data, id = "", "", ""
 		}
 	}
 
+	if idle.Load() {
+		return ErrIdleTimeout
+	}
 	if err := scanner.Err(); err != nil {
-		fmt.Println("Error reading SSE:", err)
+		return err
 	}
+	return io.EOF
 }
 
-func (c *Client) Report() {
-	client := &http.Client{}
+// reportTimeout 表示报告功率的请求超时时间。服务端不可达时，避免阻塞之后的报告。
+const reportTimeout = 5 * time.Second
 
-	postData := url.Values{}
-	postData.Set("consumption", strconv.Itoa(c.PowerMode.GetConsumption()))
-	postData.Set("recorded_at", fmt.Sprintf("%d", time.Now().Unix()))
+// reportBatchSize 表示一次补报的最多记录数，不超过服务端的限制。
+const reportBatchSize = 500
+
+// reportRetryInterval 表示补报失败后再次尝试前的等待时间。
+const reportRetryInterval = 10 * time.Second
+
+// ErrRequestRejected 表示服务端以 4xx 状态拒绝了请求。请求本身不合法，原样重试不会成功。
+type ErrRequestRejected struct {
+	Path   string
+	Status string
+}
+
+func (e ErrRequestRejected) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Status)
+}
+
+// postForm 向服务端提交签名的表单请求。响应状态不是 200 时返回错误，为 4xx 时返回 ErrRequestRejected。
+func (c *Client) postForm(path string, postData url.Values) error {
+	client := &http.Client{Timeout: reportTimeout}
 
-	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/client/report", apiSocket), strings.NewReader(postData.Encode()))
+	body := postData.Encode()
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s%s", apiSocket, path), strings.NewReader(body))
 	if err != nil {
-		log.Println(err)
-		return
+		return err
 	}
 
-	c.SetHeader(req)
+	c.SetHeader(req, []byte(body))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	// 发送请求
 	resp, err := client.Do(req)
 	if err != nil {
-		// 处理错误
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		return ErrRequestRejected{Path: path, Status: resp.Status}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", path, resp.Status)
+	}
+	return nil
+}
+
+// Report 向服务端报告当前功率。设备模拟室内温度时一并报告室内温度，储能设备一并报告荷电状态。
+// 报告失败时将记录加入队列；队列不为空时，新记录也加入队列以保持顺序。之后尝试批量补报队列中的记录。
+// 服务端拒绝的记录（4xx）重试也不会成功，直接丢弃。
+func (c *Client) Report() {
+	now := time.Now()
+	sample := ReportSample{
+		Consumption: c.Appliance.Power(now),
+		Temperature: c.Appliance.Temperature(),
+		SoC:         c.Appliance.SoC(),
+		RecordedAt:  now.Unix(),
+	}
+	if c.reportQueue.Len() == 0 {
+		postData := url.Values{}
+		postData.Set("consumption", strconv.FormatFloat(sample.Consumption, 'f', 1, 64))
+		if sample.Temperature != nil {
+			postData.Set("temperature", strconv.FormatFloat(*sample.Temperature, 'f', 2, 64))
+		}
+		if sample.SoC != nil {
+			postData.Set("soc", strconv.FormatFloat(*sample.SoC, 'f', 2, 64))
+		}
+		postData.Set("recorded_at", strconv.FormatInt(sample.RecordedAt, 10))
+		err := c.postForm("/client/report", postData)
+		if err == nil {
+			return
+		}
+		log.Println(err)
+		if errors.As(err, &ErrRequestRejected{}) {
+			log.Printf("Report recorded at %d dropped.", sample.RecordedAt)
+			return
+		}
+	}
+	if err := c.reportQueue.Push(sample); err != nil {
 		log.Println(err)
+	}
+	c.flushReportQueue()
+}
+
+// flushReportQueue 按记录时间顺序批量补报队列中的记录，直到队列为空或补报失败。
+// 网络错误或服务端错误（5xx）时，等待 reportRetryInterval 再次尝试。服务端拒绝（4xx）时，将批次减半后重试以找出
+// 被拒绝的记录，丢弃该记录并继续补报，以免一条不合法的记录阻塞之后所有的报告。
+func (c *Client) flushReportQueue() {
+	if time.Now().Before(c.reportRetryAt) {
 		return
 	}
-	defer resp.Body.Close()
+	size := reportBatchSize
+	for {
+		samples := c.reportQueue.Peek(size)
+		if len(samples) == 0 {
+			return
+		}
+		postData := url.Values{}
+		for _, sample := range samples {
+			postData.Add("consumption", strconv.FormatFloat(sample.Consumption, 'f', 1, 64))
+			postData.Add("recorded_at", strconv.FormatInt(sample.RecordedAt, 10))
+			// 未报告温度或荷电状态的记录提交空值，使其与功率一一对应。
+			postData.Add("temperature", formatOptionalFloat(sample.Temperature))
+			postData.Add("soc", formatOptionalFloat(sample.SoC))
+		}
+		err := c.postForm("/client/report/batch", postData)
+		if errors.As(err, &ErrRequestRejected{}) {
+			if len(samples) > 1 {
+				size = len(samples) / 2
+				continue
+			}
+			log.Printf("Queued report recorded at %d rejected and dropped: %v", samples[0].RecordedAt, err)
+		} else if err != nil {
+			log.Printf("Uploading %d queued report(s) failed: %v", c.reportQueue.Len(), err)
+			c.reportRetryAt = time.Now().Add(reportRetryInterval)
+			return
+		} else {
+			log.Printf("Uploaded %d queued report(s).", len(samples))
+			size = reportBatchSize
+		}
+		if err := c.reportQueue.Remove(len(samples)); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// formatOptionalFloat 格式化可选的数值。为空时返回空字符串。
+func formatOptionalFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', 2, 64)
+}
+
+// Ack 向服务端确认已执行命令。commandID 为命令事件中的ID，value 为实际生效的值。
+func (c *Client) Ack(commandID string, value string) {
+	postData := url.Values{}
+	postData.Set("id", commandID)
+	postData.Set("value", value)
+	if err := c.postForm("/client/ack", postData); err != nil {
+		log.Printf("Ack of command[%s] failed: %v", commandID, err)
+	}
+}
+
+// ackHandled 判断命令是否已经执行过。已执行过的命令以当时的生效值再次确认。
+func (c *Client) ackHandled(commandID string) bool {
+	if len(commandID) == 0 {
+		return false
+	}
+	value, ok := c.commands.Get(commandID)
+	if ok {
+		go c.Ack(commandID, value)
+	}
+	return ok
+}
+
+// ackApplied 记录已执行的命令，并确认命令、报告实际生效的值。旧版服务端下发的命令没有ID，无需确认。
+func (c *Client) ackApplied(commandID string, value string) {
+	if len(commandID) == 0 {
+		return
+	}
+	c.commands.Add(commandID, value)
+	go c.Ack(commandID, value)
 }
 
-func (c *Client) SetHeader(req *http.Request) {
+// SetHeader 设置验证所需的请求头。body 为请求体，用于计算签名。
+// 配置了密钥时使用 HMAC-SHA256 签名，否则使用旧的 MD5 验证方式。
+func (c *Client) SetHeader(req *http.Request, body []byte) {
 	clientID := c.ID()
 	clientType := fmt.Sprintf("%d", c.Type())
 	req.Header.Set(common.RequestClientID, clientID)
 	req.Header.Set(common.RequestClientType, clientType)
+	if len(c.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		nonce := make([]byte, 16)
+		_, _ = rand.Read(nonce)
+		nonceHex := hex.EncodeToString(nonce)
+		req.Header.Set(common.RequestTimestamp, timestamp)
+		req.Header.Set(common.RequestNonce, nonceHex)
+		req.Header.Set(common.RequestSignature, common.SignRequest(c.secret, req.Method, req.URL.Path, timestamp, nonceHex, body))
+		return
+	}
 	clientIDBytes := []byte(clientID)
 	sum := md5.Sum(append(clientIDBytes, byte(c.Type())))
 	req.Header.Set(common.RequestAuthorization, hex.EncodeToString(sum[:]))
@@ -159,7 +365,46 @@ func (c *Client) ProcessEvent(event, data string) common.EventInterface {
 		if err != nil {
 			return nil
 		}
-		c.PowerMode.Change(e.EventBase.Data.Power)
+		// 重新发送或重放的命令只确认，不再执行，以免覆盖之后收到的命令。
+		if c.ackHandled(e.EventBase.Data.ID) {
+			return e
+		}
+		applied := strconv.Itoa(c.Appliance.Command(e.EventBase.Data.Power))
+		c.ackApplied(e.EventBase.Data.ID, applied)
+		return e
+	case common.EventNameCommandSetpoint:
+		e := &common.EventCommandSetpoint{}
+		err := e.EventBase.UnmarshalData(data)
+		if err != nil {
+			return nil
+		}
+		if c.ackHandled(e.EventBase.Data.ID) {
+			return e
+		}
+		applied, err := c.Appliance.Setpoint(e.EventBase.Data.Setpoint)
+		if err != nil {
+			// 不确认不支持的命令，服务端重试后将其标记为未确认。
+			log.Println(err)
+			return e
+		}
+		c.ackApplied(e.EventBase.Data.ID, strconv.FormatFloat(applied, 'f', -1, 64))
+		return e
+	case common.EventNameCommandBattery:
+		e := &common.EventCommandBattery{}
+		err := e.EventBase.UnmarshalData(data)
+		if err != nil {
+			return nil
+		}
+		if c.ackHandled(e.EventBase.Data.ID) {
+			return e
+		}
+		applied, err := c.Appliance.Battery(e.EventBase.Data.Mode, e.EventBase.Data.Power)
+		if err != nil {
+			// 不确认不支持的命令，服务端重试后将其标记为未确认。
+			log.Println(err)
+			return e
+		}
+		c.ackApplied(e.EventBase.Data.ID, strconv.Itoa(applied))
 		return e
 	case common.EventNameMessage:
 		e := &common.EventMessage{}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestClient_Register 测试超过 idleTimeout 未收到服务端的任何数据时中断连接，定期收到数据时保持连接。
+func TestClient_Register(t *testing.T) {
+	tests := []struct {
+		name     string
+		pings    int // 服务端每 20 毫秒发送一行数据的次数，之后不再发送。
+		close    bool
+		expected error
+	}{
+		{"silent", 0, false, ErrIdleTimeout},
+		{"pings", 10, false, ErrIdleTimeout},
+		{"closed", 10, true, io.EOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.WriteHeader(http.StatusOK)
+				w.(http.Flusher).Flush()
+				for i := 0; i < tt.pings; i++ {
+					time.Sleep(20 * time.Millisecond)
+					io.WriteString(w, ": ping\n")
+					w.(http.Flusher).Flush()
+				}
+				if !tt.close {
+					<-r.Context().Done()
+				}
+			}))
+			defer server.Close()
+			socket := apiSocket
+			apiSocket = strings.TrimPrefix(server.URL, "http://")
+			defer func() { apiSocket = socket }()
+
+			c := NewClient("test", 1, "secret", nil, nil, 100*time.Millisecond)
+			var connected bool
+			start := time.Now()
+			err := c.Register(func() { connected = true })
+			assert.True(t, connected)
+			assert.ErrorIs(t, err, tt.expected)
+			// 定期收到数据期间不会超时。
+			assert.GreaterOrEqual(t, time.Since(start), time.Duration(tt.pings)*20*time.Millisecond)
+		})
+	}
+}
+
+// TestClient_flushReportQueue 测试服务端拒绝的记录被丢弃，之后的记录仍然补报；服务端错误时保留记录稍后重试。
+func TestClient_flushReportQueue(t *testing.T) {
+	var uploaded []string
+	unavailable := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unavailable {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		r.ParseForm()
+		// 拒绝包含不合法记录的整个批次。
+		for _, consumption := range r.PostForm["consumption"] {
+			if consumption == "-1.0" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		uploaded = append(uploaded, r.PostForm["recorded_at"]...)
+	}))
+	defer server.Close()
+	socket := apiSocket
+	apiSocket = strings.TrimPrefix(server.URL, "http://")
+	defer func() { apiSocket = socket }()
+
+	queue, err := NewReportQueue(filepath.Join(t.TempDir(), "queue"), 100)
+	assert.Nil(t, err)
+	for i := int64(1); i <= 5; i++ {
+		sample := ReportSample{Consumption: 100, RecordedAt: i}
+		if i == 3 {
+			sample.Consumption = -1
+		}
+		assert.Nil(t, queue.Push(sample))
+	}
+	c := NewClient("test", 1, "secret", nil, queue, time.Minute)
+	c.flushReportQueue()
+	assert.Equal(t, []string{"1", "2", "4", "5"}, uploaded)
+	assert.Equal(t, 0, queue.Len())
+
+	// 服务端错误时保留记录，等待一段时间后再次尝试。
+	uploaded = nil
+	unavailable = true
+	for i := int64(6); i <= 7; i++ {
+		assert.Nil(t, queue.Push(ReportSample{Consumption: 100, RecordedAt: i}))
+	}
+	c.flushReportQueue()
+	assert.Equal(t, 2, queue.Len())
+	unavailable = false
+	c.flushReportQueue()
+	assert.Equal(t, 2, queue.Len())
+	c.reportRetryAt = time.Time{}
+	c.flushReportQueue()
+	assert.Equal(t, []string{"6", "7"}, uploaded)
+	assert.Equal(t, 0, queue.Len())
+}
@@ -0,0 +1,38 @@
+package main
+
+// commandHistorySize 表示记住的最近执行过的命令数。
+const commandHistorySize = 64
+
+// CommandHistory 记住最近执行过的命令ID及其实际生效的值。
+// 服务端以相同的ID重新发送未确认的命令，重连时也会重放命令；已执行过的命令不应再次执行，
+// 否则较早的命令会覆盖之后收到的命令。超过容量时遗忘最早的记录。
+type CommandHistory struct {
+	size   int
+	values map[string]string
+	order  []string
+}
+
+func NewCommandHistory(size int) *CommandHistory {
+	return &CommandHistory{
+		size:   size,
+		values: make(map[string]string, size),
+	}
+}
+
+// Add 记录已执行的命令。
+func (h *CommandHistory) Add(commandID string, value string) {
+	if _, ok := h.values[commandID]; !ok {
+		h.order = append(h.order, commandID)
+	}
+	h.values[commandID] = value
+	for len(h.order) > h.size {
+		delete(h.values, h.order[0])
+		h.order = h.order[1:]
+	}
+}
+
+// Get 返回已执行命令的生效值。命令未执行过或已被遗忘时 ok 为 false。
+func (h *CommandHistory) Get(commandID string) (value string, ok bool) {
+	value, ok = h.values[commandID]
+	return value, ok
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCommandHistory 测试记录已执行的命令，以及超过容量时遗忘最早的记录。
+func TestCommandHistory(t *testing.T) {
+	h := NewCommandHistory(3)
+	_, ok := h.Get("1")
+	assert.False(t, ok)
+
+	for i := 1; i <= 3; i++ {
+		h.Add(strconv.Itoa(i), strconv.Itoa(i*100))
+	}
+	// 重复记录同一命令时更新生效值，不占用额外的容量。
+	h.Add("1", "150")
+	h.Add("4", "400")
+	tests := []struct {
+		commandID string
+		value     string
+		ok        bool
+	}{
+		{"1", "", false},
+		{"2", "200", true},
+		{"3", "300", true},
+		{"4", "400", true},
+	}
+	for _, tt := range tests {
+		value, ok := h.Get(tt.commandID)
+		assert.Equal(t, tt.ok, ok, tt.commandID)
+		assert.Equal(t, tt.value, value, tt.commandID)
+	}
+}
@@ -1,25 +1,60 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/pelletier/go-toml/v2"
 )
 
 type ConfigClient struct {
-	ID          string `toml:"id"`           // 客户端编号
-	Type        int    `toml:"type"`         // 客户端类型
-	PowerFactor int    `toml:"power_factor"` // 客户端起始功率
+	ID             string `toml:"id"`              // 客户端编号
+	Type           int    `toml:"type"`            // 客户端类型
+	PowerFactor    int    `toml:"power_factor"`    // 客户端起始功率
+	Secret         string `toml:"secret"`          // 客户端密钥。为空时使用旧的 MD5 验证方式。
+	EnrollmentCode string `toml:"enrollment_code"` // 注册码。编号为空时，使用注册码换取编号和密钥。
 }
 
 type ConfigServer struct {
-	Socket            string `toml:"socket"` // 服务端套接字
-	ReportConsumption bool   `toml:"report_consumption"`
+	Socket                   string `toml:"socket"` // 服务端套接字
+	ReportConsumption        bool   `toml:"report_consumption"`
+	ReconnectInitialInterval int64  `toml:"reconnect_initial_interval"` // 首次重连前的最长等待时间，单位为秒。
+	ReconnectMaxInterval     int64  `toml:"reconnect_max_interval"`     // 重连前的最长等待时间，单位为秒。
+	IdleTimeout              int64  `toml:"idle_timeout"`               // 未收到服务端任何事件的最长时间，超过后视为连接中断，单位为秒。
+	ReportQueue              string `toml:"report_queue"`               // 保存未能报告的功率的文件。为空时为配置文件名加 .queue 后缀。
+	ReportQueueSize          int    `toml:"report_queue_size"`          // 最多保存的未能报告的功率记录数，超出时丢弃最早的记录。
+}
+
+// ConfigModel 设备行为模型配置。未指定的参数使用各模型的默认值。
+type ConfigModel struct {
+	Type     string   `toml:"type"`     // 行为模型：constant（默认，以 power_factor 为功率）、refrigerator、washer、heater、standby、lighting、hvac、pv、battery。
+	Seed     int64    `toml:"seed"`     // 随机种子。为 0 时以客户端编号生成。
+	Noise    float64  `toml:"noise"`    // 功率噪声的相对幅度（标准差），例如 0.03 表示 3%。
+	Power    float64  `toml:"power"`    // 额定功率，单位为瓦。
+	Setpoint float64  `toml:"setpoint"` // 温度设定值，单位为摄氏度。适用于 refrigerator、heater 和 hvac。
+	Ambient  *float64 `toml:"ambient"`  // 环境（室外）温度，单位为摄氏度。适用于 refrigerator、heater 和 hvac；hvac 为日平均温度。
+	Swing    *float64 `toml:"swing"`    // hvac 室外温度的日较差的一半，单位为摄氏度。室外温度在 5 时最低，17 时最高。
+	COP      float64  `toml:"cop"`      // hvac 在额定工况下的能效比。
+	Interval int64    `toml:"interval"` // washer 两次洗衣程序开始之间的间隔，单位为秒。
+	Lights   int      `toml:"lights"`   // lighting 的灯具数量。
+
+	Latitude   *float64 `toml:"latitude"`   // pv 所在的纬度，单位为度，北纬为正。
+	Longitude  *float64 `toml:"longitude"`  // pv 所在的经度，单位为度，东经为正。未指定时以本地时间作为太阳时。
+	Area       float64  `toml:"area"`       // pv 组件面积，单位为平方米。
+	Efficiency float64  `toml:"efficiency"` // pv 组件转换效率，例如 0.2；battery 往返效率，例如 0.9。
+	Cloudiness *float64 `toml:"cloudiness"` // pv 的平均云量，0 为晴天，1 为全阴。
+
+	Capacity       float64  `toml:"capacity"`        // battery 容量，单位为瓦时。
+	ChargePower    float64  `toml:"charge_power"`    // battery 最大充电功率，单位为瓦。
+	DischargePower float64  `toml:"discharge_power"` // battery 最大放电功率，单位为瓦。
+	SoC            *float64 `toml:"soc"`             // battery 初始荷电状态，单位为百分比。未指定时随机。
 }
 
 type Config struct {
 	Client ConfigClient `toml:"client"`
 	Server ConfigServer `toml:"server"`
+	Model  ConfigModel  `toml:"model"`
 }
 
 func LoadConfig(name string) *Config {
@@ -34,5 +69,58 @@ func LoadConfig(name string) *Config {
 	if err := toml.NewDecoder(file).Decode(&config); err != nil {
 		panic(err)
 	}
+	if config.Server.ReconnectInitialInterval <= 0 {
+		config.Server.ReconnectInitialInterval = 1
+	}
+	if config.Server.ReconnectMaxInterval <= 0 {
+		config.Server.ReconnectMaxInterval = 60
+	}
+	if config.Server.ReconnectMaxInterval < config.Server.ReconnectInitialInterval {
+		config.Server.ReconnectMaxInterval = config.Server.ReconnectInitialInterval
+	}
+	if config.Server.IdleTimeout <= 0 {
+		config.Server.IdleTimeout = 10
+	}
+	if len(config.Server.ReportQueue) == 0 {
+		config.Server.ReportQueue = name + ".queue"
+	}
+	if config.Server.ReportQueueSize <= 0 {
+		config.Server.ReportQueueSize = 86400
+	}
 	return &config
 }
+
+// CheckConfigWritable 检查能否将配置写回文件：配置文件可写，且所在目录可以创建 SaveConfig 使用的临时文件。
+// 注册码只能使用一次，因此在使用注册码之前检查，以免换取的密钥无法保存。
+func CheckConfigWritable(name string) error {
+	file, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	temp.Close()
+	return os.Remove(temp.Name())
+}
+
+// SaveConfig 将配置写回文件。注释不会保留。
+// 先写入临时文件再替换，以免写入中断时丢失配置；替换失败时保留临时文件，其中的编号和密钥仍可手动恢复。
+func SaveConfig(name string, config *Config) error {
+	content, err := toml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	temp := name + ".tmp"
+	if err := os.WriteFile(temp, content, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(temp, name); err != nil {
+		return fmt.Errorf("config saved to %s but not replaced: %w", temp, err)
+	}
+	return nil
+}
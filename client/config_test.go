@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSaveConfig 测试检查配置文件可写，以及写回的配置可以重新读取。
+func TestSaveConfig(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "client.toml")
+	assert.NotNil(t, CheckConfigWritable(name))
+
+	assert.Nil(t, os.WriteFile(name, []byte("[client]\nenrollment_code=\"code\"\n"), 0600))
+	assert.Nil(t, CheckConfigWritable(name))
+	config := LoadConfig(name)
+	config.Client.ID = "id"
+	config.Client.Secret = "secret"
+	config.Client.EnrollmentCode = ""
+	assert.Nil(t, SaveConfig(name, config))
+	saved := LoadConfig(name)
+	assert.Equal(t, "id", saved.Client.ID)
+	assert.Equal(t, "secret", saved.Client.Secret)
+	assert.Empty(t, saved.Client.EnrollmentCode)
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+
+	// 只读的配置文件不可写。以 root 运行时忽略权限，因此跳过。
+	if os.Geteuid() != 0 {
+		assert.Nil(t, os.Chmod(name, 0400))
+		assert.NotNil(t, CheckConfigWritable(name))
+	}
+}
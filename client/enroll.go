@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type ResponseEnroll struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Type   int    `json:"type"`
+	Secret string `json:"secret"`
+}
+
+// Enroll 使用配置中的注册码换取客户端编号和密钥，并更新配置。
+func Enroll(config *Config) error {
+	if len(config.Client.EnrollmentCode) == 0 {
+		return errors.New("client id and enrollment code not specified")
+	}
+
+	postData := url.Values{}
+	postData.Set("code", config.Client.EnrollmentCode)
+	resp, err := http.Post(fmt.Sprintf("http://%s/client/enroll", apiSocket), "application/x-www-form-urlencoded", strings.NewReader(postData.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("enroll failed: %s", string(body))
+	}
+
+	result := ResponseEnroll{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return err
+	}
+	config.Client.ID = result.ID
+	config.Client.Type = result.Type
+	config.Client.Secret = result.Secret
+	config.Client.EnrollmentCode = ""
+	log.Printf("Enrolled as client[%s].", result.ID)
+	return nil
+}
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -21,10 +26,51 @@ func main() {
 	// 访问命令行参数的值
 	config := LoadConfig(*inputPtr)
 	apiSocket = config.Server.Socket
-	Client := NewClient(config.Client.ID, config.Client.Type, config.Client.PowerFactor)
+	// 尚未注册时，使用注册码换取编号和密钥，并写回配置文件。注册码只能使用一次，因此先检查配置文件可写。
+	if len(config.Client.ID) == 0 {
+		if err := CheckConfigWritable(*inputPtr); err != nil {
+			log.Println(err)
+			return
+		}
+		if err := Enroll(config); err != nil {
+			log.Println(err)
+			return
+		}
+		if err := SaveConfig(*inputPtr, config); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+	// 未能报告的功率保存在磁盘上，待服务端可达后补报。
+	reportQueue, err := NewReportQueue(config.Server.ReportQueue, config.Server.ReportQueueSize)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	appliance, err := NewAppliance(config.Model, config.Client.ID, config.Client.PowerFactor, time.Now())
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	Client := NewClient(config.Client.ID, config.Client.Type, config.Client.Secret, appliance, reportQueue, time.Duration(config.Server.IdleTimeout)*time.Second)
 	// 启动注册逻辑并持续接收服务端发来的命令。
+	// 连接断开（例如服务端重启或网络故障）后，按指数退避等待后重连，直到服务端要求断开。重连期间仍然报告功率。
 	exitChannel = make(chan bool)
-	go Client.Register(exit)
+	backoff := NewBackoff(time.Duration(config.Server.ReconnectInitialInterval)*time.Second, time.Duration(config.Server.ReconnectMaxInterval)*time.Second)
+	go func() {
+		for {
+			err := Client.Register(backoff.Reset)
+			if errors.Is(err, ErrDisconnected) {
+				exit()
+				return
+			}
+			wait := backoff.Next()
+			log.Printf("Connection lost: %v. Reconnecting in %s.", err, wait)
+			time.Sleep(wait)
+		}
+	}()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
 	ticker := time.NewTicker(time.Second)
 	for {
 		select {
@@ -34,6 +80,11 @@ func main() {
 				Client.Report()
 			}
 		case <-exitChannel:
+			log.Println("Disconnected by server.")
+			ticker.Stop()
+			return
+		case s := <-signals:
+			log.Printf("Received %s, exiting.", s)
 			ticker.Stop()
 			return
 		}
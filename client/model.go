@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Model 表示设备的用电行为模型。模型按调用时刻推进内部状态，调用时刻须单调递增。
+// Appliance 负责加锁，模型本身无需并发安全。
+type Model interface {
+	// Power 返回设备在 now 时刻的功率，单位为瓦。发电设备的功率为负数。
+	Power(now time.Time) float64
+	// Command 处理 command-power 命令，调整设定值或状态，并返回实际生效的值。0 表示关闭设备；
+	// 负数（common.CommandPowerUnlimited）表示取消之前的命令设定的限制，恢复设备的正常运行。
+	Command(value int) int
+}
+
+// SetpointModel 表示可以设定目标温度的模型，处理 command-setpoint 命令。
+type SetpointModel interface {
+	// Setpoint 设定目标温度，单位为摄氏度，并返回实际生效的值。
+	Setpoint(value float64) float64
+}
+
+// ThermalModel 表示模拟室内温度的模型。报告功率时一并报告室内温度。
+type ThermalModel interface {
+	// Temperature 返回最近一次计算功率时的室内温度，单位为摄氏度。
+	Temperature() float64
+}
+
+// StorageModel 表示储能设备的模型，处理 command-battery 命令。报告功率时一并报告荷电状态。
+type StorageModel interface {
+	// Battery 以 mode 模式、power 瓦充电、放电或待机，并返回实际生效的功率。
+	Battery(mode string, power int) int
+	// SoC 返回荷电状态，单位为百分比。
+	SoC() float64
+}
+
+const (
+	ModelConstant     = "constant"
+	ModelRefrigerator = "refrigerator"
+	ModelWasher       = "washer"
+	ModelHeater       = "heater"
+	ModelStandby      = "standby"
+	ModelLighting     = "lighting"
+	ModelHVAC         = "hvac"
+	ModelPV           = "pv"
+	ModelBattery      = "battery"
+)
+
+// Appliance 表示模拟的设备：在行为模型的功率上叠加随机噪声，并保证并发安全。
+// 随机数由种子生成，相同的种子和配置得到相同的功率曲线。
+type Appliance struct {
+	model Model
+	noise float64 // 噪声的相对幅度（标准差），例如 0.03 表示 3%。
+	rng   *rand.Rand
+	mu    sync.Mutex
+}
+
+// NewAppliance 根据配置实例化设备。未指定模型类型时使用 constant 模型，以 powerFactor 为功率。
+// 未指定种子时，以客户端编号生成种子，使同一设备每次运行的功率曲线相同。
+func NewAppliance(config ConfigModel, clientID string, powerFactor int, now time.Time) (*Appliance, error) {
+	seed := config.Seed
+	if seed == 0 {
+		h := fnv.New64a()
+		h.Write([]byte(clientID))
+		seed = int64(h.Sum64())
+	}
+	rng := rand.New(rand.NewSource(seed))
+	var model Model
+	switch config.Type {
+	case "", ModelConstant:
+		model = NewConstantModel(powerFactor)
+	case ModelRefrigerator:
+		model = NewRefrigeratorModel(config, rng, now)
+	case ModelWasher:
+		model = NewWasherModel(config, rng, now)
+	case ModelHeater:
+		model = NewHeaterModel(config, rng, now)
+	case ModelStandby:
+		model = NewStandbyModel(config)
+	case ModelLighting:
+		model = NewLightingModel(config, rng, now)
+	case ModelHVAC:
+		model = NewHVACModel(config, rng, now)
+	case ModelPV:
+		model = NewPVModel(config, rng, now)
+	case ModelBattery:
+		model = NewBatteryModel(config, rng, now)
+	default:
+		return nil, fmt.Errorf("model not supported: %s", config.Type)
+	}
+	return &Appliance{model: model, noise: config.Noise, rng: rng}, nil
+}
+
+// Power 返回设备在 now 时刻的功率，单位为瓦。发电设备的功率为负数。
+// 功率为 0 时（设备关闭）不叠加噪声，功率不会因噪声改变正负。
+func (a *Appliance) Power(now time.Time) float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	power := a.model.Power(now)
+	if power == 0 || a.noise <= 0 {
+		return power
+	}
+	noisy := power * (1 + a.noise*a.rng.NormFloat64())
+	if (noisy < 0) != (power < 0) {
+		return 0
+	}
+	return noisy
+}
+
+// Command 处理 command-power 命令，并返回实际生效的值。
+func (a *Appliance) Command(value int) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.model.Command(value)
+}
+
+// ErrSetpointNotSupported 表示设备不支持设定温度。
+var ErrSetpointNotSupported = errors.New("setpoint not supported")
+
+// Setpoint 处理 command-setpoint 命令，并返回实际生效的值。设备不支持设定温度时返回 ErrSetpointNotSupported。
+func (a *Appliance) Setpoint(value float64) (float64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	model, ok := a.model.(SetpointModel)
+	if !ok {
+		return 0, ErrSetpointNotSupported
+	}
+	return model.Setpoint(value), nil
+}
+
+// Temperature 返回室内温度。设备不模拟室内温度时返回 nil。
+func (a *Appliance) Temperature() *float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	model, ok := a.model.(ThermalModel)
+	if !ok {
+		return nil
+	}
+	t := model.Temperature()
+	return &t
+}
+
+// ErrBatteryNotSupported 表示设备不是储能设备。
+var ErrBatteryNotSupported = errors.New("battery not supported")
+
+// Battery 处理 command-battery 命令，并返回实际生效的功率。设备不是储能设备时返回 ErrBatteryNotSupported。
+func (a *Appliance) Battery(mode string, power int) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	model, ok := a.model.(StorageModel)
+	if !ok {
+		return 0, ErrBatteryNotSupported
+	}
+	return model.Battery(mode, power), nil
+}
+
+// SoC 返回荷电状态（百分比）。设备不是储能设备时返回 nil。
+func (a *Appliance) SoC() *float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	model, ok := a.model.(StorageModel)
+	if !ok {
+		return nil
+	}
+	soc := model.SoC()
+	return &soc
+}
+
+// advance 以不超过 1 秒的步长将模型从 last 推进到 now，每一步以该步结束的时刻和步长（秒）调用 step。
+// 首次调用时仅记录时刻。单次最多推进一小时，以免暂停较久后计算过久。
+func advance(last *time.Time, now time.Time, step func(t time.Time, dt float64)) {
+	if last.IsZero() {
+		*last = now
+		return
+	}
+	if !now.After(*last) {
+		return
+	}
+	if now.Sub(*last) > time.Hour {
+		*last = now.Add(-time.Hour)
+	}
+	for t := *last; t.Before(now); {
+		next := t.Add(time.Second)
+		if next.After(now) {
+			next = now
+		}
+		step(next, next.Sub(t).Seconds())
+		t = next
+	}
+	*last = now
+}
+
+// orDefault 返回 value，value 不大于 0 时返回 def。
+func orDefault(value float64, def float64) float64 {
+	if value > 0 {
+		return value
+	}
+	return def
+}
+
+// capPower 返回不超过 limit 的功率。limit 为负数时不限制。
+func capPower(power float64, limit float64) float64 {
+	if limit >= 0 && power > limit {
+		return limit
+	}
+	return power
+}
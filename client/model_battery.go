@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/vistart/project20240227/server/common"
+)
+
+// BatteryModel 表示家用储能电池：按 command-battery 命令以指定功率充电、放电或待机。
+// 充电和放电的损耗各占往返效率的一半（各为往返效率的平方根）。充满或放空后功率降为 0，直到收到新的命令。
+// 充电时功率为正数，放电时为负数。command-power 命令设定充放电功率的上限，为 0 时相当于待机，为负数时取消上限。
+type BatteryModel struct {
+	capacity     float64 // 容量，单位为瓦时。
+	chargeMax    float64 // 最大充电功率，单位为瓦。
+	dischargeMax float64 // 最大放电功率，单位为瓦。
+	efficiency   float64 // 往返效率。
+	limit        float64 // 充放电功率上限，单位为瓦。为负数时不限制。
+	energy       float64 // 当前储存的能量，单位为瓦时。
+	mode         string  // 充放电模式，同 command-battery 命令。
+	target       float64 // 命令要求的充电或放电功率，单位为瓦。
+	power        float64 // 当前功率，单位为瓦。
+	last         time.Time
+}
+
+func NewBatteryModel(config ConfigModel, rng *rand.Rand, now time.Time) *BatteryModel {
+	m := &BatteryModel{
+		capacity:     orDefault(config.Capacity, 10000),
+		chargeMax:    orDefault(config.ChargePower, 5000),
+		dischargeMax: orDefault(config.DischargePower, 5000),
+		efficiency:   math.Min(orDefault(config.Efficiency, 0.9), 1),
+		limit:        -1,
+		mode:         common.BatteryModeIdle,
+		last:         now,
+	}
+	soc := 20 + rng.Float64()*60
+	if config.SoC != nil {
+		soc = math.Max(0, math.Min(*config.SoC, 100))
+	}
+	m.energy = m.capacity * soc / 100
+	return m
+}
+
+func (m *BatteryModel) step(t time.Time, dt float64) {
+	oneWay := math.Sqrt(m.efficiency)
+	hours := dt / 3600
+	switch m.mode {
+	case common.BatteryModeCharge:
+		p := capPower(math.Min(m.target, m.chargeMax), m.limit)
+		if room := m.capacity - m.energy; p*oneWay*hours > room {
+			p = room / (oneWay * hours)
+		}
+		m.energy += p * oneWay * hours
+		m.power = p
+	case common.BatteryModeDischarge:
+		p := capPower(math.Min(m.target, m.dischargeMax), m.limit)
+		if p/oneWay*hours > m.energy {
+			p = m.energy * oneWay / hours
+		}
+		m.energy -= p / oneWay * hours
+		m.power = -p
+	default:
+		m.power = 0
+	}
+	m.energy = math.Max(0, math.Min(m.energy, m.capacity))
+}
+
+func (m *BatteryModel) Power(now time.Time) float64 {
+	advance(&m.last, now, m.step)
+	if m.power == 0 {
+		return 0
+	}
+	return m.power
+}
+
+func (m *BatteryModel) Command(value int) int {
+	// 负数不限制功率。
+	m.limit = float64(value)
+	return int(capPower(math.Max(m.chargeMax, m.dischargeMax), m.limit))
+}
+
+func (m *BatteryModel) Battery(mode string, power int) int {
+	m.mode = mode
+	m.target = math.Max(float64(power), 0)
+	switch mode {
+	case common.BatteryModeCharge:
+		return int(capPower(math.Min(m.target, m.chargeMax), m.limit))
+	case common.BatteryModeDischarge:
+		return int(capPower(math.Min(m.target, m.dischargeMax), m.limit))
+	default:
+		m.mode = common.BatteryModeIdle
+		m.target = 0
+		return 0
+	}
+}
+
+func (m *BatteryModel) SoC() float64 {
+	return m.energy / m.capacity * 100
+}
@@ -0,0 +1,25 @@
+package main
+
+import "time"
+
+// ConstantModel 以固定功率运行，command-power 命令直接设定功率，为负数时恢复配置的功率。未配置行为模型时使用。
+type ConstantModel struct {
+	rated int // 配置的功率，单位为瓦。
+	power int
+}
+
+func NewConstantModel(power int) *ConstantModel {
+	return &ConstantModel{rated: power, power: power}
+}
+
+func (m *ConstantModel) Power(now time.Time) float64 {
+	return float64(m.power)
+}
+
+func (m *ConstantModel) Command(value int) int {
+	if value < 0 {
+		value = m.rated
+	}
+	m.power = value
+	return m.power
+}
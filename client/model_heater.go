@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// HeaterModel 表示带温控器的电阻式取暖器：房间温度按一阶模型向室外温度回落，加热功率使其升高；
+// 温度低于设定值减回差时开始加热，高于设定值加回差时停止。
+// command-power 命令设定加热功率的上限，为 0 时关闭取暖器，为负数时取消上限；command-setpoint 命令设定房间温度。
+type HeaterModel struct {
+	power       float64 // 额定功率，单位为瓦。
+	limit       float64 // 功率上限，单位为瓦。为负数时不限制。
+	setpoint    float64 // 房间温度设定值，单位为摄氏度。
+	outdoor     float64 // 室外温度，单位为摄氏度。
+	hysteresis  float64 // 回差，单位为摄氏度。
+	temperature float64 // 房间温度，单位为摄氏度。
+	heating     bool
+	last        time.Time
+}
+
+const (
+	heaterRoomTau = 14400.0 // 房间温度回落的时间常数，单位为秒。
+	heaterRise    = 25.0    // 以额定功率持续加热时，房间温度最终高于室外温度的度数。
+)
+
+func NewHeaterModel(config ConfigModel, rng *rand.Rand, now time.Time) *HeaterModel {
+	m := &HeaterModel{
+		power:      orDefault(config.Power, 2000),
+		limit:      -1,
+		setpoint:   orDefault(config.Setpoint, 21),
+		outdoor:    5,
+		hysteresis: 0.5,
+		last:       now,
+	}
+	if config.Ambient != nil {
+		m.outdoor = *config.Ambient
+	}
+	m.temperature = m.setpoint - m.hysteresis + rng.Float64()*2*m.hysteresis
+	m.heating = rng.Intn(2) == 0
+	return m
+}
+
+func (m *HeaterModel) element() float64 {
+	if !m.heating {
+		return 0
+	}
+	return capPower(m.power, m.limit)
+}
+
+func (m *HeaterModel) Power(now time.Time) float64 {
+	advance(&m.last, now, func(t time.Time, dt float64) {
+		gain := heaterRise / (m.power * heaterRoomTau)
+		m.temperature += ((m.outdoor-m.temperature)/heaterRoomTau + gain*m.element()) * dt
+		if m.temperature <= m.setpoint-m.hysteresis {
+			m.heating = true
+		} else if m.temperature >= m.setpoint+m.hysteresis {
+			m.heating = false
+		}
+	})
+	return m.element()
+}
+
+func (m *HeaterModel) Command(value int) int {
+	// 负数不限制功率。
+	m.limit = float64(value)
+	return int(capPower(m.power, m.limit))
+}
+
+func (m *HeaterModel) Setpoint(value float64) float64 {
+	m.setpoint = value
+	return m.setpoint
+}
+
+func (m *HeaterModel) Temperature() float64 {
+	return m.temperature
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// HVACModel 表示变频热泵空调：房间按一阶热模型与室外换热，室外温度按日变化曲线变化。
+// 空调根据设定值与室内、室外温度调节制热或制冷量，电功率为制热（制冷）量除以能效比（COP），
+// COP 随室外温度变化：制热时室外越冷越低，制冷时室外越热越低。负荷低于最小运行功率时启停运行。
+// command-power 命令设定电功率的上限，为 0 时关闭空调，为负数时取消上限；command-setpoint 命令设定室内温度。
+type HVACModel struct {
+	power       float64 // 额定电功率，单位为瓦。
+	limit       float64 // 电功率上限，单位为瓦。为负数时不限制。
+	cop         float64 // 额定工况下的能效比。
+	setpoint    float64 // 室内温度设定值，单位为摄氏度。
+	ambient     float64 // 室外日平均温度，单位为摄氏度。
+	swing       float64 // 室外温度日较差的一半，单位为摄氏度。
+	temperature float64 // 室内温度，单位为摄氏度。
+	electric    float64 // 当前电功率，单位为瓦。
+	last        time.Time
+}
+
+const (
+	hvacUA         = 150.0   // 房间的热损失系数，单位为瓦每摄氏度。
+	hvacRoomTau    = 14400.0 // 房间温度的时间常数，单位为秒。热容为 hvacUA * hvacRoomTau。
+	hvacGain       = 800.0   // 室内温度每偏离设定值一度增加的制热（制冷）量，单位为瓦。
+	hvacMinLoad    = 0.15    // 最小运行功率与额定功率之比。
+	hvacDeadband   = 0.5     // 负荷低于最小运行功率时，室内温度偏离设定值超过该值才启动，单位为摄氏度。
+	hvacCOPSlope   = 0.025   // 室外温度每偏离额定工况一度，COP 变化的比例。
+	hvacHeatRating = 7.0     // 制热额定工况的室外温度，单位为摄氏度。
+	hvacCoolRating = 35.0    // 制冷额定工况的室外温度，单位为摄氏度。
+)
+
+func NewHVACModel(config ConfigModel, rng *rand.Rand, now time.Time) *HVACModel {
+	m := &HVACModel{
+		power:    orDefault(config.Power, 1500),
+		limit:    -1,
+		cop:      orDefault(config.COP, 3.5),
+		setpoint: orDefault(config.Setpoint, 22),
+		ambient:  10,
+		swing:    5,
+		last:     now,
+	}
+	if config.Ambient != nil {
+		m.ambient = *config.Ambient
+	}
+	if config.Swing != nil {
+		m.swing = *config.Swing
+	}
+	m.temperature = m.setpoint - 1 + rng.Float64()*2
+	return m
+}
+
+// outdoor 返回 t 时刻的室外温度。室外温度在 5 时最低，17 时最高。
+func (m *HVACModel) outdoor(t time.Time) float64 {
+	hour := float64(t.Hour()) + float64(t.Minute())/60 + float64(t.Second())/3600
+	return m.ambient - m.swing*math.Cos(2*math.Pi*(hour-5)/24)
+}
+
+// COP 返回室外温度为 outdoor 时的能效比。heating 为 true 时为制热，否则为制冷。
+func (m *HVACModel) COP(outdoor float64, heating bool) float64 {
+	cop := m.cop * (1 + hvacCOPSlope*(outdoor-hvacHeatRating))
+	if !heating {
+		cop = m.cop * (1 - hvacCOPSlope*(outdoor-hvacCoolRating))
+	}
+	return math.Max(1, math.Min(cop, 2*m.cop))
+}
+
+func (m *HVACModel) step(t time.Time, dt float64) {
+	outdoor := m.outdoor(t)
+	// 维持设定值所需的制热量（为负数时为制冷量），加上与偏差成比例的部分。
+	demand := hvacUA*(m.setpoint-outdoor) + hvacGain*(m.setpoint-m.temperature)
+	heating := demand > 0
+	cop := m.COP(outdoor, heating)
+	electric := math.Min(math.Abs(demand)/cop, m.power)
+	if electric < hvacMinLoad*m.power {
+		electric = 0
+		if math.Abs(m.setpoint-m.temperature) >= hvacDeadband {
+			// 以最小运行功率朝设定值方向运行。
+			heating = m.setpoint > m.temperature
+			cop = m.COP(outdoor, heating)
+			electric = hvacMinLoad * m.power
+		}
+	}
+	m.electric = capPower(electric, m.limit)
+	thermal := m.electric * cop
+	if !heating {
+		thermal = -thermal
+	}
+	m.temperature += (hvacUA*(outdoor-m.temperature) + thermal) / (hvacUA * hvacRoomTau) * dt
+}
+
+func (m *HVACModel) Power(now time.Time) float64 {
+	advance(&m.last, now, m.step)
+	return m.electric
+}
+
+func (m *HVACModel) Command(value int) int {
+	// 负数不限制功率。
+	m.limit = float64(value)
+	return int(capPower(m.power, m.limit))
+}
+
+func (m *HVACModel) Setpoint(value float64) float64 {
+	m.setpoint = value
+	return m.setpoint
+}
+
+func (m *HVACModel) Temperature() float64 {
+	return m.temperature
+}
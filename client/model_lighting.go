@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// LightingModel 表示若干盏灯，每盏灯按所在时段的概率随机开关：傍晚开灯的概率高，深夜关灯的概率高。
+// command-power 命令设定所有灯的总功率上限（调光），为 0 时关闭所有灯，为负数时取消调光。
+type LightingModel struct {
+	each  float64 // 每盏灯的功率，单位为瓦。
+	lamps []bool  // 每盏灯是否开启。
+	dim   float64 // 调光比例，0 到 1。
+	rng   *rand.Rand
+	last  time.Time
+}
+
+func NewLightingModel(config ConfigModel, rng *rand.Rand, now time.Time) *LightingModel {
+	lights := config.Lights
+	if lights <= 0 {
+		lights = 5
+	}
+	return &LightingModel{
+		each:  orDefault(config.Power, 300) / float64(lights),
+		lamps: make([]bool, lights),
+		dim:   1,
+		rng:   rng,
+		last:  now,
+	}
+}
+
+// lightingRates 返回 hour 时每盏灯每秒开灯和关灯的概率。
+func lightingRates(hour int) (on float64, off float64) {
+	switch {
+	case hour >= 18 && hour < 23: // 傍晚
+		return 1.0 / 600, 1.0 / 3600
+	case hour >= 6 && hour < 8: // 早晨
+		return 1.0 / 900, 1.0 / 1200
+	case hour >= 8 && hour < 18: // 白天
+		return 1.0 / 7200, 1.0 / 600
+	default: // 深夜
+		return 1.0 / 14400, 1.0 / 300
+	}
+}
+
+func (m *LightingModel) Power(now time.Time) float64 {
+	advance(&m.last, now, func(t time.Time, dt float64) {
+		on, off := lightingRates(t.Hour())
+		for i, lit := range m.lamps {
+			if lit && m.rng.Float64() < off*dt {
+				m.lamps[i] = false
+			} else if !lit && m.rng.Float64() < on*dt {
+				m.lamps[i] = true
+			}
+		}
+	})
+	var count int
+	for _, lit := range m.lamps {
+		if lit {
+			count++
+		}
+	}
+	return float64(count) * m.each * m.dim
+}
+
+func (m *LightingModel) Command(value int) int {
+	rated := m.each * float64(len(m.lamps))
+	m.dim = 1
+	if value >= 0 && float64(value) < rated {
+		m.dim = float64(value) / rated
+	}
+	return int(rated * m.dim)
+}
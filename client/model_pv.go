@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// PVModel 表示屋顶光伏：按纬度和日期计算太阳高度角，以晴空模型计算水平面辐照度，
+// 再乘以云量造成的衰减、组件面积和转换效率得到发电功率。云量按均值回复的随机过程变化。
+// 发电功率以负数报告。command-power 命令设定发电功率的上限（逆变器限发），为 0 时停止发电，为负数时取消限发。
+type PVModel struct {
+	rated      float64  // 额定功率（标准辐照度下的发电功率），单位为瓦。
+	limit      float64  // 发电功率上限，单位为瓦。为负数时不限制。
+	area       float64  // 组件面积，单位为平方米。
+	efficiency float64  // 组件转换效率。
+	latitude   float64  // 纬度，单位为度。北纬为正。
+	longitude  *float64 // 经度，单位为度。东经为正。为空时以本地时间作为太阳时。
+	cloudiness float64  // 平均云量，0 为晴天，1 为全阴。
+	cloud      float64  // 当前云量。
+	power      float64  // 当前发电功率，单位为瓦。
+	rng        *rand.Rand
+	last       time.Time
+}
+
+const (
+	pvIrradiance = 1000.0 // 标准辐照度，单位为瓦每平方米。
+	pvCloudTau   = 1200.0 // 云量回复平均值的时间常数，单位为秒。
+	pvCloudSigma = 0.01   // 云量每秒随机变化的幅度。
+)
+
+func NewPVModel(config ConfigModel, rng *rand.Rand, now time.Time) *PVModel {
+	m := &PVModel{
+		limit:      -1,
+		area:       orDefault(config.Area, 10),
+		efficiency: orDefault(config.Efficiency, 0.2),
+		latitude:   31.2,
+		longitude:  config.Longitude,
+		cloudiness: 0.3,
+		rng:        rng,
+		last:       now,
+	}
+	if config.Latitude != nil {
+		m.latitude = *config.Latitude
+	}
+	if config.Cloudiness != nil {
+		m.cloudiness = math.Max(0, math.Min(*config.Cloudiness, 1))
+	}
+	m.rated = pvIrradiance * m.area * m.efficiency
+	m.cloud = m.cloudiness
+	return m
+}
+
+// solarHour 返回 t 时刻的太阳时，单位为小时。未配置经度时以本地时间代替，忽略时差方程。
+func (m *PVModel) solarHour(t time.Time) float64 {
+	if m.longitude != nil {
+		t = t.UTC().Add(time.Duration(*m.longitude / 15 * float64(time.Hour)))
+	}
+	return float64(t.Hour()) + float64(t.Minute())/60 + float64(t.Second())/3600
+}
+
+// ClearSky 返回 t 时刻晴空下水平面的总辐照度，单位为瓦每平方米。太阳在地平线以下时为 0。
+// 太阳天顶角由赤纬和时角计算，辐照度采用 Haurwitz 晴空模型。
+func (m *PVModel) ClearSky(t time.Time) float64 {
+	rad := math.Pi / 180
+	declination := 23.45 * math.Sin(2*math.Pi*float64(284+t.YearDay())/365)
+	hourAngle := 15 * (m.solarHour(t) - 12)
+	cosZenith := math.Sin(m.latitude*rad)*math.Sin(declination*rad) +
+		math.Cos(m.latitude*rad)*math.Cos(declination*rad)*math.Cos(hourAngle*rad)
+	if cosZenith <= 0 {
+		return 0
+	}
+	return 1098 * cosZenith * math.Exp(-0.057/cosZenith)
+}
+
+func (m *PVModel) step(t time.Time, dt float64) {
+	m.cloud += (m.cloudiness-m.cloud)*dt/pvCloudTau + pvCloudSigma*math.Sqrt(dt)*m.rng.NormFloat64()
+	m.cloud = math.Max(0, math.Min(m.cloud, 1))
+	// 云量对辐照度的衰减采用 Kasten-Czeplak 经验公式。
+	clearness := 1 - 0.75*math.Pow(m.cloud, 3.4)
+	m.power = capPower(m.ClearSky(t)*clearness*m.area*m.efficiency, m.limit)
+}
+
+func (m *PVModel) Power(now time.Time) float64 {
+	advance(&m.last, now, m.step)
+	if m.power == 0 {
+		return 0
+	}
+	return -m.power
+}
+
+func (m *PVModel) Command(value int) int {
+	// 负数不限制功率。
+	m.limit = float64(value)
+	return int(capPower(m.rated, m.limit))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// RefrigeratorModel 表示冰箱：柜内温度按一阶模型向环境温度回升，超过设定值加回差时压缩机启动制冷，
+// 降到设定值减回差时停止，因此功率呈周期性的方波。command-power 命令为 0 时关闭冰箱，否则开启。
+type RefrigeratorModel struct {
+	compressor  float64 // 压缩机运行时的功率，单位为瓦。
+	idle        float64 // 压缩机停止时的功率（控制电路等），单位为瓦。
+	setpoint    float64 // 柜内温度设定值，单位为摄氏度。
+	ambient     float64 // 环境温度，单位为摄氏度。
+	hysteresis  float64 // 回差，单位为摄氏度。
+	temperature float64 // 柜内温度，单位为摄氏度。
+	running     bool    // 压缩机是否运行。
+	on          bool
+	last        time.Time
+}
+
+const (
+	refrigeratorLeakTau  = 7200.0 // 柜内温度回升的时间常数，单位为秒。
+	refrigeratorCoolRate = 0.0075 // 压缩机运行时的降温速率，单位为摄氏度每秒。
+)
+
+func NewRefrigeratorModel(config ConfigModel, rng *rand.Rand, now time.Time) *RefrigeratorModel {
+	m := &RefrigeratorModel{
+		compressor: orDefault(config.Power, 120),
+		idle:       2,
+		setpoint:   orDefault(config.Setpoint, 4),
+		ambient:    22,
+		hysteresis: 1.5,
+		on:         true,
+		last:       now,
+	}
+	if config.Ambient != nil {
+		m.ambient = *config.Ambient
+	}
+	// 从回差范围内的随机温度开始，使多台冰箱的周期错开。
+	m.temperature = m.setpoint - m.hysteresis + rng.Float64()*2*m.hysteresis
+	m.running = rng.Intn(2) == 0
+	return m
+}
+
+func (m *RefrigeratorModel) Power(now time.Time) float64 {
+	advance(&m.last, now, func(t time.Time, dt float64) {
+		m.temperature += (m.ambient - m.temperature) / refrigeratorLeakTau * dt
+		if m.running {
+			m.temperature -= refrigeratorCoolRate * dt
+		}
+		switch {
+		case !m.on:
+			m.running = false
+		case m.temperature >= m.setpoint+m.hysteresis:
+			m.running = true
+		case m.temperature <= m.setpoint-m.hysteresis:
+			m.running = false
+		}
+	})
+	if !m.on {
+		return 0
+	}
+	if m.running {
+		return m.compressor
+	}
+	return m.idle
+}
+
+func (m *RefrigeratorModel) Command(value int) int {
+	m.on = value != 0
+	if !m.on {
+		return 0
+	}
+	return int(m.compressor)
+}
@@ -0,0 +1,28 @@
+package main
+
+import "time"
+
+// StandbyModel 表示始终通电的待机负载，例如路由器、机顶盒。command-power 命令为 0 时断电，否则恢复待机。
+type StandbyModel struct {
+	power float64
+	on    bool
+}
+
+func NewStandbyModel(config ConfigModel) *StandbyModel {
+	return &StandbyModel{power: orDefault(config.Power, 8), on: true}
+}
+
+func (m *StandbyModel) Power(now time.Time) float64 {
+	if !m.on {
+		return 0
+	}
+	return m.power
+}
+
+func (m *StandbyModel) Command(value int) int {
+	m.on = value != 0
+	if !m.on {
+		return 0
+	}
+	return int(m.power)
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vistart/project20240227/server/common"
+)
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+// run 从 from 起每隔 step 计算一次功率，直到 to（不含），返回每次的功率。
+func run(a *Appliance, from, to time.Time, step time.Duration) []float64 {
+	var powers []float64
+	for t := from; t.Before(to); t = t.Add(step) {
+		powers = append(powers, a.Power(t))
+	}
+	return powers
+}
+
+func newTestRand() *rand.Rand {
+	return rand.New(rand.NewSource(1))
+}
+
+func newTestAppliance(t *testing.T, config ConfigModel, now time.Time) *Appliance {
+	if config.Seed == 0 {
+		config.Seed = 1
+	}
+	a, err := NewAppliance(config, "test", 100, now)
+	assert.Nil(t, err)
+	return a
+}
+
+// TestNewAppliance 测试按配置实例化各种模型，以及各模型支持的命令。
+func TestNewAppliance(t *testing.T) {
+	now := time.Date(2024, 6, 20, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		model    string
+		setpoint bool
+		thermal  bool
+		storage  bool
+	}{
+		{"", false, false, false},
+		{ModelConstant, false, false, false},
+		{ModelRefrigerator, false, false, false},
+		{ModelWasher, false, false, false},
+		{ModelHeater, true, true, false},
+		{ModelStandby, false, false, false},
+		{ModelLighting, false, false, false},
+		{ModelHVAC, true, true, false},
+		{ModelPV, false, false, false},
+		{ModelBattery, false, false, true},
+	}
+	for _, tt := range tests {
+		a, err := NewAppliance(ConfigModel{Type: tt.model}, "test", 100, now)
+		if !assert.Nil(t, err, tt.model) {
+			continue
+		}
+		_, err = a.Setpoint(22)
+		if tt.setpoint {
+			assert.Nil(t, err, tt.model)
+		} else {
+			assert.ErrorIs(t, err, ErrSetpointNotSupported, tt.model)
+		}
+		assert.Equal(t, tt.thermal, a.Temperature() != nil, tt.model)
+		_, err = a.Battery(common.BatteryModeCharge, 1000)
+		if tt.storage {
+			assert.Nil(t, err, tt.model)
+		} else {
+			assert.ErrorIs(t, err, ErrBatteryNotSupported, tt.model)
+		}
+		assert.Equal(t, tt.storage, a.SoC() != nil, tt.model)
+	}
+
+	_, err := NewAppliance(ConfigModel{Type: "unknown"}, "test", 100, now)
+	assert.NotNil(t, err)
+}
+
+// TestAppliance_Seed 测试相同的种子得到相同的功率曲线，不同的种子得到不同的功率曲线。
+// 未指定种子时以客户端编号生成种子。
+func TestAppliance_Seed(t *testing.T) {
+	from := time.Date(2024, 6, 20, 18, 0, 0, 0, time.UTC)
+	to := from.Add(2 * time.Hour)
+	config := ConfigModel{Type: ModelLighting, Noise: 0.05, Seed: 42}
+
+	a := run(newTestAppliance(t, config, from), from, to, time.Minute)
+	b := run(newTestAppliance(t, config, from), from, to, time.Minute)
+	assert.Equal(t, a, b)
+	config.Seed = 43
+	assert.NotEqual(t, a, run(newTestAppliance(t, config, from), from, to, time.Minute))
+
+	config.Seed = 0
+	c, _ := NewAppliance(config, "client-1", 100, from)
+	d, _ := NewAppliance(config, "client-1", 100, from)
+	e, _ := NewAppliance(config, "client-2", 100, from)
+	assert.Equal(t, run(c, from, to, time.Minute), run(d, from, to, time.Minute))
+	assert.NotEqual(t, run(c, from, to, time.Minute), run(e, from, to, time.Minute))
+}
+
+// TestAppliance_Noise 测试噪声不改变功率的正负，设备关闭时不叠加噪声。
+func TestAppliance_Noise(t *testing.T) {
+	from := time.Date(2024, 6, 20, 10, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	pv := newTestAppliance(t, ConfigModel{Type: ModelPV, Noise: 2}, from)
+	for _, power := range run(pv, from, to, time.Second) {
+		assert.LessOrEqual(t, power, 0.0)
+	}
+
+	constant := newTestAppliance(t, ConfigModel{Noise: 0.1}, from)
+	powers := run(constant, from, from.Add(10*time.Minute), time.Second)
+	var sum float64
+	for _, power := range powers {
+		sum += power
+	}
+	mean := sum / float64(len(powers))
+	assert.InDelta(t, 100, mean, 2)
+	assert.NotEqual(t, powers[0], powers[1])
+
+	assert.Equal(t, 0, constant.Command(0))
+	assert.Equal(t, 0.0, constant.Power(to))
+}
+
+// TestConstantModel 测试固定功率模型的命令。
+func TestConstantModel(t *testing.T) {
+	m := NewConstantModel(100)
+	now := time.Now()
+	tests := []struct {
+		command int
+		applied int
+	}{
+		{300, 300},
+		{0, 0},
+		{common.CommandPowerUnlimited, 100},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.applied, m.Command(tt.command))
+		assert.Equal(t, float64(tt.applied), m.Power(now))
+	}
+}
+
+// TestRefrigeratorModel 测试冰箱的压缩机按温度启停，运行时间占比接近漏热与制冷速率之比。
+func TestRefrigeratorModel(t *testing.T) {
+	from := time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)
+	a := newTestAppliance(t, ConfigModel{Type: ModelRefrigerator}, from)
+	powers := run(a, from, from.Add(24*time.Hour), 10*time.Second)
+
+	var running, switches int
+	for i, power := range powers {
+		assert.Contains(t, []float64{2, 120}, power)
+		if power == 120 {
+			running++
+		}
+		if i > 0 && power != powers[i-1] {
+			switches++
+		}
+	}
+	// 柜内温度在 4 度附近时，回升速率约为 (22 - 4) / 7200 = 0.0025 度每秒，制冷速率为 0.0075 度每秒。
+	assert.InDelta(t, 1.0/3, float64(running)/float64(len(powers)), 0.05)
+	assert.Greater(t, switches, 20)
+
+	to := from.Add(24 * time.Hour)
+	assert.Equal(t, 0, a.Command(0))
+	assert.Equal(t, 0.0, a.Power(to.Add(time.Minute)))
+	assert.Equal(t, 120, a.Command(common.CommandPowerUnlimited))
+	assert.Contains(t, []float64{2, 120}, a.Power(to.Add(2*time.Minute)))
+}
+
+// TestWasherModel 测试洗衣程序各阶段的功率，以及停止、开始和恢复自动运行的命令。
+func TestWasherModel(t *testing.T) {
+	from := time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)
+	m := NewWasherModel(ConfigModel{Power: 2000, Interval: 4 * 3600}, newTestRand(), from)
+	assert.Equal(t, 0, m.Command(0))
+	assert.Equal(t, 0.0, m.Power(from))
+
+	// 立即开始程序。
+	assert.Equal(t, 2000, m.Command(2000))
+	tests := []struct {
+		elapsed time.Duration
+		phase   string
+		min     float64
+		max     float64
+	}{
+		{time.Minute, "fill", 30, 30},
+		{10 * time.Minute, "heat", 2000, 2000},
+		{30 * time.Minute, "wash", 150, 250},
+		{50 * time.Minute, "rinse", 120, 120},
+		{62 * time.Minute, "spin", 200, 500},
+		{80 * time.Minute, "standby", 2, 2},
+	}
+	for _, tt := range tests {
+		power := m.Power(from.Add(tt.elapsed))
+		assert.GreaterOrEqual(t, power, tt.min, tt.phase)
+		assert.LessOrEqual(t, power, tt.max, tt.phase)
+	}
+
+	// 下一次程序在间隔之后自动开始。
+	assert.Equal(t, 30.0, m.Power(from.Add(4*time.Hour+time.Minute)))
+
+	// 停止后不再自动运行；恢复自动运行后不立即开始程序。
+	now := from.Add(4*time.Hour + 2*time.Minute)
+	m.Power(now)
+	assert.Equal(t, 0, m.Command(0))
+	assert.Equal(t, 0.0, m.Power(now.Add(8*time.Hour)))
+	assert.Equal(t, 2000, m.Command(common.CommandPowerUnlimited))
+	assert.Equal(t, 2.0, m.Power(now.Add(9*time.Hour)))
+	assert.Equal(t, 30.0, m.Power(now.Add(12*time.Hour+time.Minute)))
+}
+
+// TestHeaterModel 测试温控器使房间温度保持在设定值附近，以及功率上限和设定温度的命令。
+func TestHeaterModel(t *testing.T) {
+	from := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	a := newTestAppliance(t, ConfigModel{Type: ModelHeater, Power: 2000, Setpoint: 21, Ambient: float64Ptr(5)}, from)
+	for i, power := range run(a, from, from.Add(6*time.Hour), time.Minute) {
+		assert.Contains(t, []float64{0, 2000}, power)
+		if i > 0 {
+			assert.InDelta(t, 21, *a.Temperature(), 0.6)
+		}
+	}
+
+	now := from.Add(6 * time.Hour)
+	assert.Equal(t, 1000, a.Command(1000))
+	for _, power := range run(a, now, now.Add(time.Hour), time.Minute) {
+		assert.LessOrEqual(t, power, 1000.0)
+	}
+	now = now.Add(time.Hour)
+	assert.Equal(t, 2000, a.Command(common.CommandPowerUnlimited))
+	setpoint, err := a.Setpoint(18)
+	assert.Nil(t, err)
+	assert.Equal(t, 18.0, setpoint)
+	run(a, now, now.Add(6*time.Hour), time.Minute)
+	assert.InDelta(t, 18, *a.Temperature(), 0.6)
+
+	// 关闭后房间温度向室外温度回落。
+	now = now.Add(6 * time.Hour)
+	assert.Equal(t, 0, a.Command(0))
+	run(a, now, now.Add(2*time.Hour), time.Minute)
+	assert.Less(t, *a.Temperature(), 17.0)
+}
+
+// TestLightingModel 测试傍晚开灯、深夜关灯，以及调光命令。
+func TestLightingModel(t *testing.T) {
+	evening := time.Date(2024, 6, 20, 18, 0, 0, 0, time.UTC)
+	a := newTestAppliance(t, ConfigModel{Type: ModelLighting, Power: 300, Lights: 5}, evening)
+	var lit int
+	for _, power := range run(a, evening, evening.Add(4*time.Hour), time.Minute) {
+		assert.Equal(t, 0.0, math.Mod(power, 60))
+		if power > 0 {
+			lit++
+		}
+	}
+	assert.Greater(t, lit, 120)
+
+	now := evening.Add(4 * time.Hour)
+	assert.Equal(t, 150, a.Command(150))
+	for _, power := range run(a, now, now.Add(10*time.Minute), time.Minute) {
+		assert.LessOrEqual(t, power, 150.0)
+	}
+	assert.Equal(t, 0, a.Command(0))
+	assert.Equal(t, 0.0, a.Power(now.Add(11*time.Minute)))
+	assert.Equal(t, 300, a.Command(common.CommandPowerUnlimited))
+
+	// 深夜大部分灯熄灭。
+	night := time.Date(2024, 6, 21, 1, 0, 0, 0, time.UTC)
+	run(a, now.Add(12*time.Minute), night, time.Minute)
+	var sum float64
+	powers := run(a, night, night.Add(4*time.Hour), time.Minute)
+	for _, power := range powers {
+		sum += power
+	}
+	assert.Less(t, sum/float64(len(powers)), 60.0)
+}
+
+// TestHVACModel 测试空调使室内温度保持在设定值附近，以及能效比随室外温度的变化。
+func TestHVACModel(t *testing.T) {
+	m := NewHVACModel(ConfigModel{COP: 3.5}, newTestRand(), time.Time{})
+	tests := []struct {
+		outdoor float64
+		heating bool
+		cop     float64
+	}{
+		{hvacHeatRating, true, 3.5},
+		{hvacHeatRating - 10, true, 3.5 * 0.75},
+		{hvacHeatRating + 10, true, 3.5 * 1.25},
+		{hvacCoolRating, false, 3.5},
+		{hvacCoolRating + 10, false, 3.5 * 0.75},
+		{-100, true, 1},
+		{100, true, 7},
+	}
+	for _, tt := range tests {
+		assert.InDelta(t, tt.cop, m.COP(tt.outdoor, tt.heating), 1e-9)
+	}
+
+	from := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	a := newTestAppliance(t, ConfigModel{Type: ModelHVAC, Power: 1500, Setpoint: 22, Ambient: float64Ptr(5)}, from)
+	for i, power := range run(a, from, from.Add(12*time.Hour), time.Minute) {
+		assert.GreaterOrEqual(t, power, 0.0)
+		assert.LessOrEqual(t, power, 1500.0)
+		if i > 60 {
+			assert.InDelta(t, 22, *a.Temperature(), 1)
+		}
+	}
+
+	now := from.Add(12 * time.Hour)
+	setpoint, err := a.Setpoint(25)
+	assert.Nil(t, err)
+	assert.Equal(t, 25.0, setpoint)
+	run(a, now, now.Add(6*time.Hour), time.Minute)
+	assert.InDelta(t, 25, *a.Temperature(), 1)
+
+	now = now.Add(6 * time.Hour)
+	assert.Equal(t, 0, a.Command(0))
+	run(a, now, now.Add(time.Hour), time.Minute)
+	assert.Equal(t, 0.0, a.Power(now.Add(time.Hour)))
+	assert.Less(t, *a.Temperature(), 24.0)
+	assert.Equal(t, 1500, a.Command(common.CommandPowerUnlimited))
+}
+
+// TestPVModel_ClearSky 测试晴空辐照度随季节和时刻的变化。
+func TestPVModel_ClearSky(t *testing.T) {
+	m := NewPVModel(ConfigModel{Latitude: float64Ptr(31.2)}, newTestRand(), time.Time{})
+	summer := time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)
+	winter := time.Date(2024, 12, 20, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		at         time.Time
+		irradiance float64
+	}{
+		{summer, 0},
+		{summer.Add(12 * time.Hour), 1027.15},
+		{summer.Add(10 * time.Hour), 911.92},
+		{summer.Add(14 * time.Hour), 911.92},
+		{winter.Add(12 * time.Hour), 575.67},
+		{winter.Add(20 * time.Hour), 0},
+	}
+	for _, tt := range tests {
+		assert.InDelta(t, tt.irradiance, m.ClearSky(tt.at), 0.01, tt.at.String())
+	}
+
+	// 配置经度时以太阳时计算：东经 120 度的 UTC 4 时为太阳时 12 时。
+	m = NewPVModel(ConfigModel{Latitude: float64Ptr(31.2), Longitude: float64Ptr(120)}, newTestRand(), time.Time{})
+	assert.InDelta(t, 1027.15, m.ClearSky(summer.Add(4*time.Hour)), 0.01)
+}
+
+// TestPVModel 测试发电功率为负数，夜间为 0，以及限发命令。
+func TestPVModel(t *testing.T) {
+	from := time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)
+	a := newTestAppliance(t, ConfigModel{Type: ModelPV, Latitude: float64Ptr(31.2), Area: 10, Efficiency: 0.2, Cloudiness: float64Ptr(0)}, from)
+	powers := run(a, from, from.Add(24*time.Hour), time.Minute)
+	assert.Equal(t, 0.0, powers[0])
+	assert.Equal(t, 0.0, powers[23*60])
+	// 晴天正午接近晴空辐照度下的发电功率 1027 * 10 * 0.2。
+	assert.InDelta(t, -2054, powers[12*60], 150)
+	for _, power := range powers {
+		assert.LessOrEqual(t, power, 0.0)
+	}
+
+	noon := from.Add(36 * time.Hour)
+	run(a, from.Add(24*time.Hour), noon, time.Minute)
+	assert.Equal(t, 500, a.Command(500))
+	assert.Equal(t, -500.0, a.Power(noon.Add(time.Minute)))
+	assert.Equal(t, 0, a.Command(0))
+	assert.Equal(t, 0.0, a.Power(noon.Add(2*time.Minute)))
+	assert.Equal(t, 2000, a.Command(common.CommandPowerUnlimited))
+	assert.Less(t, a.Power(noon.Add(3*time.Minute)), -1500.0)
+}
+
+// TestBatteryModel 测试充放电的效率损耗、充满和放空后停止，以及命令设定的功率上限。
+func TestBatteryModel(t *testing.T) {
+	from := time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)
+	// 往返效率 0.81，充电和放电的效率各为 0.9。
+	config := ConfigModel{Type: ModelBattery, Capacity: 1000, ChargePower: 1000, DischargePower: 1000, Efficiency: 0.81, SoC: float64Ptr(50)}
+	a := newTestAppliance(t, config, from)
+	assert.Equal(t, 50.0, *a.SoC())
+
+	applied, err := a.Battery(common.BatteryModeCharge, 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, 1000, applied)
+	run(a, from, from.Add(30*time.Minute), time.Minute)
+	assert.Equal(t, 1000.0, a.Power(from.Add(30*time.Minute)))
+	assert.InDelta(t, 95, *a.SoC(), 1e-9)
+
+	// 充满后功率降为 0。
+	now := from.Add(30 * time.Minute)
+	run(a, now, now.Add(10*time.Minute), time.Minute)
+	assert.Equal(t, 0.0, a.Power(now.Add(10*time.Minute)))
+	assert.InDelta(t, 100, *a.SoC(), 1e-9)
+
+	// 放电 36 分钟：1000 / 0.9 * 0.6 = 666.7 瓦时。
+	now = now.Add(10 * time.Minute)
+	applied, _ = a.Battery(common.BatteryModeDischarge, 2000)
+	assert.Equal(t, 1000, applied)
+	run(a, now, now.Add(36*time.Minute), time.Minute)
+	assert.Equal(t, -1000.0, a.Power(now.Add(36*time.Minute)))
+	assert.InDelta(t, 100-1000/0.9*0.6/10, *a.SoC(), 1e-9)
+
+	// 放空后功率降为 0。
+	now = now.Add(36 * time.Minute)
+	run(a, now, now.Add(30*time.Minute), time.Minute)
+	assert.Equal(t, 0.0, a.Power(now.Add(30*time.Minute)))
+	assert.InDelta(t, 0, *a.SoC(), 1e-9)
+
+	// 功率上限。
+	now = now.Add(30 * time.Minute)
+	assert.Equal(t, 500, a.Command(500))
+	applied, _ = a.Battery(common.BatteryModeCharge, 800)
+	assert.Equal(t, 500, applied)
+	assert.Equal(t, 500.0, a.Power(now.Add(time.Minute)))
+	assert.Equal(t, 1000, a.Command(common.CommandPowerUnlimited))
+	assert.Equal(t, 800.0, a.Power(now.Add(2*time.Minute)))
+	applied, _ = a.Battery(common.BatteryModeIdle, 800)
+	assert.Equal(t, 0, applied)
+	assert.Equal(t, 0.0, a.Power(now.Add(3*time.Minute)))
+
+	// 初始荷电状态限制在 0 到 100 之间。
+	for _, tt := range []struct{ soc, expected float64 }{{150, 100}, {-5, 0}} {
+		config.SoC = float64Ptr(tt.soc)
+		assert.Equal(t, tt.expected, *newTestAppliance(t, config, from).SoC())
+	}
+}
+
+// TestAdvance 测试以不超过 1 秒的步长推进模型，单次最多推进一小时。
+func TestAdvance(t *testing.T) {
+	var last time.Time
+	from := time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)
+	var steps int
+	var total float64
+	step := func(t time.Time, dt float64) {
+		steps++
+		total += dt
+	}
+	advance(&last, from, step)
+	assert.Equal(t, 0, steps)
+	assert.Equal(t, from, last)
+
+	advance(&last, from.Add(2500*time.Millisecond), step)
+	assert.Equal(t, 3, steps)
+	assert.InDelta(t, 2.5, total, 1e-9)
+
+	advance(&last, from, step)
+	assert.Equal(t, 3, steps)
+
+	steps, total = 0, 0
+	advance(&last, from.Add(3*time.Hour), step)
+	assert.Equal(t, 3600, steps)
+	assert.InDelta(t, 3600, total, 1e-9)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// washerPhase 表示洗衣程序的一个阶段。power 为该阶段开始后 elapsed 秒时的功率与额定功率之比。
+type washerPhase struct {
+	name     string
+	duration time.Duration
+	power    func(elapsed float64) float64
+}
+
+// washerProgram 表示洗衣程序：进水、加热、洗涤、漂洗、脱水。加热阶段以额定功率运行，其余阶段为电机功率。
+var washerProgram = []washerPhase{
+	{"fill", 2 * time.Minute, func(float64) float64 { return 0.015 }},
+	{"heat", 15 * time.Minute, func(float64) float64 { return 1 }},
+	{"wash", 30 * time.Minute, func(elapsed float64) float64 {
+		// 滚筒正反转交替，每 30 秒一个周期。
+		return 0.075 + 0.05*math.Abs(math.Sin(elapsed*math.Pi/30))
+	}},
+	{"rinse", 15 * time.Minute, func(float64) float64 { return 0.06 }},
+	{"spin", 10 * time.Minute, func(elapsed float64) float64 {
+		// 转速在前 2 分钟内逐渐升高。
+		return 0.1 + 0.15*math.Min(elapsed/120, 1)
+	}},
+}
+
+// WasherModel 表示洗衣机：每隔 interval 运行一次洗衣程序，程序之外为待机功率。
+// command-power 命令为 0 时停止程序并不再自动运行，为负数时恢复自动运行，否则在空闲时立即开始程序并恢复自动运行。
+type WasherModel struct {
+	power    float64       // 额定功率（加热功率），单位为瓦。
+	standby  float64       // 待机功率，单位为瓦。
+	interval time.Duration // 两次程序开始之间的间隔。
+	on       bool
+	started  time.Time // 当前程序的开始时刻。程序未运行时为零值。
+	next     time.Time // 下一次程序的开始时刻。
+	last     time.Time // 最近一次计算功率的时刻。
+}
+
+func NewWasherModel(config ConfigModel, rng *rand.Rand, now time.Time) *WasherModel {
+	m := &WasherModel{
+		power:    orDefault(config.Power, 2000),
+		standby:  2,
+		interval: time.Duration(orDefault(float64(config.Interval), 4*3600)) * time.Second,
+		on:       true,
+	}
+	// 第一次程序在一个间隔内的随机时刻开始。
+	m.next = now.Add(time.Duration(rng.Int63n(int64(m.interval))))
+	return m
+}
+
+// phase 返回 now 时刻所处的阶段及已进行的秒数。程序未运行或已结束时返回 nil。
+func (m *WasherModel) phase(now time.Time) (*washerPhase, float64) {
+	if m.started.IsZero() {
+		return nil, 0
+	}
+	elapsed := now.Sub(m.started)
+	for i := range washerProgram {
+		if elapsed < washerProgram[i].duration {
+			return &washerProgram[i], elapsed.Seconds()
+		}
+		elapsed -= washerProgram[i].duration
+	}
+	return nil, 0
+}
+
+func (m *WasherModel) Power(now time.Time) float64 {
+	m.last = now
+	if !m.on {
+		return 0
+	}
+	if m.started.IsZero() && !now.Before(m.next) {
+		m.started = m.next
+		m.next = m.next.Add(m.interval)
+	}
+	phase, elapsed := m.phase(now)
+	if phase == nil {
+		m.started = time.Time{}
+		return m.standby
+	}
+	return m.power * phase.power(elapsed)
+}
+
+func (m *WasherModel) Command(value int) int {
+	if value == 0 {
+		m.on = false
+		m.started = time.Time{}
+		return 0
+	}
+	now := m.last
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if value < 0 {
+		// 恢复自动运行，不立即开始程序。停止期间错过的程序在一个间隔后开始。
+		if !m.on {
+			m.on = true
+			m.next = now.Add(m.interval)
+		}
+		return int(m.power)
+	}
+	if !m.on || m.started.IsZero() {
+		m.started = now
+		m.next = now.Add(m.interval)
+	}
+	m.on = true
+	return int(m.power)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ReportSample 表示一次功率报告。
+type ReportSample struct {
+	Consumption float64  `json:"consumption"`           // 单位为瓦。
+	Temperature *float64 `json:"temperature,omitempty"` // 室内温度，单位为摄氏度。设备不模拟室内温度时为空。
+	SoC         *float64 `json:"soc,omitempty"`         // 荷电状态，单位为百分比。设备不是储能设备时为空。
+	RecordedAt  int64    `json:"recorded_at"`           // Unix 时间戳，单位为秒。
+}
+
+// ReportQueue 是保存在磁盘上的有界队列，保存未能报告的功率，待服务端可达后批量补报。
+// 文件每行一条 JSON 格式的记录。新记录追加到文件末尾；超出容量时丢弃最早的记录，
+// 文件中已丢弃或已补报的记录积累到一定数量后再重写文件，以免频繁重写。
+// 重写之前，文件开头已补报的行数保存在加 .head 后缀的文件中，重启后跳过这些行，以免重复补报。
+type ReportQueue struct {
+	path      string
+	size      int
+	samples   []ReportSample // 按记录时间正序排列。
+	lines     []int          // 每条记录在文件中的行号，从 0 开始。
+	fileLines int            // 文件中的行数，包括已丢弃或已补报的记录。
+	mu        sync.Mutex
+}
+
+// NewReportQueue 打开保存在 path 的队列，最多保存 size 条记录。文件不存在时视为空队列。
+func NewReportQueue(path string, size int) (*ReportQueue, error) {
+	q := &ReportQueue{path: path, size: size}
+	head, err := q.readHead()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := q.fileLines
+		q.fileLines++
+		if line < head {
+			continue // 已补报的记录。
+		}
+		var sample ReportSample
+		if err := json.Unmarshal(scanner.Bytes(), &sample); err != nil {
+			continue // 忽略写入中断造成的不完整记录。
+		}
+		q.samples = append(q.samples, sample)
+		q.lines = append(q.lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	q.truncate()
+	return q, nil
+}
+
+// headPath 返回保存已补报行数的文件。
+func (q *ReportQueue) headPath() string {
+	return q.path + ".head"
+}
+
+// readHead 读取文件开头已补报的行数。文件不存在或内容不完整时为 0。
+func (q *ReportQueue) readHead() (int, error) {
+	content, err := os.ReadFile(q.headPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	head, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0, nil
+	}
+	return head, nil
+}
+
+// writeHead 保存文件开头已补报的行数。先写入临时文件再替换，以免写入中断时内容不完整。
+func (q *ReportQueue) writeHead(head int) error {
+	temp := q.headPath() + ".tmp"
+	if err := os.WriteFile(temp, []byte(strconv.Itoa(head)), 0600); err != nil {
+		return err
+	}
+	return os.Rename(temp, q.headPath())
+}
+
+// truncate 超出容量时丢弃最早的记录。调用前须持有锁。
+func (q *ReportQueue) truncate() {
+	if len(q.samples) > q.size {
+		q.samples = q.samples[len(q.samples)-q.size:]
+		q.lines = q.lines[len(q.lines)-q.size:]
+	}
+}
+
+// Len 返回队列中的记录数。
+func (q *ReportQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.samples)
+}
+
+// Push 将记录加入队列末尾。超出容量时丢弃最早的记录。
+func (q *ReportQueue) Push(sample ReportSample) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.samples = append(q.samples, sample)
+	q.lines = append(q.lines, q.fileLines)
+	q.truncate()
+	if q.fileLines-len(q.samples) > q.size/10 {
+		return q.rewrite()
+	}
+	line, err := json.Marshal(sample)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(q.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	q.fileLines++
+	return nil
+}
+
+// Peek 返回队列开头最多 n 条记录。
+func (q *ReportQueue) Peek(n int) []ReportSample {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if n > len(q.samples) {
+		n = len(q.samples)
+	}
+	samples := make([]ReportSample, n)
+	copy(samples, q.samples[:n])
+	return samples
+}
+
+// Remove 从队列开头删除 n 条记录，通常在这些记录补报成功后调用。
+// 不重写文件时保存已补报的行数，重启后不再补报这些记录。
+func (q *ReportQueue) Remove(n int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if n > len(q.samples) {
+		n = len(q.samples)
+	}
+	q.samples = q.samples[n:]
+	q.lines = q.lines[n:]
+	if len(q.samples) == 0 || q.fileLines-len(q.samples) > q.size/10 {
+		return q.rewrite()
+	}
+	return q.writeHead(q.lines[0])
+}
+
+// rewrite 以队列中的记录重写文件。队列为空时删除文件。调用前须持有锁。
+func (q *ReportQueue) rewrite() error {
+	// 先删除已补报的行数，再替换文件：即使中断，也只会重复补报，而不会跳过未补报的记录。
+	if err := os.Remove(q.headPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(q.samples) == 0 {
+		q.fileLines = 0
+		if err := os.Remove(q.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	temp := q.path + ".tmp"
+	file, err := os.OpenFile(temp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for _, sample := range q.samples {
+		line, err := json.Marshal(sample)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		writer.Write(append(line, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	// 先写入临时文件再替换，以免写入中断时丢失整个队列。
+	if err := os.Rename(temp, q.path); err != nil {
+		return err
+	}
+	q.fileLines = len(q.samples)
+	for i := range q.lines {
+		q.lines[i] = i
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pushSamples(t *testing.T, q *ReportQueue, from, to int64) {
+	for i := from; i < to; i++ {
+		assert.Nil(t, q.Push(ReportSample{Consumption: float64(i), RecordedAt: i}))
+	}
+}
+
+func recordedAt(samples []ReportSample) []int64 {
+	var result []int64
+	for _, sample := range samples {
+		result = append(result, sample.RecordedAt)
+	}
+	return result
+}
+
+func fileLines(t *testing.T, path string) int {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	assert.Nil(t, err)
+	return strings.Count(string(content), "\n")
+}
+
+// TestReportQueue 测试记录的加入和删除，以及重启后从文件恢复队列。
+func TestReportQueue(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		push      int64
+		remove    int
+		expected  []int64
+		fileLines int // 删除后文件中的行数。
+		head      bool
+	}{
+		{"empty", 100, 0, 0, nil, 0, false},
+		{"pending", 100, 5, 0, []int64{0, 1, 2, 3, 4}, 5, false},
+		// 已补报的行数较少时不重写文件，保存已补报的行数。
+		{"head", 100, 5, 2, []int64{2, 3, 4}, 5, true},
+		// 已补报的行数超过容量的十分之一时重写文件。
+		{"rewrite", 100, 15, 12, []int64{12, 13, 14}, 3, false},
+		{"all", 100, 5, 5, nil, 0, false},
+		// 超出容量时丢弃最早的记录。
+		{"capacity", 10, 15, 0, []int64{5, 6, 7, 8, 9, 10, 11, 12, 13, 14}, 12, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "queue")
+			q, err := NewReportQueue(path, tt.size)
+			assert.Nil(t, err)
+			pushSamples(t, q, 0, tt.push)
+			if tt.remove > 0 {
+				assert.Nil(t, q.Remove(tt.remove))
+			}
+			assert.Equal(t, tt.expected, recordedAt(q.Peek(tt.size)))
+			assert.Equal(t, tt.fileLines, fileLines(t, path))
+			_, err = os.Stat(path + ".head")
+			assert.Equal(t, tt.head, err == nil)
+
+			// 重启后恢复相同的队列，并可以继续加入和删除记录。
+			q, err = NewReportQueue(path, tt.size)
+			assert.Nil(t, err)
+			assert.Equal(t, len(tt.expected), q.Len())
+			assert.Equal(t, tt.expected, recordedAt(q.Peek(tt.size)))
+			pushSamples(t, q, 100, 102)
+			assert.Nil(t, q.Remove(1))
+			q, err = NewReportQueue(path, tt.size)
+			assert.Nil(t, err)
+			expected := append(append([]int64{}, tt.expected...), 100, 101)
+			if len(expected) > tt.size {
+				expected = expected[len(expected)-tt.size:]
+			}
+			expected = expected[1:]
+			assert.Equal(t, expected, recordedAt(q.Peek(tt.size)))
+		})
+	}
+}
+
+// TestReportQueue_Corrupt 测试忽略写入中断造成的不完整记录，以及内容不完整的已补报行数。
+func TestReportQueue_Corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue")
+	content := "{\"consumption\":1,\"recorded_at\":1}\n{\"consumption\":2,\"recorded_at\":2}\n{\"consumption\":3,\"rec"
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0600))
+	q, err := NewReportQueue(path, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 2}, recordedAt(q.Peek(10)))
+
+	assert.Nil(t, os.WriteFile(path+".head", []byte("1x"), 0600))
+	q, err = NewReportQueue(path, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 2}, recordedAt(q.Peek(10)))
+}
@@ -0,0 +1,303 @@
+package analytics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/vistart/project20240227/server/models"
+	"gorm.io/gorm"
+)
+
+// CostPeriod 表示某个季节的某个时段内的能耗和费用。
+type CostPeriod struct {
+	Season string  `json:"season"`
+	Period string  `json:"period"`
+	Energy float64 `json:"energy"` // 单位：千瓦时
+	Cost   float64 `json:"cost"`
+}
+
+// Cost 表示一段时间内的能耗和费用。只有用电量按电价计费，发电量单独统计，不计费。
+type Cost struct {
+	Energy     float64      `json:"energy"`     // 用电量，单位：千瓦时
+	Generation float64      `json:"generation"` // 发电量（功率为负数期间），单位：千瓦时
+	Cost       float64      `json:"cost"`       // 单位：电价方案的货币
+	Unpriced   float64      `json:"unpriced"`   // 早于电价第一个版本生效时间、无法计费的能耗，单位：千瓦时
+	Periods    []CostPeriod `json:"periods"`
+}
+
+// Add 将另一段费用累加到当前费用中。
+func (c *Cost) Add(other *Cost) {
+	c.Energy += other.Energy
+	c.Generation += other.Generation
+	c.Cost += other.Cost
+	c.Unpriced += other.Unpriced
+	for _, period := range other.Periods {
+		c.addPeriod(TariffRate{Season: period.Season, Period: period.Period}, period.Energy, period.Cost)
+	}
+}
+
+func (c *Cost) addPeriod(rate TariffRate, energy float64, cost float64) {
+	for i := range c.Periods {
+		if c.Periods[i].Season == rate.Season && c.Periods[i].Period == rate.Period {
+			c.Periods[i].Energy += energy
+			c.Periods[i].Cost += cost
+			return
+		}
+	}
+	c.Periods = append(c.Periods, CostPeriod{Season: rate.Season, Period: rate.Period, Energy: energy, Cost: cost})
+	sort.Slice(c.Periods, func(i, j int) bool {
+		if c.Periods[i].Season != c.Periods[j].Season {
+			return c.Periods[i].Season < c.Periods[j].Season
+		}
+		return c.Periods[i].Period < c.Periods[j].Period
+	})
+}
+
+func NewCost() *Cost {
+	return &Cost{Periods: make([]CostPeriod, 0)}
+}
+
+// splitEnergy 返回功率由 p0 线性变化到 p1、持续 hours 小时的用电量和发电量，单位与功率乘以小时相同。
+// 功率过零时在零点处切分。
+func splitEnergy(p0, p1, hours float64) (consumed, generated float64) {
+	switch {
+	case p0 >= 0 && p1 >= 0:
+		return (p0 + p1) / 2 * hours, 0
+	case p0 <= 0 && p1 <= 0:
+		return 0, -(p0 + p1) / 2 * hours
+	}
+	zero := p0 / (p0 - p1) // 过零时刻占整段时间的比例。
+	first, second := p0/2*hours*zero, p1/2*hours*(1-zero)
+	if p0 > 0 {
+		return first, -second
+	}
+	return second, -first
+}
+
+// IntegrateCost 与 IntegrateEnergy 一样使用梯形法对功率积分，并按每段时间适用的电价计算费用。
+// 相邻两个样本之间的功率按线性插值，在电价可能变化的时刻切分。功率为负数（发电）的部分计入发电量，不计费。
+func IntegrateCost(samples []Sample, online []Interval, schedule *TariffSchedule) *Cost {
+	cost := NewCost()
+	for i := 1; i < len(samples); i++ {
+		prev, curr := samples[i-1], samples[i]
+		if !sameInterval(online, prev.At, curr.At) || !curr.At.After(prev.At) {
+			continue
+		}
+		total := curr.At.Sub(prev.At).Seconds()
+		power := func(t time.Time) float64 {
+			return prev.Power + (curr.Power-prev.Power)*t.Sub(prev.At).Seconds()/total
+		}
+		for start := prev.At; start.Before(curr.At); {
+			end := schedule.NextChange(start)
+			if end.After(curr.At) {
+				end = curr.At
+			}
+			energy, generated := splitEnergy(power(start), power(end), end.Sub(start).Hours()/1000)
+			cost.Energy += energy
+			cost.Generation += generated
+			if rate, ok := schedule.RateAt(start); ok {
+				cost.Cost += energy * rate.Rate
+				cost.addPeriod(rate, energy, energy*rate.Rate)
+			} else {
+				cost.Unpriced += energy
+			}
+			start = end
+		}
+	}
+	return cost
+}
+
+// ClientCost 表示某个客户端在一段时间内的费用。
+type ClientCost struct {
+	ClientID string            `json:"client_id"`
+	Name     string            `json:"name"`
+	Type     models.ClientType `json:"type"`
+	*Cost
+}
+
+// TypeCost 表示某种类型的所有客户端在一段时间内的费用。
+type TypeCost struct {
+	Type models.ClientType `json:"type"`
+	*Cost
+}
+
+// HouseholdCost 表示全屋在一段时间内的费用，包括每个客户端、每种客户端类型的费用。
+// Total 为各客户端用电量及其费用之和；有发电客户端时，实际从电网取用的电量和电费见 Grid。
+type HouseholdCost struct {
+	From     time.Time    `json:"from"`
+	To       time.Time    `json:"to"`
+	Currency string       `json:"currency"`
+	Total    *Cost        `json:"total"`
+	Grid     *GridEnergy  `json:"grid"`
+	Types    []TypeCost   `json:"types"`
+	Clients  []ClientCost `json:"clients"`
+}
+
+// GridEnergy 表示全屋在一段时间内与电网之间的电量交换。能量单位均为千瓦时。
+// 每段时间内发电优先供全屋自用，不足部分从电网取用，剩余部分向电网输出。
+type GridEnergy struct {
+	Load            float64 `json:"load"`             // 用电量
+	Generation      float64 `json:"generation"`       // 发电量
+	Import          float64 `json:"import"`           // 从电网取用的电量
+	Export          float64 `json:"export"`           // 向电网输出的电量
+	SelfConsumption float64 `json:"self_consumption"` // 自用的发电量
+	Cost            *Cost   `json:"cost"`             // 从电网取用的电量按电价计算的费用
+}
+
+// ClientSamples 表示某个客户端的功率样本及其运行区间，用于汇总全屋的电量交换。
+type ClientSamples struct {
+	Samples []Sample
+	Online  []Interval
+}
+
+// averagePower 返回客户端在 [from, to] 内的平均功率，单位为瓦。next 为第一个可能与该区间相交的样本对的序号，
+// 返回时更新为下一个区间的起点，因此按时间顺序依次查询时总计只需遍历一次样本。不在运行区间内的时间功率视为 0。
+func (c *ClientSamples) averagePower(from, to time.Time, next *int) float64 {
+	var energy float64 // 单位：瓦秒
+	i := *next
+	for ; i+1 < len(c.Samples); i++ {
+		prev, curr := c.Samples[i], c.Samples[i+1]
+		if !curr.At.After(from) {
+			continue
+		}
+		if !prev.At.Before(to) {
+			break
+		}
+		if curr.At.After(prev.At) && sameInterval(c.Online, prev.At, curr.At) {
+			total := curr.At.Sub(prev.At).Seconds()
+			power := func(t time.Time) float64 {
+				return prev.Power + (curr.Power-prev.Power)*t.Sub(prev.At).Seconds()/total
+			}
+			start, end := prev.At, curr.At
+			if start.Before(from) {
+				start = from
+			}
+			if end.After(to) {
+				end = to
+			}
+			energy += (power(start) + power(end)) / 2 * end.Sub(start).Seconds()
+		}
+		if curr.At.After(to) {
+			break
+		}
+	}
+	*next = i
+	return energy / to.Sub(from).Seconds()
+}
+
+// IntegrateGrid 计算所有客户端在 [from, to] 内与电网之间的电量交换。[from, to] 在电价可能变化的时刻切分（至多一分钟），
+// 每段时间内以各客户端的平均功率区分用电和发电，再计算从电网取用、向电网输出和自用的电量。
+// 只有从电网取用的电量按电价计费。
+func IntegrateGrid(clients []ClientSamples, schedule *TariffSchedule, from, to time.Time) *GridEnergy {
+	grid := &GridEnergy{Cost: NewCost()}
+	next := make([]int, len(clients))
+	for start := from; start.Before(to); {
+		end := schedule.NextChange(start)
+		if end.After(to) {
+			end = to
+		}
+		var load, generation float64 // 单位：瓦
+		for i := range clients {
+			power := clients[i].averagePower(start, end, &next[i])
+			if power >= 0 {
+				load += power
+			} else {
+				generation -= power
+			}
+		}
+		hours := end.Sub(start).Hours() / 1000
+		grid.Load += load * hours
+		grid.Generation += generation * hours
+		var energy float64 // 从电网取用的电量。
+		if load > generation {
+			energy = (load - generation) * hours
+			grid.SelfConsumption += generation * hours
+		} else {
+			grid.Export += (generation - load) * hours
+			grid.SelfConsumption += load * hours
+		}
+		grid.Import += energy
+		grid.Cost.Energy += energy
+		// 不从电网取用的时段不计入时段细分。
+		if energy > 0 {
+			if rate, ok := schedule.RateAt(start); ok {
+				grid.Cost.Cost += energy * rate.Rate
+				grid.Cost.addPeriod(rate, energy, energy*rate.Rate)
+			} else {
+				grid.Cost.Unpriced += energy
+			}
+		}
+		start = end
+	}
+	return grid
+}
+
+// loadClientSamples 加载客户端在 [from, to] 内的功率样本及其运行区间。
+func loadClientSamples(db *gorm.DB, client *models.Client, from, to time.Time) (*ClientSamples, error) {
+	consumptions, err := client.GetConsumptionsBetween(db, from, to)
+	if err != nil {
+		return nil, err
+	}
+	activities, err := client.GetActivitiesBetween(db, from, to)
+	if err != nil {
+		return nil, err
+	}
+	samples := NewSamples(consumptions)
+	return &ClientSamples{Samples: samples, Online: ActiveIntervals(activities, samples, from, to)}, nil
+}
+
+// CalculateClientCost 计算客户端在 [from, to] 内的费用。
+func CalculateClientCost(db *gorm.DB, client *models.Client, schedule *TariffSchedule, from, to time.Time) (*Cost, error) {
+	samples, err := loadClientSamples(db, client, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return IntegrateCost(samples.Samples, samples.Online, schedule), nil
+}
+
+// CalculateHouseholdCost 计算所有客户端在 [from, to] 内的费用，并按客户端类型及全屋汇总，
+// 同时计算全屋与电网之间的电量交换及实际的电费。
+func CalculateHouseholdCost(db *gorm.DB, schedule *TariffSchedule, from, to time.Time) (*HouseholdCost, error) {
+	clients, _, err := models.GetClients(db, 0, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := &HouseholdCost{
+		From:     from,
+		To:       to,
+		Currency: schedule.Currency,
+		Total:    NewCost(),
+		Types:    make([]TypeCost, 0),
+		Clients:  make([]ClientCost, 0, len(clients)),
+	}
+	types := make(map[models.ClientType]*Cost)
+	all := make([]ClientSamples, 0, len(clients))
+	for i := range clients {
+		client := &clients[i]
+		samples, err := loadClientSamples(db, client, from, to)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, *samples)
+		cost := IntegrateCost(samples.Samples, samples.Online, schedule)
+		result.Clients = append(result.Clients, ClientCost{
+			ClientID: client.ID,
+			Name:     client.Name,
+			Type:     client.Type,
+			Cost:     cost,
+		})
+		if _, existed := types[client.Type]; !existed {
+			types[client.Type] = NewCost()
+		}
+		types[client.Type].Add(cost)
+		result.Total.Add(cost)
+	}
+	result.Grid = IntegrateGrid(all, schedule, from, to)
+	for clientType, cost := range types {
+		result.Types = append(result.Types, TypeCost{Type: clientType, Cost: cost})
+	}
+	sort.Slice(result.Types, func(i, j int) bool {
+		return result.Types[i].Type < result.Types[j].Type
+	})
+	return result, nil
+}
@@ -0,0 +1,187 @@
+package analytics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/vistart/project20240227/server/models"
+	"gorm.io/gorm"
+)
+
+// Sample 表示某一时刻的瞬时功率，单位为瓦。
+type Sample struct {
+	Power float64
+	At    time.Time
+}
+
+// Interval 表示一段时间区间 [Start, End]。
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Contains 检查 t 是否在区间内。
+func (i Interval) Contains(t time.Time) bool {
+	return !t.Before(i.Start) && !t.After(i.End)
+}
+
+// OnlineIntervals 根据活跃记录计算 [from, to] 内的在线区间。
+// activities 需要按时间正序排列，且可以包含 from 之前的最后一条记录，用于确定 from 时刻的状态。
+// 如果 from 时刻的状态无法确定，则以第一条记录的相反状态作为初始状态；没有任何记录时，视为始终在线。
+func OnlineIntervals(activities []models.ClientActivity, from, to time.Time) []Interval {
+	online := true
+	i := 0
+	for ; i < len(activities); i++ {
+		at := activities[i].CreatedAt
+		if at == nil || !at.Before(from) {
+			break
+		}
+		online = activities[i].Status == models.ClientActivityOn
+	}
+	if i == 0 && len(activities) > 0 {
+		online = activities[0].Status != models.ClientActivityOn
+	}
+
+	var intervals []Interval
+	start := from
+	for ; i < len(activities); i++ {
+		at := activities[i].CreatedAt
+		if at == nil || at.After(to) {
+			break
+		}
+		status := activities[i].Status == models.ClientActivityOn
+		if status == online {
+			continue
+		}
+		if online {
+			intervals = append(intervals, Interval{Start: start, End: *at})
+		} else {
+			start = *at
+		}
+		online = status
+	}
+	if online {
+		intervals = append(intervals, Interval{Start: start, End: to})
+	}
+	return intervals
+}
+
+// SampleMaxGap 表示相邻两个样本的最大间隔。间隔不超过该值的样本视为连续报告。
+// 客户端每秒报告一次功率，断开连接期间报告失败的功率之后补报，因此连续的样本说明客户端仍在运行。
+const SampleMaxGap = time.Minute
+
+// SampleIntervals 返回样本连续覆盖的区间：相邻两个样本的间隔不超过 maxGap 时属于同一区间。
+// samples 需要按时间正序排列。只有一个样本的区间不计。
+func SampleIntervals(samples []Sample, maxGap time.Duration) []Interval {
+	var intervals []Interval
+	for i := 1; i < len(samples); i++ {
+		prev, curr := samples[i-1], samples[i]
+		if curr.At.Sub(prev.At) > maxGap {
+			continue
+		}
+		if n := len(intervals); n > 0 && intervals[n-1].End.Equal(prev.At) {
+			intervals[n-1].End = curr.At
+			continue
+		}
+		intervals = append(intervals, Interval{Start: prev.At, End: curr.At})
+	}
+	return intervals
+}
+
+// UnionIntervals 合并两组区间，返回按时间正序排列、互不相交的区间。相接的区间合并为一个。
+func UnionIntervals(a, b []Interval) []Interval {
+	all := make([]Interval, 0, len(a)+len(b))
+	all = append(all, a...)
+	all = append(all, b...)
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Start.Before(all[j].Start)
+	})
+	var intervals []Interval
+	for _, interval := range all {
+		if n := len(intervals); n > 0 && !interval.Start.After(intervals[n-1].End) {
+			if interval.End.After(intervals[n-1].End) {
+				intervals[n-1].End = interval.End
+			}
+			continue
+		}
+		intervals = append(intervals, interval)
+	}
+	return intervals
+}
+
+// ActiveIntervals 返回客户端在 [from, to] 内运行的区间：在线区间，以及样本连续覆盖的区间。
+// 客户端断开连接期间仍然报告或之后补报的功率因此参与积分，不会被视为离线。
+func ActiveIntervals(activities []models.ClientActivity, samples []Sample, from, to time.Time) []Interval {
+	return UnionIntervals(OnlineIntervals(activities, from, to), SampleIntervals(samples, SampleMaxGap))
+}
+
+// IntegrateEnergy 使用梯形法对功率积分，返回能耗，单位为千瓦时。
+// samples 需要按时间正序排列。仅当相邻两个样本处于同一在线区间内时才积分，离线期间不做插值。
+func IntegrateEnergy(samples []Sample, online []Interval) float64 {
+	var energy float64 // 单位：瓦时
+	for i := 1; i < len(samples); i++ {
+		prev, curr := samples[i-1], samples[i]
+		if !sameInterval(online, prev.At, curr.At) {
+			continue
+		}
+		hours := curr.At.Sub(prev.At).Hours()
+		energy += (prev.Power + curr.Power) / 2 * hours
+	}
+	return energy / 1000
+}
+
+func sameInterval(intervals []Interval, a, b time.Time) bool {
+	for _, interval := range intervals {
+		if interval.Contains(a) && interval.Contains(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSamples 将能耗记录转换为样本。
+func NewSamples(consumptions []models.ClientConsumption) []Sample {
+	samples := make([]Sample, 0, len(consumptions))
+	for _, consumption := range consumptions {
+		samples = append(samples, Sample{
+			Power: float64(consumption.Consumption),
+			At:    consumption.RecordedAt,
+		})
+	}
+	return samples
+}
+
+// EnergyResult 表示某个客户端在一段时间内的能耗。
+type EnergyResult struct {
+	ClientID      string    `json:"client_id"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	Energy        float64   `json:"energy"`         // 单位：千瓦时
+	Samples       int       `json:"samples"`        // 参与计算的样本数
+	OnlineSeconds float64   `json:"online_seconds"` // 在线或连续报告功率的时长
+}
+
+// ClientEnergy 计算客户端在 [from, to] 内的能耗。
+func ClientEnergy(db *gorm.DB, client *models.Client, from, to time.Time) (*EnergyResult, error) {
+	consumptions, err := client.GetConsumptionsBetween(db, from, to)
+	if err != nil {
+		return nil, err
+	}
+	activities, err := client.GetActivitiesBetween(db, from, to)
+	if err != nil {
+		return nil, err
+	}
+	samples := NewSamples(consumptions)
+	online := ActiveIntervals(activities, samples, from, to)
+	result := &EnergyResult{
+		ClientID: client.ID,
+		From:     from,
+		To:       to,
+		Energy:   IntegrateEnergy(samples, online),
+		Samples:  len(consumptions),
+	}
+	for _, interval := range online {
+		result.OnlineSeconds += interval.End.Sub(interval.Start).Seconds()
+	}
+	return result, nil
+}
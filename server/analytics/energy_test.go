@@ -0,0 +1,106 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vistart/project20240227/server/models"
+)
+
+func activity(status int8, at time.Time) models.ClientActivity {
+	return models.ClientActivity{Status: status, CreatedAt: &at}
+}
+
+// TestOnlineIntervals 测试根据活跃记录计算在线区间。
+func TestOnlineIntervals(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(10 * time.Hour)
+
+	// 没有任何记录时，视为始终在线。
+	assert.Equal(t, []Interval{{from, to}}, OnlineIntervals(nil, from, to))
+
+	// from 之前在线，期间断开一次。
+	activities := []models.ClientActivity{
+		activity(models.ClientActivityOn, from.Add(-time.Hour)),
+		activity(models.ClientActivityOff, from.Add(2*time.Hour)),
+		activity(models.ClientActivityOn, from.Add(3*time.Hour)),
+	}
+	assert.Equal(t, []Interval{
+		{from, from.Add(2 * time.Hour)},
+		{from.Add(3 * time.Hour), to},
+	}, OnlineIntervals(activities, from, to))
+
+	// from 时刻状态未知，第一条记录为连接，则之前视为离线。
+	activities = []models.ClientActivity{
+		activity(models.ClientActivityOn, from.Add(time.Hour)),
+		activity(models.ClientActivityOff, from.Add(5*time.Hour)),
+	}
+	assert.Equal(t, []Interval{{from.Add(time.Hour), from.Add(5 * time.Hour)}}, OnlineIntervals(activities, from, to))
+}
+
+// TestIntegrateEnergy 测试梯形法积分。离线期间不插值。
+func TestIntegrateEnergy(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(10 * time.Hour)
+
+	samples := []Sample{
+		{Power: 1000, At: from},
+		{Power: 3000, At: from.Add(time.Hour)},     // (1000+3000)/2*1 = 2000Wh
+		{Power: 3000, At: from.Add(2 * time.Hour)}, // 3000Wh
+		{Power: 500, At: from.Add(4 * time.Hour)},  // 离线期间，不计
+		{Power: 500, At: from.Add(5 * time.Hour)},  // 500Wh
+	}
+	online := []Interval{{from, from.Add(2 * time.Hour)}, {from.Add(3 * time.Hour), to}}
+	assert.InDelta(t, 5.5, IntegrateEnergy(samples, online), 1e-9)
+
+	// 始终在线时，离线区间也参与积分：(3000+500)/2*2 = 3500Wh
+	assert.InDelta(t, 9.0, IntegrateEnergy(samples, []Interval{{from, to}}), 1e-9)
+
+	assert.Equal(t, 0.0, IntegrateEnergy(samples[:1], online))
+	assert.Equal(t, 0.0, IntegrateEnergy(nil, online))
+}
+
+// TestActiveIntervals 测试断开连接期间连续报告（或补报）的功率覆盖的区间不视为离线。
+func TestActiveIntervals(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	activities := []models.ClientActivity{
+		activity(models.ClientActivityOn, from.Add(-time.Hour)),
+		activity(models.ClientActivityOff, from.Add(10*time.Minute)),
+		activity(models.ClientActivityOn, from.Add(40*time.Minute)),
+	}
+	// 断开期间每 30 秒一个样本，补报覆盖 10 分至 30 分；30 分至 40 分没有样本。
+	var samples []Sample
+	for at := from.Add(5 * time.Minute); !at.After(from.Add(30 * time.Minute)); at = at.Add(30 * time.Second) {
+		samples = append(samples, Sample{Power: 1200, At: at})
+	}
+	samples = append(samples, Sample{Power: 1200, At: from.Add(45 * time.Minute)})
+
+	assert.Equal(t, []Interval{
+		{from.Add(5 * time.Minute), from.Add(30 * time.Minute)},
+	}, SampleIntervals(samples, SampleMaxGap))
+	online := ActiveIntervals(activities, samples, from, to)
+	assert.Equal(t, []Interval{
+		{from, from.Add(30 * time.Minute)},
+		{from.Add(40 * time.Minute), to},
+	}, online)
+
+	// 5 分至 30 分均计入：1200W * 25 分钟 = 500Wh。30 分至 45 分跨越离线区间，不计。
+	assert.InDelta(t, 0.5, IntegrateEnergy(samples, online), 1e-9)
+	// 仅按在线区间时，断开期间补报的功率被忽略：1200W * 5 分钟 = 100Wh。
+	assert.InDelta(t, 0.1, IntegrateEnergy(samples, OnlineIntervals(activities, from, to)), 1e-9)
+}
+
+// TestUnionIntervals 测试合并区间。
+func TestUnionIntervals(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return from.Add(time.Duration(minutes) * time.Minute)
+	}
+	assert.Equal(t, []Interval{{at(0), at(20)}, {at(30), at(40)}}, UnionIntervals(
+		[]Interval{{at(0), at(10)}, {at(30), at(40)}},
+		[]Interval{{at(10), at(15)}, {at(5), at(20)}, {at(32), at(35)}},
+	))
+	assert.Nil(t, UnionIntervals(nil, nil))
+}
@@ -0,0 +1,111 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// PlanSlot 表示计划中以固定功率运行的一段时间 [Start, End)。
+type PlanSlot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Power int       `json:"power"` // 单位：瓦
+	Rate  float64   `json:"rate"`  // 该时段的平均单价
+}
+
+// Plan 表示可延后负载的运行计划。
+type Plan struct {
+	Slots    []PlanSlot `json:"slots"`    // 按时间正序排列，不在任何时段内时不运行。
+	Energy   float64    `json:"energy"`   // 计划消耗的能量，单位：千瓦时
+	Cost     float64    `json:"cost"`     // 预计费用
+	Feasible bool       `json:"feasible"` // 能否在截止时间前满足所需能量
+}
+
+// PowerAt 返回计划在 t 时刻的功率。不在任何时段内时返回 0。
+func (p *Plan) PowerAt(t time.Time) int {
+	for _, slot := range p.Slots {
+		if !t.Before(slot.Start) && t.Before(slot.End) {
+			return slot.Power
+		}
+	}
+	return 0
+}
+
+// PlanDeferrable 计算在 [now, deadline) 内以不超过 maxPower 瓦的功率消耗 energy 千瓦时的最低费用计划。
+// 时间按 slot 对齐划分为若干时段，每个时段的单价为其中每分钟单价的平均值，按单价从低到高（单价相同时从早到晚）选取时段，
+// 除最后选取的时段按剩余能量降低功率外，其余时段均以最大功率运行。
+// schedule 为 nil 或某一时刻没有电价时，单价视为 0，此时计划尽早运行。
+func PlanDeferrable(now, deadline time.Time, energy float64, maxPower int, schedule *TariffSchedule, slot time.Duration) *Plan {
+	plan := &Plan{Slots: make([]PlanSlot, 0), Feasible: energy <= 0}
+	if energy <= 0 || maxPower <= 0 || !deadline.After(now) {
+		return plan
+	}
+
+	var candidates []PlanSlot
+	for start := now; start.Before(deadline); {
+		end := start.Truncate(slot).Add(slot)
+		if end.After(deadline) {
+			end = deadline
+		}
+		candidates = append(candidates, PlanSlot{Start: start, End: end, Power: maxPower, Rate: averageRate(schedule, start, end)})
+		start = end
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Rate < candidates[j].Rate
+	})
+
+	remaining := energy
+	var chosen []PlanSlot
+	for _, candidate := range candidates {
+		if remaining <= 0 {
+			break
+		}
+		hours := candidate.End.Sub(candidate.Start).Hours()
+		full := float64(maxPower) * hours / 1000
+		if full > remaining {
+			candidate.Power = int(math.Ceil(remaining * 1000 / hours))
+			if candidate.Power > maxPower {
+				candidate.Power = maxPower
+			}
+		}
+		used := float64(candidate.Power) * hours / 1000
+		remaining -= used
+		plan.Energy += used
+		plan.Cost += used * candidate.Rate
+		chosen = append(chosen, candidate)
+	}
+	plan.Feasible = remaining <= 1e-9
+
+	sort.Slice(chosen, func(i, j int) bool {
+		return chosen[i].Start.Before(chosen[j].Start)
+	})
+	for _, s := range chosen {
+		// 合并相邻且功率和单价相同的时段。
+		if n := len(plan.Slots); n > 0 && plan.Slots[n-1].End.Equal(s.Start) && plan.Slots[n-1].Power == s.Power && plan.Slots[n-1].Rate == s.Rate {
+			plan.Slots[n-1].End = s.End
+			continue
+		}
+		plan.Slots = append(plan.Slots, s)
+	}
+	return plan
+}
+
+// averageRate 返回 [start, end) 内的平均单价，按时长加权。
+func averageRate(schedule *TariffSchedule, start, end time.Time) float64 {
+	if schedule == nil || !end.After(start) {
+		return 0
+	}
+	var sum float64
+	for t := start; t.Before(end); {
+		next := schedule.NextChange(t)
+		if next.After(end) {
+			next = end
+		}
+		if rate, ok := schedule.RateAt(t); ok {
+			sum += rate.Rate * next.Sub(t).Seconds()
+		}
+		t = next
+	}
+	return sum / end.Sub(start).Seconds()
+}
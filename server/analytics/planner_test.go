@@ -0,0 +1,56 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// TestPlanDeferrable 测试按电价计算可延后负载的最低费用计划。
+func TestPlanDeferrable(t *testing.T) {
+	// 2024-07-01 为星期一：23:00 起为谷时。
+	now := time.Date(2024, 7, 1, 21, 50, 0, 0, time.UTC)
+	deadline := time.Date(2024, 7, 2, 7, 0, 0, 0, time.UTC)
+	schedule := newTestTariffSchedule(t, models.TariffVersion{EffectiveFrom: now.AddDate(-1, 0, 0), TimeZone: "UTC", Definition: testTariffDefinition})
+
+	// 2 千瓦时，最大 1000 瓦：谷时最早的两个小时。
+	plan := PlanDeferrable(now, deadline, 2, 1000, schedule, 15*time.Minute)
+	assert.True(t, plan.Feasible)
+	assert.InDelta(t, 2, plan.Energy, 1e-9)
+	assert.InDelta(t, 0.6, plan.Cost, 1e-9)
+	assert.Equal(t, []PlanSlot{{
+		Start: time.Date(2024, 7, 1, 23, 0, 0, 0, time.UTC),
+		End:   time.Date(2024, 7, 2, 1, 0, 0, 0, time.UTC),
+		Power: 1000,
+		Rate:  0.3,
+	}}, plan.Slots)
+	assert.Equal(t, 0, plan.PowerAt(now))
+	assert.Equal(t, 1000, plan.PowerAt(time.Date(2024, 7, 1, 23, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 0, plan.PowerAt(time.Date(2024, 7, 2, 1, 0, 0, 0, time.UTC)))
+
+	// 剩余能量不足一个时段时降低功率。
+	plan = PlanDeferrable(now, deadline, 0.1, 1000, schedule, 15*time.Minute)
+	assert.True(t, plan.Feasible)
+	assert.Len(t, plan.Slots, 1)
+	assert.Equal(t, 400, plan.Slots[0].Power)
+
+	// 截止时间前无法满足时，全部时段以最大功率运行。第一个时段从 now 开始，不足一个时段。
+	plan = PlanDeferrable(now, deadline, 100, 1000, schedule, 15*time.Minute)
+	assert.False(t, plan.Feasible)
+	assert.Equal(t, now, plan.Slots[0].Start)
+	assert.InDelta(t, deadline.Sub(now).Hours(), plan.Energy, 1e-9)
+
+	// 没有电价时尽早运行：21:50 至 22:45 以最大功率运行，22:45 至 23:00 以降低的功率运行。
+	plan = PlanDeferrable(now, deadline, 1, 1000, nil, 15*time.Minute)
+	assert.True(t, plan.Feasible)
+	assert.Len(t, plan.Slots, 2)
+	assert.Equal(t, now, plan.Slots[0].Start)
+	assert.Equal(t, time.Date(2024, 7, 1, 22, 45, 0, 0, time.UTC), plan.Slots[0].End)
+	assert.Equal(t, 334, plan.Slots[1].Power)
+
+	plan = PlanDeferrable(now, now.Add(-time.Hour), 1, 1000, schedule, 15*time.Minute)
+	assert.False(t, plan.Feasible)
+	assert.Len(t, plan.Slots, 0)
+}
@@ -0,0 +1,262 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vistart/project20240227/server/models"
+)
+
+// TariffDefinition 表示电价版本的内容。
+// 一年按日期划分为若干季节，每个季节按星期和时刻划分为若干时段，每个时段有各自的单价。
+// 季节和时段均按定义的顺序匹配，先匹配者优先。
+type TariffDefinition struct {
+	Seasons []TariffSeason `json:"seasons"`
+}
+
+// TariffSeason 表示一个季节。From 和 To 为 MM-DD 格式的起止日期（均包含），To 早于 From 时表示跨年。
+type TariffSeason struct {
+	Name    string         `json:"name"`
+	From    string         `json:"from"`
+	To      string         `json:"to"`
+	Periods []TariffPeriod `json:"periods"`
+
+	from, to int // 月份*100+日期。
+}
+
+// TariffPeriod 表示一个时段。Start 和 End 为 HH:MM 格式的起止时刻（包含 Start，不包含 End），
+// End 可以为 24:00，End 不晚于 Start 时表示跨越午夜。Weekdays 为适用的星期，0 表示星期日，为空时适用于每天。
+// 跨越午夜的时段按开始当天的星期匹配。Rate 为每千瓦时的单价。
+type TariffPeriod struct {
+	Name     string  `json:"name"`
+	Weekdays []int   `json:"weekdays,omitempty"`
+	Start    string  `json:"start"`
+	End      string  `json:"end"`
+	Rate     float64 `json:"rate"`
+
+	start, end int // 距午夜的分钟数。
+}
+
+// ErrTariffDefinition 表示电价定义有误。
+type ErrTariffDefinition struct {
+	Reason string
+}
+
+func (e ErrTariffDefinition) Error() string {
+	return "invalid tariff definition: " + e.Reason
+}
+
+// ParseTariffDefinition 解析并检查电价定义。定义必须覆盖全年每一天的每一分钟。
+func ParseTariffDefinition(definition string) (*TariffDefinition, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(definition)))
+	decoder.DisallowUnknownFields()
+	var d TariffDefinition
+	if err := decoder.Decode(&d); err != nil {
+		return nil, ErrTariffDefinition{Reason: err.Error()}
+	}
+	if len(d.Seasons) == 0 {
+		return nil, ErrTariffDefinition{Reason: "no season"}
+	}
+	for i := range d.Seasons {
+		season := &d.Seasons[i]
+		var err error
+		if season.from, err = parseMonthDay(season.From); err != nil {
+			return nil, ErrTariffDefinition{Reason: fmt.Sprintf("season %q: %s", season.Name, err.Error())}
+		}
+		if season.to, err = parseMonthDay(season.To); err != nil {
+			return nil, ErrTariffDefinition{Reason: fmt.Sprintf("season %q: %s", season.Name, err.Error())}
+		}
+		if len(season.Periods) == 0 {
+			return nil, ErrTariffDefinition{Reason: fmt.Sprintf("season %q: no period", season.Name)}
+		}
+		for j := range season.Periods {
+			period := &season.Periods[j]
+			if period.start, err = parseClock(period.Start); err != nil {
+				return nil, ErrTariffDefinition{Reason: fmt.Sprintf("period %q: %s", period.Name, err.Error())}
+			}
+			if period.end, err = parseClock(period.End); err != nil {
+				return nil, ErrTariffDefinition{Reason: fmt.Sprintf("period %q: %s", period.Name, err.Error())}
+			}
+			if period.start == 24*60 {
+				return nil, ErrTariffDefinition{Reason: fmt.Sprintf("period %q: start out of range", period.Name)}
+			}
+			for _, weekday := range period.Weekdays {
+				if weekday < 0 || weekday > 6 {
+					return nil, ErrTariffDefinition{Reason: fmt.Sprintf("period %q: weekday out of range", period.Name)}
+				}
+			}
+			if period.Rate < 0 {
+				return nil, ErrTariffDefinition{Reason: fmt.Sprintf("period %q: negative rate", period.Name)}
+			}
+		}
+	}
+	// 以闰年检查覆盖范围，确保 02-29 也被覆盖。
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for ; day.Year() == 2024; day = day.AddDate(0, 0, 1) {
+		season := d.season(day)
+		if season == nil {
+			return nil, ErrTariffDefinition{Reason: "date " + day.Format("01-02") + " not covered"}
+		}
+	}
+	for i := range d.Seasons {
+		season := &d.Seasons[i]
+		for weekday := 0; weekday < 7; weekday++ {
+			for minute := 0; minute < 24*60; minute++ {
+				if season.period(time.Weekday(weekday), minute) == nil {
+					return nil, ErrTariffDefinition{Reason: fmt.Sprintf("season %q: %s %02d:%02d not covered", season.Name, time.Weekday(weekday), minute/60, minute%60)}
+				}
+			}
+		}
+	}
+	return &d, nil
+}
+
+func parseMonthDay(value string) (int, error) {
+	t, err := time.Parse("01-02", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date %q", value)
+	}
+	return int(t.Month())*100 + t.Day(), nil
+}
+
+func parseClock(value string) (int, error) {
+	if value == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// season 返回 t 所在日期适用的季节。t 须已转换为电价的时区。
+func (d *TariffDefinition) season(t time.Time) *TariffSeason {
+	date := int(t.Month())*100 + t.Day()
+	for i := range d.Seasons {
+		season := &d.Seasons[i]
+		if season.from <= season.to && date >= season.from && date <= season.to {
+			return season
+		}
+		if season.from > season.to && (date >= season.from || date <= season.to) {
+			return season
+		}
+	}
+	return nil
+}
+
+// period 返回某个星期的某一分钟适用的时段。
+func (s *TariffSeason) period(weekday time.Weekday, minute int) *TariffPeriod {
+	for i := range s.Periods {
+		period := &s.Periods[i]
+		if period.start < period.end {
+			if minute >= period.start && minute < period.end && period.matches(weekday) {
+				return period
+			}
+			continue
+		}
+		// 跨越午夜：午夜之前按当天匹配，午夜之后按前一天匹配。
+		if minute >= period.start && period.matches(weekday) {
+			return period
+		}
+		if minute < period.end && period.matches((weekday+6)%7) {
+			return period
+		}
+	}
+	return nil
+}
+
+func (p *TariffPeriod) matches(weekday time.Weekday) bool {
+	if len(p.Weekdays) == 0 {
+		return true
+	}
+	for _, w := range p.Weekdays {
+		if time.Weekday(w) == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// PeriodAt 返回 t 时刻适用的季节和时段。t 须已转换为电价的时区。
+func (d *TariffDefinition) PeriodAt(t time.Time) (*TariffSeason, *TariffPeriod) {
+	season := d.season(t)
+	if season == nil {
+		return nil, nil
+	}
+	return season, season.period(t.Weekday(), t.Hour()*60+t.Minute())
+}
+
+// TariffRate 表示某一时刻的电价。
+type TariffRate struct {
+	Season string  `json:"season"`
+	Period string  `json:"period"`
+	Rate   float64 `json:"rate"`
+}
+
+type tariffScheduleVersion struct {
+	effectiveFrom time.Time
+	location      *time.Location
+	definition    *TariffDefinition
+}
+
+// TariffSchedule 表示电价方案的所有版本，用于查询任意时刻的电价。
+type TariffSchedule struct {
+	Currency string
+	versions []tariffScheduleVersion // 按生效时间正序排列。
+}
+
+// NewTariffSchedule 根据电价方案的所有版本构造电价表。
+func NewTariffSchedule(tariff *models.Tariff, versions []models.TariffVersion) (*TariffSchedule, error) {
+	s := &TariffSchedule{Currency: tariff.Currency}
+	for _, version := range versions {
+		location, err := time.LoadLocation(version.TimeZone)
+		if err != nil {
+			return nil, err
+		}
+		definition, err := ParseTariffDefinition(version.Definition)
+		if err != nil {
+			return nil, err
+		}
+		s.versions = append(s.versions, tariffScheduleVersion{
+			effectiveFrom: version.EffectiveFrom,
+			location:      location,
+			definition:    definition,
+		})
+	}
+	sort.SliceStable(s.versions, func(i, j int) bool {
+		return s.versions[i].effectiveFrom.Before(s.versions[j].effectiveFrom)
+	})
+	return s, nil
+}
+
+// RateAt 返回 t 时刻的电价。t 早于第一个版本的生效时间时，返回 false。
+func (s *TariffSchedule) RateAt(t time.Time) (TariffRate, bool) {
+	i := sort.Search(len(s.versions), func(i int) bool {
+		return s.versions[i].effectiveFrom.After(t)
+	}) - 1
+	if i < 0 {
+		return TariffRate{}, false
+	}
+	version := s.versions[i]
+	season, period := version.definition.PeriodAt(t.In(version.location))
+	if season == nil || period == nil {
+		return TariffRate{}, false
+	}
+	return TariffRate{Season: season.Name, Period: period.Name, Rate: period.Rate}, true
+}
+
+// NextChange 返回 t 之后电价可能发生变化的最早时刻：下一分钟的开始，或下一个版本的生效时间。
+// 时段以分钟划分，因此在同一分钟内电价不变。
+func (s *TariffSchedule) NextChange(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	for _, version := range s.versions {
+		if version.effectiveFrom.After(t) && version.effectiveFrom.Before(next) {
+			return version.effectiveFrom
+		}
+	}
+	return next
+}
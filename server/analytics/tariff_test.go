@@ -0,0 +1,184 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// testTariffDefinition 夏季工作日 14:00-20:00 为峰时，其余为平时；全年 23:00-07:00 为谷时，周末除谷时外均为平时。
+const testTariffDefinition = `{
+	"seasons": [
+		{"name": "summer", "from": "06-01", "to": "09-30", "periods": [
+			{"name": "off-peak", "start": "23:00", "end": "07:00", "rate": 0.3},
+			{"name": "peak", "weekdays": [1, 2, 3, 4, 5], "start": "14:00", "end": "20:00", "rate": 1.2},
+			{"name": "shoulder", "start": "00:00", "end": "24:00", "rate": 0.6}
+		]},
+		{"name": "winter", "from": "10-01", "to": "05-31", "periods": [
+			{"name": "off-peak", "start": "23:00", "end": "07:00", "rate": 0.3},
+			{"name": "shoulder", "start": "07:00", "end": "23:00", "rate": 0.5}
+		]}
+	]
+}`
+
+// TestParseTariffDefinition 测试解析并检查电价定义。
+func TestParseTariffDefinition(t *testing.T) {
+	d, err := ParseTariffDefinition(testTariffDefinition)
+	assert.Nil(t, err)
+
+	// 2024-07-01 为星期一。
+	season, period := d.PeriodAt(time.Date(2024, 7, 1, 15, 0, 0, 0, time.UTC))
+	assert.Equal(t, "summer", season.Name)
+	assert.Equal(t, "peak", period.Name)
+	_, period = d.PeriodAt(time.Date(2024, 7, 6, 15, 0, 0, 0, time.UTC))
+	assert.Equal(t, "shoulder", period.Name)
+	_, period = d.PeriodAt(time.Date(2024, 7, 2, 6, 59, 0, 0, time.UTC))
+	assert.Equal(t, "off-peak", period.Name)
+	season, period = d.PeriodAt(time.Date(2024, 12, 31, 23, 30, 0, 0, time.UTC))
+	assert.Equal(t, "winter", season.Name)
+	assert.Equal(t, "off-peak", period.Name)
+	season, _ = d.PeriodAt(time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC))
+	assert.Equal(t, "winter", season.Name)
+
+	// 未覆盖全年。
+	_, err = ParseTariffDefinition(`{"seasons": [{"name": "a", "from": "01-01", "to": "12-30", "periods": [{"name": "p", "start": "00:00", "end": "24:00", "rate": 1}]}]}`)
+	assert.ErrorAs(t, err, &ErrTariffDefinition{})
+
+	// 未覆盖全天。
+	_, err = ParseTariffDefinition(`{"seasons": [{"name": "a", "from": "01-01", "to": "12-31", "periods": [{"name": "p", "start": "00:00", "end": "23:59", "rate": 1}]}]}`)
+	assert.ErrorAs(t, err, &ErrTariffDefinition{})
+
+	// 跨越午夜的时段按开始当天的星期匹配：周五 22:00 至周六 06:00。
+	_, err = ParseTariffDefinition(`{"seasons": [{"name": "a", "from": "01-01", "to": "12-31", "periods": [
+		{"name": "weekend", "weekdays": [5], "start": "22:00", "end": "06:00", "rate": 1},
+		{"name": "other", "start": "00:00", "end": "24:00", "rate": 2}
+	]}]}`)
+	assert.Nil(t, err)
+
+	_, err = ParseTariffDefinition(`{"seasons": [], "currency": "CNY"}`)
+	assert.ErrorAs(t, err, &ErrTariffDefinition{})
+}
+
+func newTestTariffSchedule(t *testing.T, versions ...models.TariffVersion) *TariffSchedule {
+	schedule, err := NewTariffSchedule(models.NewTariff("test", "CNY"), versions)
+	assert.Nil(t, err)
+	return schedule
+}
+
+// TestTariffSchedule_RateAt 测试按版本查询电价。
+func TestTariffSchedule_RateAt(t *testing.T) {
+	v1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	v2 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	schedule := newTestTariffSchedule(t,
+		models.TariffVersion{EffectiveFrom: v2, TimeZone: "UTC", Definition: `{"seasons": [{"name": "all", "from": "01-01", "to": "12-31", "periods": [{"name": "flat", "start": "00:00", "end": "24:00", "rate": 2}]}]}`},
+		models.TariffVersion{EffectiveFrom: v1, TimeZone: "Asia/Shanghai", Definition: testTariffDefinition},
+	)
+
+	_, ok := schedule.RateAt(v1.Add(-time.Second))
+	assert.False(t, ok)
+
+	// 2024-01-01 22:59 UTC 为上海时间 2024-01-02 06:59，属于谷时。
+	rate, ok := schedule.RateAt(time.Date(2024, 1, 1, 22, 59, 0, 0, time.UTC))
+	assert.True(t, ok)
+	assert.Equal(t, TariffRate{Season: "winter", Period: "off-peak", Rate: 0.3}, rate)
+	rate, _ = schedule.RateAt(time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC))
+	assert.Equal(t, "shoulder", rate.Period)
+
+	rate, _ = schedule.RateAt(v2)
+	assert.Equal(t, TariffRate{Season: "all", Period: "flat", Rate: 2}, rate)
+
+	assert.Equal(t, v2, schedule.NextChange(v2.Add(-30*time.Second)))
+	assert.Equal(t, v2.Add(time.Minute), schedule.NextChange(v2))
+}
+
+// TestIntegrateCost 测试按电价计算费用。
+func TestIntegrateCost(t *testing.T) {
+	from := time.Date(2024, 7, 1, 13, 0, 0, 0, time.UTC)
+	schedule := newTestTariffSchedule(t, models.TariffVersion{EffectiveFrom: from.Add(30 * time.Minute), TimeZone: "UTC", Definition: testTariffDefinition})
+
+	// 13:00 至 15:00 恒定 1000 W：13:00-13:30 无法计费，13:30-14:00 为平时，14:00-15:00 为峰时。
+	samples := []Sample{
+		{Power: 1000, At: from},
+		{Power: 1000, At: from.Add(2 * time.Hour)},
+	}
+	cost := IntegrateCost(samples, []Interval{{from, from.Add(2 * time.Hour)}}, schedule)
+	assert.InDelta(t, 2, cost.Energy, 1e-9)
+	assert.InDelta(t, 0.5, cost.Unpriced, 1e-9)
+	assert.InDelta(t, 0.5*0.6+1*1.2, cost.Cost, 1e-9)
+	assert.Len(t, cost.Periods, 2)
+	assert.Equal(t, "peak", cost.Periods[0].Period)
+	assert.InDelta(t, 1, cost.Periods[0].Energy, 1e-9)
+
+	// 功率线性变化时，在时段边界处按插值切分：13:30 至 14:30 由 0 W 升至 2000 W。
+	samples = []Sample{
+		{Power: 0, At: from.Add(30 * time.Minute)},
+		{Power: 2000, At: from.Add(90 * time.Minute)},
+	}
+	cost = IntegrateCost(samples, []Interval{{from, from.Add(2 * time.Hour)}}, schedule)
+	assert.InDelta(t, 1, cost.Energy, 1e-9)
+	assert.InDelta(t, 0.25*0.6+0.75*1.2, cost.Cost, 1e-9)
+
+	// 离线期间不计费。
+	cost = IntegrateCost(samples, []Interval{{from, from.Add(time.Hour)}}, schedule)
+	assert.Equal(t, float64(0), cost.Energy)
+
+	total := NewCost()
+	total.Add(IntegrateCost(samples, []Interval{{from, from.Add(2 * time.Hour)}}, schedule))
+	total.Add(IntegrateCost(samples, []Interval{{from, from.Add(2 * time.Hour)}}, schedule))
+	assert.InDelta(t, 2, total.Energy, 1e-9)
+	assert.Len(t, total.Periods, 2)
+}
+
+// TestIntegrateCost_Generation 测试发电（负功率）部分计入发电量，不按电价计费；功率过零时在零点切分。
+func TestIntegrateCost_Generation(t *testing.T) {
+	from := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	schedule := newTestTariffSchedule(t, models.TariffVersion{EffectiveFrom: from.AddDate(-1, 0, 0), TimeZone: "UTC", Definition: testTariffDefinition})
+
+	// 10:00 至 11:00 由 -1000 W 升至 1000 W：前半小时发电 0.25 千瓦时，后半小时用电 0.25 千瓦时。
+	samples := []Sample{
+		{Power: -1000, At: from},
+		{Power: 1000, At: from.Add(time.Hour)},
+	}
+	cost := IntegrateCost(samples, []Interval{{from, from.Add(time.Hour)}}, schedule)
+	assert.InDelta(t, 0.25, cost.Energy, 1e-9)
+	assert.InDelta(t, 0.25, cost.Generation, 1e-9)
+	assert.InDelta(t, 0.25*0.6, cost.Cost, 1e-9)
+}
+
+// TestIntegrateGrid 测试按时间汇总全屋与电网之间的电量交换，只有从电网取用的电量计费。
+func TestIntegrateGrid(t *testing.T) {
+	from := time.Date(2024, 7, 1, 13, 0, 0, 0, time.UTC)
+	to := from.Add(2 * time.Hour)
+	schedule := newTestTariffSchedule(t, models.TariffVersion{EffectiveFrom: from.AddDate(-1, 0, 0), TimeZone: "UTC", Definition: testTariffDefinition})
+	constant := func(power float64, from, to time.Time) ClientSamples {
+		return ClientSamples{
+			Samples: []Sample{{Power: power, At: from}, {Power: power, At: to}},
+			Online:  []Interval{{from, to}},
+		}
+	}
+
+	// 13:00 至 15:00 用电 1000 W；13:00 至 14:00 发电 1500 W，之后停止发电（离线）。
+	grid := IntegrateGrid([]ClientSamples{
+		constant(1000, from, to),
+		constant(-1500, from, from.Add(time.Hour)),
+	}, schedule, from, to)
+	assert.InDelta(t, 2, grid.Load, 1e-9)
+	assert.InDelta(t, 1.5, grid.Generation, 1e-9)
+	assert.InDelta(t, 1, grid.Import, 1e-9)
+	assert.InDelta(t, 0.5, grid.Export, 1e-9)
+	assert.InDelta(t, 1, grid.SelfConsumption, 1e-9)
+	// 只有 14:00 至 15:00 峰时从电网取用的 1 千瓦时计费。
+	assert.InDelta(t, 1, grid.Cost.Energy, 1e-9)
+	assert.InDelta(t, 1.2, grid.Cost.Cost, 1e-9)
+	assert.Len(t, grid.Cost.Periods, 1)
+
+	// 按客户端分别计费时，发电不抵扣用电。
+	cost := NewCost()
+	cost.Add(IntegrateCost(constant(1000, from, to).Samples, []Interval{{from, to}}, schedule))
+	cost.Add(IntegrateCost(constant(-1500, from, from.Add(time.Hour)).Samples, []Interval{{from, to}}, schedule))
+	assert.InDelta(t, 2, cost.Energy, 1e-9)
+	assert.InDelta(t, 1.5, cost.Generation, 1e-9)
+	assert.InDelta(t, 0.6+1.2, cost.Cost, 1e-9)
+}
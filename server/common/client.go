@@ -1,12 +1,10 @@
 package common
 
 import (
-	"errors"
 	"fmt"
 	"time"
 
 	"github.com/vistart/project20240227/server/models"
-	"gorm.io/gorm"
 )
 
 const (
@@ -32,8 +30,6 @@ type ClientBaseInterface interface {
 
 	// Type 表示设备类型。
 	Type() models.ClientType
-
-	InsertNewClient() (int64, error)
 }
 
 type ClientSessionInterface interface {
@@ -49,7 +45,11 @@ type ClientActivityInterface interface {
 }
 
 type ClientConsumptionInterface interface {
-	ReceiveReportConsumption(float32, time.Time) (int64, error)
+	ReceiveReportConsumption(float32, *float32, time.Time) (int64, error)
+}
+
+type ClientBatteryStateInterface interface {
+	ReceiveReportBatteryState(float32, time.Time) (int64, error)
 }
 
 // ClientBase 保存了ID和Type，但不能直接访问。
@@ -63,6 +63,7 @@ type ClientBase struct {
 
 	ClientActivityInterface
 	ClientConsumptionInterface
+	ClientBatteryStateInterface
 }
 
 func (c *ClientBase) ID() string {
@@ -73,21 +74,24 @@ func (c *ClientBase) Type() models.ClientType {
 	return c.clientType
 }
 
-func (c *ClientBase) InsertNewClient() (int64, error) {
-	_, err := models.GetClient(DB, c.ID())
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		model := models.NewClient(c.ID(), c.ID(), c.Type())
-		return models.RegisterNewClient(DB, model)
-	}
-	return 0, nil
-}
-
 // SendToSessionChannel 向客户端通道发送内容。
 // 发送的内容目前是任意类型，但目前仅支持
 func (c *ClientBase) SendToSessionChannel(v any) {
 	c.sessionChannel <- v
 }
 
+// SendToSessionChannelWithTimeout 向客户端通道发送内容。如果超过 timeout 仍未被接收，则放弃并返回 false。
+func (c *ClientBase) SendToSessionChannelWithTimeout(v any, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case c.sessionChannel <- v:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
 func (c *ClientBase) CreateSessionChannel() {
 	c.sessionChannel = make(SessionChannel)
 }
@@ -108,12 +112,22 @@ func (c *ClientBase) ReceiveActivity(content int8) {
 	client.InsertNewActivity(DB, content)
 }
 
-func (c *ClientBase) ReceiveReportConsumption(consumption float32, recordedAt time.Time) (int64, error) {
+// ReceiveReportConsumption 保存客户端报告的功耗。temperature 为报告的室内温度，未报告时为空。
+func (c *ClientBase) ReceiveReportConsumption(consumption float32, temperature *float32, recordedAt time.Time) (int64, error) {
+	client, err := models.GetClient(DB, c.ID())
+	if err != nil {
+		return 0, nil
+	}
+	return client.InsertNewConsumption(DB, consumption, temperature, recordedAt)
+}
+
+// ReceiveReportBatteryState 保存储能客户端报告的荷电状态（百分比）。
+func (c *ClientBase) ReceiveReportBatteryState(soc float32, recordedAt time.Time) (int64, error) {
 	client, err := models.GetClient(DB, c.ID())
 	if err != nil {
 		return 0, nil
 	}
-	return client.InsertNewConsumption(DB, consumption, recordedAt)
+	return client.InsertNewBatteryState(DB, soc, recordedAt)
 }
 
 // Client 客户端。
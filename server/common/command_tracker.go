@@ -0,0 +1,138 @@
+package common
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/vistart/project20240227/server/models"
+)
+
+// CommandTracker 跟踪已下发但尚未被客户端确认的命令。
+// 超过确认时间仍未确认时，以相同的命令ID重新发送，客户端可据此识别重复的命令；重试次数用尽后将命令标记为未确认。
+// 同一客户端的同类命令只等待最新的一条，较早的命令不再重新发送，以免覆盖新命令。
+type CommandTracker struct {
+	timeout    time.Duration
+	maxRetries int
+	sessions   *SessionManager
+	pending    map[string]*trackedCommand // 键为命令ID。
+	superseded []*trackedCommand          // 被新命令取代、尚未标记的命令。
+	mu         sync.Mutex
+}
+
+type trackedCommand struct {
+	execution *models.ClientCommandExecution
+	event     any       // 已下发的命令事件，重新发送时原样发送。
+	deadline  time.Time // 超过该时刻仍未确认时重新发送。
+}
+
+func NewCommandTracker(config ConfigCommand, sessions *SessionManager) *CommandTracker {
+	return &CommandTracker{
+		timeout:    time.Duration(config.AckTimeout) * time.Second,
+		maxRetries: config.MaxRetries,
+		sessions:   sessions,
+		pending:    make(map[string]*trackedCommand),
+	}
+}
+
+// Track 开始等待客户端确认已下发的命令。同一客户端仍在等待确认的同类命令被取代，不再重新发送。
+func (t *CommandTracker) Track(execution *models.ClientCommandExecution, event any, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for commandID, command := range t.pending {
+		if command.execution.ClientID == execution.ClientID && command.execution.Code == execution.Code {
+			delete(t.pending, commandID)
+			t.superseded = append(t.superseded, command)
+		}
+	}
+	t.pending[execution.CommandID] = &trackedCommand{
+		execution: execution,
+		event:     event,
+		deadline:  now.Add(t.timeout),
+	}
+}
+
+// Remove 停止等待某条命令的确认。客户端确认命令后调用。
+func (t *CommandTracker) Remove(commandID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.pending, commandID)
+}
+
+// Count 返回正在等待确认的命令数。
+func (t *CommandTracker) Count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending)
+}
+
+// Serve 提供服务。每秒检查一次等待确认的命令。
+func (t *CommandTracker) Serve() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		t.check(now)
+	}
+}
+
+// isPending 判断命令是否仍在等待确认。
+func (t *CommandTracker) isPending(commandID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.pending[commandID]
+	return ok
+}
+
+// check 重新发送超时未确认的命令，并将重试次数用尽的命令标记为未确认，被取代的命令标记为已被取代。
+// 客户端离线时同样计入重试次数，以免命令无限期等待。
+func (t *CommandTracker) check(now time.Time) {
+	var retry, expired []*trackedCommand
+	t.mu.Lock()
+	superseded := t.superseded
+	t.superseded = nil
+	for commandID, command := range t.pending {
+		if now.Before(command.deadline) {
+			continue
+		}
+		if command.execution.Retries >= t.maxRetries {
+			delete(t.pending, commandID)
+			expired = append(expired, command)
+			continue
+		}
+		command.deadline = now.Add(t.timeout)
+		retry = append(retry, command)
+	}
+	t.mu.Unlock()
+
+	for _, command := range superseded {
+		if _, err := command.execution.MarkSuperseded(DB); err != nil {
+			log.Println(err.Error())
+			continue
+		}
+		log.Printf("Command[%s] to client[%s] superseded.", command.execution.CommandID, command.execution.ClientID)
+	}
+	for _, command := range expired {
+		if _, err := command.execution.MarkUnacked(DB); err != nil {
+			log.Println(err.Error())
+			continue
+		}
+		log.Printf("Command[%s] to client[%s] unacknowledged after %d retries.", command.execution.CommandID, command.execution.ClientID, command.execution.Retries)
+	}
+	for _, command := range retry {
+		// 准备重新发送期间可能已被确认或取代。
+		if !t.isPending(command.execution.CommandID) {
+			continue
+		}
+		if _, err := command.execution.UpdateRetries(DB, command.execution.Retries+1); err != nil {
+			log.Println(err.Error())
+		}
+		client := t.sessions.GetClient(command.execution.ClientID)
+		if client == nil || !client.SendToSessionChannelWithTimeout(command.event, DispatchCommandTimeout) {
+			log.Printf("Command[%s] to client[%s] retry %d not delivered.", command.execution.CommandID, command.execution.ClientID, command.execution.Retries)
+			continue
+		}
+		log.Printf("Command[%s] to client[%s] resent, retry %d.", command.execution.CommandID, command.execution.ClientID, command.execution.Retries)
+	}
+}
+
+var GlobalCommandTracker *CommandTracker
@@ -0,0 +1,36 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// TestCommandTracker_Track 测试同一客户端的同类新命令取代仍在等待确认的旧命令。
+func TestCommandTracker_Track(t *testing.T) {
+	tracker := NewCommandTracker(ConfigCommand{AckTimeout: 10, MaxRetries: 3}, nil)
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	command := func(id string, clientID string, code int) *models.ClientCommandExecution {
+		return &models.ClientCommandExecution{CommandID: id, ClientID: clientID, Code: code}
+	}
+
+	tracker.Track(command("a", "heater", EventCodeCommandPower), nil, now)
+	tracker.Track(command("b", "heater", EventCodeCommandSetpoint), nil, now)
+	tracker.Track(command("c", "washer", EventCodeCommandPower), nil, now)
+	assert.Equal(t, 3, tracker.Count())
+
+	tracker.Track(command("d", "heater", EventCodeCommandPower), nil, now.Add(time.Second))
+	assert.Equal(t, 3, tracker.Count())
+	assert.False(t, tracker.isPending("a"))
+	assert.True(t, tracker.isPending("b"))
+	assert.True(t, tracker.isPending("c"))
+	assert.True(t, tracker.isPending("d"))
+	if assert.Len(t, tracker.superseded, 1) {
+		assert.Equal(t, "a", tracker.superseded[0].execution.CommandID)
+	}
+
+	tracker.Remove("d")
+	assert.Equal(t, 2, tracker.Count())
+}
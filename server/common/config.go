@@ -13,12 +13,46 @@ type ConfigDatabase struct {
 
 type ConfigSessionManager struct {
 	BroadcastTimestampInterval int64 `toml:"broadcast_timestamp_interval"`
+	ReplayBufferSize           int   `toml:"replay_buffer_size"` // 每个客户端最多保存的可重放事件数，用于客户端重连后重放。
+}
+
+// ConfigAuth 客户端验证配置。
+type ConfigAuth struct {
+	LegacyMD5          bool  `toml:"legacy_md5"`          // 是否允许旧的 MD5 验证方式。仅用于迁移期间。
+	TimestampTolerance int64 `toml:"timestamp_tolerance"` // 签名时间戳允许的最大偏差，单位为秒。
+}
+
+// ConfigUser 用户配置。
+type ConfigUser struct {
+	SessionTTL    int64  `toml:"session_ttl"`    // 登录会话有效期，单位为秒。
+	AdminUsername string `toml:"admin_username"` // 初始管理员用户名。仅在不存在任何用户时创建。
+	AdminPassword string `toml:"admin_password"` // 初始管理员密码。
+}
+
+// ConfigLoadShedding 负载切除配置。
+type ConfigLoadShedding struct {
+	Enabled       bool    `toml:"enabled"`        // 是否启用负载切除。
+	Limit         float64 `toml:"limit"`          // 全屋总功率上限，单位为瓦。
+	ShedAfter     int64   `toml:"shed_after"`     // 总功率持续超出上限多少秒后切除一个客户端。
+	ShedPower     int     `toml:"shed_power"`     // 切除时向客户端下发的功率，单位为瓦。
+	RestoreMargin float64 `toml:"restore_margin"` // 恢复客户端后预计的总功率须至少低于上限多少瓦。
+	RestoreAfter  int64   `toml:"restore_after"`  // 持续满足恢复条件多少秒后恢复一个客户端。
+}
+
+// ConfigCommand 命令下发配置。
+type ConfigCommand struct {
+	AckTimeout int64 `toml:"ack_timeout"` // 等待客户端确认命令的时间，超时后重新发送，单位为秒。
+	MaxRetries int   `toml:"max_retries"` // 未确认时最多重新发送的次数。用尽后将命令标记为未确认。
 }
 
 type Config struct {
 	Port               uint16               `toml:"port"`
 	Database           ConfigDatabase       `toml:"database"`
 	BroadcastTimestamp ConfigSessionManager `toml:"session_manager"`
+	Auth               ConfigAuth           `toml:"auth"`
+	User               ConfigUser           `toml:"user"`
+	LoadShedding       ConfigLoadShedding   `toml:"load_shedding"`
+	Command            ConfigCommand        `toml:"command"`
 }
 
 func LoadConfig(name string) *Config {
@@ -36,5 +70,31 @@ func LoadConfig(name string) *Config {
 	if config.BroadcastTimestamp.BroadcastTimestampInterval <= 0 {
 		panic(errors.New("broadcast timestamp interval is zero"))
 	}
+	if config.BroadcastTimestamp.ReplayBufferSize <= 0 {
+		config.BroadcastTimestamp.ReplayBufferSize = 64
+	}
+	if config.Auth.TimestampTolerance <= 0 {
+		config.Auth.TimestampTolerance = 300
+	}
+	if config.User.SessionTTL <= 0 {
+		config.User.SessionTTL = 86400
+	}
+	if config.LoadShedding.Enabled && config.LoadShedding.Limit <= 0 {
+		panic(errors.New("load shedding limit is zero"))
+	}
+	if config.LoadShedding.ShedAfter <= 0 {
+		config.LoadShedding.ShedAfter = 10
+	}
+	if config.LoadShedding.RestoreAfter <= 0 {
+		config.LoadShedding.RestoreAfter = 60
+	}
+	if config.Command.AckTimeout <= 0 {
+		config.Command.AckTimeout = 10
+	}
+	if config.Command.MaxRetries < 0 {
+		config.Command.MaxRetries = 0
+	}
 	return &config
 }
+
+var GlobalConfig *Config
@@ -0,0 +1,206 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule 表示解析后的 cron 表达式。
+// 表达式由五个字段组成，依次为：分（0-59）、时（0-23）、日（1-31）、月（1-12）、星期（0-7，0和7均为星期日）。
+// 每个字段支持 *、逗号分隔的列表、a-b 范围以及 /n 步长；月份和星期也可以使用英文缩写，例如 JAN、MON。
+// 与传统 cron 一致，当日和星期均不为 * 时，满足其一即可。
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+	location                      *time.Location
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronFieldMinute = cronField{name: "minute", min: 0, max: 59}
+	cronFieldHour   = cronField{name: "hour", min: 0, max: 23}
+	cronFieldDom    = cronField{name: "day of month", min: 1, max: 31}
+	cronFieldMonth  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronFieldDow = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// ErrCronExpression 表示 cron 表达式不合法。
+type ErrCronExpression struct {
+	Expression string
+	Reason     string
+	error
+}
+
+func (e ErrCronExpression) Error() string {
+	return fmt.Sprintf("bad cron expression `%s`: %s", e.Expression, e.Reason)
+}
+
+// ParseCron 解析 cron 表达式。location 为计算触发时间所用的时区，为 nil 时使用 time.Local。
+func ParseCron(expression string, location *time.Location) (*CronSchedule, error) {
+	if location == nil {
+		location = time.Local
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, ErrCronExpression{Expression: expression, Reason: "expected 5 fields"}
+	}
+	s := &CronSchedule{location: location}
+	var err error
+	if s.minute, err = cronFieldMinute.parse(fields[0]); err != nil {
+		return nil, ErrCronExpression{Expression: expression, Reason: err.Error()}
+	}
+	if s.hour, err = cronFieldHour.parse(fields[1]); err != nil {
+		return nil, ErrCronExpression{Expression: expression, Reason: err.Error()}
+	}
+	if s.dom, err = cronFieldDom.parse(fields[2]); err != nil {
+		return nil, ErrCronExpression{Expression: expression, Reason: err.Error()}
+	}
+	if s.month, err = cronFieldMonth.parse(fields[3]); err != nil {
+		return nil, ErrCronExpression{Expression: expression, Reason: err.Error()}
+	}
+	if s.dow, err = cronFieldDow.parse(fields[4]); err != nil {
+		return nil, ErrCronExpression{Expression: expression, Reason: err.Error()}
+	}
+	// 星期日可以用0或7表示。
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = fields[2] == "*" || fields[2] == "?"
+	s.dowStar = fields[4] == "*" || fields[4] == "?"
+	return s, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad %s value `%s`", f.name, s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s value %d out of range [%d, %d]", f.name, v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// parse 将字段解析为位图，第 n 位表示值 n。
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("bad %s step `%s`", f.name, part[i+1:])
+			}
+			part = part[:i]
+		}
+		var start, end int
+		switch {
+		case part == "*" || part == "?":
+			start, end = f.min, f.max
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if start, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if end, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("bad %s range `%s`", f.name, part)
+			}
+		default:
+			v, err := f.value(part)
+			if err != nil {
+				return 0, err
+			}
+			start, end = v, v
+			if step > 1 {
+				end = f.max
+			}
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Location 返回计算触发时间所用的时区。
+func (s *CronSchedule) Location() *time.Location {
+	return s.location
+}
+
+// Matches 检查指定时刻（精确到分钟）是否满足表达式。
+func (s *CronSchedule) Matches(t time.Time) bool {
+	t = t.In(s.location)
+	return s.minute&(1<<uint(t.Minute())) != 0 &&
+		s.hour&(1<<uint(t.Hour())) != 0 &&
+		s.month&(1<<uint(t.Month())) != 0 &&
+		s.dayMatches(t)
+}
+
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	domMatched := s.dom&(1<<uint(t.Day())) != 0
+	dowMatched := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatched && dowMatched
+	}
+	return domMatched || dowMatched
+}
+
+// Next 返回 after 之后（不含）的下一个触发时刻。如果五年内不存在触发时刻，则返回零值。
+func (s *CronSchedule) Next(after time.Time) time.Time {
+	t := after.In(s.location)
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, s.location)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.location)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.location)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.location)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// NextN 返回 after 之后的 n 个触发时刻。
+func (s *CronSchedule) NextN(after time.Time, n int) []time.Time {
+	times := make([]time.Time, 0, n)
+	for i := 0; i < n; i++ {
+		after = s.Next(after)
+		if after.IsZero() {
+			break
+		}
+		times = append(times, after)
+	}
+	return times
+}
@@ -0,0 +1,57 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseCron 测试解析 cron 表达式。
+func TestParseCron(t *testing.T) {
+	for _, expression := range []string{"* * * * *", "0 23 * * 1-5", "*/15 8-18 * * MON-FRI", "0 0 1,15 * *", "30 6 * JAN-MAR 0,7"} {
+		_, err := ParseCron(expression, time.UTC)
+		assert.Nil(t, err, expression)
+	}
+	for _, expression := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := ParseCron(expression, time.UTC)
+		assert.ErrorAs(t, err, &ErrCronExpression{}, expression)
+	}
+}
+
+// TestCronSchedule_Next 测试计算下一个触发时刻。
+func TestCronSchedule_Next(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	s, err := ParseCron("0 23 * * 1-5", shanghai)
+	assert.Nil(t, err)
+
+	// 2024-03-01 是星期五。
+	after := time.Date(2024, 3, 1, 22, 59, 30, 0, shanghai)
+	assert.Equal(t, time.Date(2024, 3, 1, 23, 0, 0, 0, shanghai), s.Next(after))
+	// 周末跳过。
+	after = time.Date(2024, 3, 1, 23, 0, 0, 0, shanghai)
+	assert.Equal(t, time.Date(2024, 3, 4, 23, 0, 0, 0, shanghai), s.Next(after))
+	// 按表达式时区计算，与 after 的时区无关。
+	assert.Equal(t, time.Date(2024, 3, 4, 23, 0, 0, 0, shanghai), s.Next(after.UTC()))
+
+	s, err = ParseCron("*/20 * * * *", time.UTC)
+	assert.Nil(t, err)
+	times := s.NextN(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 3)
+	assert.Equal(t, []time.Time{
+		time.Date(2024, 1, 1, 0, 20, 0, 0, time.UTC),
+		time.Date(2024, 1, 1, 0, 40, 0, 0, time.UTC),
+		time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC),
+	}, times)
+
+	// 日和星期均指定时，满足其一即可。2024-01-05 是星期五。
+	s, err = ParseCron("0 0 13 * 5", time.UTC)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), s.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	// 闰日。
+	s, err = ParseCron("0 0 29 2 *", time.UTC)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC), s.Next(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, s.Matches(time.Date(2028, 2, 29, 0, 0, 10, 0, time.UTC)))
+	assert.False(t, s.Matches(time.Date(2028, 2, 29, 0, 1, 0, 0, time.UTC)))
+}
@@ -0,0 +1,147 @@
+package common
+
+import (
+	"sync"
+	"time"
+
+	"github.com/vistart/project20240227/server/models"
+)
+
+const (
+	DashboardEventConsumption = "consumption" // 客户端报告功耗。
+	DashboardEventPresence    = "presence"    // 客户端上线或下线。
+	DashboardEventCommand     = "command"     // 向客户端发送了命令。
+	DashboardEventBattery     = "battery"     // 储能客户端报告荷电状态。
+)
+
+// DashboardEvent 表示推送给仪表盘的事件。Name 作为 SSE 事件名，Data 序列化为 JSON 作为事件内容。
+type DashboardEvent struct {
+	Name string
+	Data any
+}
+
+type DashboardConsumptionData struct {
+	ClientID    string            `json:"client_id"`
+	Type        models.ClientType `json:"type"`
+	Power       float64           `json:"power"`
+	Temperature *float64          `json:"temperature,omitempty"` // 室内温度。客户端未报告时省略。
+	RecordedAt  time.Time         `json:"recorded_at"`
+}
+
+type DashboardBatteryData struct {
+	ClientID   string            `json:"client_id"`
+	Type       models.ClientType `json:"type"`
+	SoC        float64           `json:"soc"` // 荷电状态，单位为百分比。
+	RecordedAt time.Time         `json:"recorded_at"`
+}
+
+type DashboardPresenceData struct {
+	ClientID string            `json:"client_id"`
+	Type     models.ClientType `json:"type"`
+	Online   bool              `json:"online"`
+	At       time.Time         `json:"at"`
+}
+
+type DashboardCommandData struct {
+	ClientID string    `json:"client_id"`
+	Code     int       `json:"code"`
+	Name     string    `json:"name"`
+	Data     string    `json:"data"`
+	SentAt   time.Time `json:"sent_at"`
+}
+
+// DashboardSubscriberBufferSize 表示每个订阅者的事件缓冲数量。缓冲已满时，新事件将被丢弃，以免拖慢发布者。
+const DashboardSubscriberBufferSize = 64
+
+// DashboardHub 将客户端的功耗、上下线和命令事件分发给所有订阅的仪表盘连接。
+type DashboardHub struct {
+	subscribers map[chan DashboardEvent]struct{}
+	mu          sync.RWMutex // subscribers 读写锁。
+}
+
+func NewDashboardHub() *DashboardHub {
+	return &DashboardHub{subscribers: make(map[chan DashboardEvent]struct{})}
+}
+
+// Subscribe 订阅事件。订阅者不再需要时，必须调用 Unsubscribe。
+func (h *DashboardHub) Subscribe() chan DashboardEvent {
+	ch := make(chan DashboardEvent, DashboardSubscriberBufferSize)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subscribers[ch] = struct{}{}
+	return ch
+}
+
+// Unsubscribe 取消订阅并关闭通道。
+func (h *DashboardHub) Unsubscribe(ch chan DashboardEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, existed := h.subscribers[ch]; existed {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Count 返回订阅者数量。
+func (h *DashboardHub) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers)
+}
+
+// Publish 向所有订阅者发布事件。不会阻塞。
+func (h *DashboardHub) Publish(name string, data any) {
+	event := DashboardEvent{Name: name, Data: data}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// PublishConsumption 发布客户端报告功耗事件。temperature 为报告的室内温度，未报告时为空。
+func (h *DashboardHub) PublishConsumption(client *Client, power float64, temperature *float64, recordedAt time.Time) {
+	h.Publish(DashboardEventConsumption, DashboardConsumptionData{
+		ClientID:    client.ID(),
+		Type:        client.Type(),
+		Power:       power,
+		Temperature: temperature,
+		RecordedAt:  recordedAt,
+	})
+}
+
+// PublishBattery 发布储能客户端报告荷电状态事件。
+func (h *DashboardHub) PublishBattery(client *Client, soc float64, recordedAt time.Time) {
+	h.Publish(DashboardEventBattery, DashboardBatteryData{
+		ClientID:   client.ID(),
+		Type:       client.Type(),
+		SoC:        soc,
+		RecordedAt: recordedAt,
+	})
+}
+
+// PublishPresence 发布客户端上下线事件。
+func (h *DashboardHub) PublishPresence(client *Client, online bool) {
+	h.Publish(DashboardEventPresence, DashboardPresenceData{
+		ClientID: client.ID(),
+		Type:     client.Type(),
+		Online:   online,
+		At:       time.Now(),
+	})
+}
+
+// PublishCommand 发布命令已发送事件。
+func (h *DashboardHub) PublishCommand(clientID string, code int, data string, sentAt time.Time) {
+	h.Publish(DashboardEventCommand, DashboardCommandData{
+		ClientID: clientID,
+		Code:     code,
+		Name:     EventCodeNameMap[code],
+		Data:     data,
+		SentAt:   sentAt,
+	})
+}
+
+var GlobalDashboardHub = NewDashboardHub()
@@ -0,0 +1,52 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDashboardHub 测试仪表盘事件的订阅与发布。
+func TestDashboardHub(t *testing.T) {
+	hub := NewDashboardHub()
+	a := hub.Subscribe()
+	b := hub.Subscribe()
+	assert.Equal(t, 2, hub.Count())
+
+	client := NewClient("a", ClientType1)
+	hub.PublishPresence(client, true)
+	for _, ch := range []chan DashboardEvent{a, b} {
+		event := <-ch
+		assert.Equal(t, DashboardEventPresence, event.Name)
+		assert.Equal(t, "a", event.Data.(DashboardPresenceData).ClientID)
+		assert.True(t, event.Data.(DashboardPresenceData).Online)
+	}
+
+	hub.Unsubscribe(a)
+	_, ok := <-a
+	assert.False(t, ok)
+	assert.Equal(t, 1, hub.Count())
+	hub.Unsubscribe(a)
+
+	hub.PublishCommand("a", EventCodeCommandPower, "{\"power\":1}", time.Now())
+	event := <-b
+	assert.Equal(t, EventNameCommandPower, event.Data.(DashboardCommandData).Name)
+
+	hub.PublishBattery(client, 80, time.Now())
+	event = <-b
+	assert.Equal(t, DashboardEventBattery, event.Name)
+	assert.Equal(t, float64(80), event.Data.(DashboardBatteryData).SoC)
+}
+
+// TestDashboardHub_PublishNonBlocking 测试订阅者缓冲已满时发布不会阻塞。
+func TestDashboardHub_PublishNonBlocking(t *testing.T) {
+	hub := NewDashboardHub()
+	ch := hub.Subscribe()
+	client := NewClient("a", ClientType1)
+	for i := 0; i < DashboardSubscriberBufferSize*2; i++ {
+		hub.PublishConsumption(client, float64(i), nil, time.Now())
+	}
+	assert.Len(t, ch, DashboardSubscriberBufferSize)
+	hub.Unsubscribe(ch)
+}
@@ -1,8 +1,13 @@
 package common
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"sync"
+
+	"github.com/vistart/project20240227/server/models"
 )
 
 // EventInterface 表示一个事件应该实现的方法。
@@ -35,6 +40,15 @@ func (e *EventBase[T]) UnmarshalData(data string) error {
 	return json.Unmarshal([]byte(data), &e.Data)
 }
 
+// SetCommandID 设置命令事件的ID，客户端确认命令时回传该ID。非命令事件忽略。
+func (e *EventBase[T]) SetCommandID(id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if d, ok := any(&e.Data).(interface{ setCommandID(id string) }); ok {
+		d.setCommandID(id)
+	}
+}
+
 func NewEventBase[T any](code int, data T) *EventBase[T] {
 	return &EventBase[T]{
 		Code: code,
@@ -48,22 +62,33 @@ const (
 	EventCodeCommandPower        // 命令。
 	EventCodeMessage             // 消息。
 	EventCodeDisconnect
+	EventCodeCommandSetpoint // 设定温度命令。
+	EventCodeCommandBattery  // 储能充放电命令。
 )
 
 const (
-	EventNameNone         = ""
-	EventNameRegistration = "registration"
-	EventNameCommandPower = "command-power"
-	EventNameMessage      = "message"
-	EventNameDisconnect   = "disconnect"
+	EventNameNone            = ""
+	EventNameRegistration    = "registration"
+	EventNameCommandPower    = "command-power"
+	EventNameMessage         = "message"
+	EventNameDisconnect      = "disconnect"
+	EventNameCommandSetpoint = "command-setpoint"
+	EventNameCommandBattery  = "command-battery"
 )
 
 var EventCodeNameMap = map[int]string{
-	EventCodeNone:         EventNameNone,
-	EventCodeRegistration: EventNameRegistration,
-	EventCodeCommandPower: EventNameCommandPower,
-	EventCodeMessage:      EventNameMessage,
-	EventCodeDisconnect:   EventNameDisconnect,
+	EventCodeNone:            EventNameNone,
+	EventCodeRegistration:    EventNameRegistration,
+	EventCodeCommandPower:    EventNameCommandPower,
+	EventCodeMessage:         EventNameMessage,
+	EventCodeDisconnect:      EventNameDisconnect,
+	EventCodeCommandSetpoint: EventNameCommandSetpoint,
+	EventCodeCommandBattery:  EventNameCommandBattery,
+}
+
+// IsEventCodeCommand 判断事件代码是否表示命令。命令需要客户端确认，并在客户端重连时重放。
+func IsEventCodeCommand(code int) bool {
+	return code == EventCodeCommandPower || code == EventCodeCommandSetpoint || code == EventCodeCommandBattery
 }
 
 const (
@@ -94,14 +119,105 @@ func NewEventRegistrationFailed(data int) *EventBase[int] {
 	}
 }
 
+// CommandPowerUnlimited 表示取消功率上限。command-power 命令的功率为该值时，客户端取消之前的命令设定的限制，
+// 恢复设备的正常运行。例如负载切除后恢复客户端时，客户端此前没有收到过其它功率命令。
+const CommandPowerUnlimited = -1
+
 type EventCommandPowerData struct {
-	Power int `json:"power"`
+	Power int    `json:"power"`        // 功率，单位为瓦。为 CommandPowerUnlimited 时取消功率上限。
+	ID    string `json:"id,omitempty"` // 命令ID。由服务端下发时生成，客户端确认命令时回传。
+}
+
+func (d *EventCommandPowerData) setCommandID(id string) {
+	d.ID = id
+}
+
+// ErrEventCommandPowerInvalid 表示调整功率命令的功率不合法。
+var ErrEventCommandPowerInvalid = errors.New("power must be non-negative or unlimited")
+
+// Check 检查功率是否合法。功率不能为负数，CommandPowerUnlimited 除外。
+func (d *EventCommandPowerData) Check() error {
+	if d.Power < CommandPowerUnlimited {
+		return ErrEventCommandPowerInvalid
+	}
+	return nil
 }
 
 type EventCommandPower struct {
 	EventBase[EventCommandPowerData]
 }
 
+// EventCommandSetpointData 表示设定温度命令的内容。
+type EventCommandSetpointData struct {
+	Setpoint float64 `json:"setpoint"`     // 目标温度，单位为摄氏度。
+	ID       string  `json:"id,omitempty"` // 命令ID。由服务端下发时生成，客户端确认命令时回传。
+}
+
+func (d *EventCommandSetpointData) setCommandID(id string) {
+	d.ID = id
+}
+
+const (
+	CommandSetpointMin = 5.0  // 目标温度的下限，单位为摄氏度。
+	CommandSetpointMax = 35.0 // 目标温度的上限，单位为摄氏度。
+)
+
+// ErrEventCommandSetpointInvalid 表示设定温度命令的目标温度不合法。
+var ErrEventCommandSetpointInvalid = fmt.Errorf("setpoint must be between %g and %g", CommandSetpointMin, CommandSetpointMax)
+
+// Check 检查目标温度是否在 CommandSetpointMin 到 CommandSetpointMax 之间。NaN 和无穷大均不合法。
+func (d *EventCommandSetpointData) Check() error {
+	if !(d.Setpoint >= CommandSetpointMin && d.Setpoint <= CommandSetpointMax) {
+		return ErrEventCommandSetpointInvalid
+	}
+	return nil
+}
+
+type EventCommandSetpoint struct {
+	EventBase[EventCommandSetpointData]
+}
+
+const (
+	BatteryModeCharge    = "charge"    // 充电。
+	BatteryModeDischarge = "discharge" // 放电。
+	BatteryModeIdle      = "idle"      // 待机，既不充电也不放电。
+)
+
+// EventCommandBatteryData 表示储能充放电命令的内容。
+type EventCommandBatteryData struct {
+	Mode  string `json:"mode"`         // 充放电模式：charge、discharge 或 idle。
+	Power int    `json:"power"`        // 充电或放电功率，单位为瓦。idle 时为 0。
+	ID    string `json:"id,omitempty"` // 命令ID。由服务端下发时生成，客户端确认命令时回传。
+}
+
+func (d *EventCommandBatteryData) setCommandID(id string) {
+	d.ID = id
+}
+
+// ErrEventCommandBatteryInvalid 表示储能充放电命令的模式或功率不合法。
+var ErrEventCommandBatteryInvalid = errors.New("invalid battery command")
+
+// Check 检查模式和功率是否合法。功率不能为负数，idle 时功率必须为 0。
+func (d *EventCommandBatteryData) Check() error {
+	switch d.Mode {
+	case BatteryModeCharge, BatteryModeDischarge:
+		if d.Power < 0 {
+			return ErrEventCommandBatteryInvalid
+		}
+	case BatteryModeIdle:
+		if d.Power != 0 {
+			return ErrEventCommandBatteryInvalid
+		}
+	default:
+		return ErrEventCommandBatteryInvalid
+	}
+	return nil
+}
+
+type EventCommandBattery struct {
+	EventBase[EventCommandBatteryData]
+}
+
 type EventMessageData struct {
 	Message string `json:"message"`
 }
@@ -123,14 +239,84 @@ func NewEventCommand(data EventCommandPowerData) *EventBase[EventCommandPowerDat
 
 // NewEventCommandPower 实例化一个调整功率命令事件。
 func NewEventCommandPower(power int) *EventBase[EventCommandPowerData] {
-	return NewEventCommand(EventCommandPowerData{power})
+	return NewEventCommand(EventCommandPowerData{Power: power})
+}
+
+// NewEventCommandSetpoint 实例化一个设定温度命令事件。
+func NewEventCommandSetpoint(setpoint float64) *EventBase[EventCommandSetpointData] {
+	return &EventBase[EventCommandSetpointData]{
+		Code: EventCodeCommandSetpoint,
+		Data: EventCommandSetpointData{Setpoint: setpoint},
+	}
+}
+
+// NewEventCommandBattery 实例化一个储能充放电命令事件。power 为正数时充电，为负数时放电，为 0 时待机。
+func NewEventCommandBattery(power int) *EventBase[EventCommandBatteryData] {
+	data := EventCommandBatteryData{Mode: BatteryModeIdle}
+	if power > 0 {
+		data = EventCommandBatteryData{Mode: BatteryModeCharge, Power: power}
+	} else if power < 0 {
+		data = EventCommandBatteryData{Mode: BatteryModeDischarge, Power: -power}
+	}
+	return &EventBase[EventCommandBatteryData]{
+		Code: EventCodeCommandBattery,
+		Data: data,
+	}
+}
+
+// ErrEventCommandNotSupported 表示不支持的命令事件代码。
+type ErrEventCommandNotSupported struct {
+	Code int
+	error
+}
+
+func (e ErrEventCommandNotSupported) Error() string {
+	return fmt.Sprintf("command not supported: %d", e.Code)
+}
+
+// NewEventCommandFromData 根据命令代码及序列化后的 data 实例化命令事件。
+// data 必须能严格解析为该命令对应的数据结构，否则返回错误。目前支持 EventCodeCommandPower、EventCodeCommandSetpoint 和 EventCodeCommandBattery。
+func NewEventCommandFromData(code int, data string) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(data)))
+	decoder.DisallowUnknownFields()
+	switch code {
+	case EventCodeCommandPower:
+		d := EventCommandPowerData{}
+		if err := decoder.Decode(&d); err != nil {
+			return nil, err
+		}
+		if err := d.Check(); err != nil {
+			return nil, err
+		}
+		return NewEventCommand(d), nil
+	case EventCodeCommandSetpoint:
+		d := EventCommandSetpointData{}
+		if err := decoder.Decode(&d); err != nil {
+			return nil, err
+		}
+		if err := d.Check(); err != nil {
+			return nil, err
+		}
+		return &EventBase[EventCommandSetpointData]{Code: code, Data: d}, nil
+	case EventCodeCommandBattery:
+		d := EventCommandBatteryData{}
+		if err := decoder.Decode(&d); err != nil {
+			return nil, err
+		}
+		if err := d.Check(); err != nil {
+			return nil, err
+		}
+		return &EventBase[EventCommandBatteryData]{Code: code, Data: d}, nil
+	default:
+		return nil, ErrEventCommandNotSupported{Code: code}
+	}
 }
 
 const (
 	// ClientActivityOff 表示设备不活跃。
-	ClientActivityOff = iota
+	ClientActivityOff = models.ClientActivityOff
 	// ClientActivityOn 表示设备活跃。
-	ClientActivityOn
+	ClientActivityOn = models.ClientActivityOn
 )
 
 func NewEventMessage(data string) *EventBase[EventMessageData] {
@@ -0,0 +1,69 @@
+package common
+
+import (
+	"sync"
+	"time"
+)
+
+// ReplayEvent 表示已向客户端发送的一个 SSE 事件。
+type ReplayEvent struct {
+	ID   uint64
+	Name string
+	Data string
+}
+
+// EventReplayBuffer 为某个客户端分配单调递增的事件ID，并保存最近发送的可重放事件，
+// 以便客户端重连时根据 Last-Event-ID 重放断开期间可能错过的事件。
+// 缓冲区跨越客户端的多次连接存在，最多保存 size 个事件，超出时丢弃最早的事件。
+type EventReplayBuffer struct {
+	size    int
+	lastID  uint64
+	evicted uint64        // 最近被丢弃的事件的ID。
+	events  []ReplayEvent // 按ID正序排列。
+	mu      sync.Mutex
+}
+
+// NewEventReplayBuffer 实例化事件重放缓冲区。
+// 事件ID从 start 之后开始分配。以创建时的毫秒时间戳为 start 时，服务端重启后事件ID仍然递增。
+func NewEventReplayBuffer(size int, start uint64) *EventReplayBuffer {
+	return &EventReplayBuffer{
+		size:   size,
+		lastID: start,
+		events: make([]ReplayEvent, 0, size),
+	}
+}
+
+// Append 为事件分配下一个ID并返回。replayable 为 true 时将事件保存到缓冲区。
+func (b *EventReplayBuffer) Append(name string, data string, replayable bool) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastID++
+	if replayable && b.size > 0 {
+		if len(b.events) == b.size {
+			b.evicted = b.events[0].ID
+			copy(b.events, b.events[1:])
+			b.events = b.events[:len(b.events)-1]
+		}
+		b.events = append(b.events, ReplayEvent{ID: b.lastID, Name: name, Data: data})
+	}
+	return b.lastID
+}
+
+// Since 返回ID大于 lastID 的可重放事件。
+// 如果其中有事件已被丢弃，则 complete 为 false。
+func (b *EventReplayBuffer) Since(lastID uint64) (events []ReplayEvent, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	complete = lastID >= b.evicted
+	for _, event := range b.events {
+		if event.ID > lastID {
+			events = append(events, event)
+		}
+	}
+	return events, complete
+}
+
+// newEventReplayBufferStart 返回新建缓冲区的起始ID。
+func newEventReplayBufferStart() uint64 {
+	return uint64(time.Now().UnixMilli())
+}
@@ -0,0 +1,33 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEventReplayBuffer 测试分配事件ID及按 Last-Event-ID 重放。仅保存可重放的事件，超出容量时丢弃最早的事件。
+func TestEventReplayBuffer(t *testing.T) {
+	b := NewEventReplayBuffer(2, 100)
+	assert.Equal(t, uint64(101), b.Append(EventNameMessage, "a", false))
+	assert.Equal(t, uint64(102), b.Append(EventNameCommandPower, "b", true))
+	assert.Equal(t, uint64(103), b.Append(EventNameCommandPower, "c", true))
+
+	events, complete := b.Since(101)
+	assert.True(t, complete)
+	assert.Equal(t, []ReplayEvent{{ID: 102, Name: EventNameCommandPower, Data: "b"}, {ID: 103, Name: EventNameCommandPower, Data: "c"}}, events)
+
+	events, complete = b.Since(103)
+	assert.True(t, complete)
+	assert.Empty(t, events)
+
+	assert.Equal(t, uint64(104), b.Append(EventNameCommandPower, "d", true))
+	events, complete = b.Since(101)
+	assert.False(t, complete)
+	assert.Len(t, events, 2)
+	assert.Equal(t, uint64(103), events[0].ID)
+
+	events, complete = b.Since(102)
+	assert.True(t, complete)
+	assert.Len(t, events, 2)
+}
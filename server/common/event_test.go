@@ -3,6 +3,7 @@ package common
 import (
 	"encoding/json"
 	"log"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -25,7 +26,7 @@ func TestNewEventRegistrationSucceeded(t *testing.T) {
 
 // TestNewEventCommand 测试实例化命令事件。
 func TestNewEventCommand(t *testing.T) {
-	event := NewEventCommand(EventCommandPowerData{0})
+	event := NewEventCommand(EventCommandPowerData{Power: 0})
 	content, err := json.Marshal(event)
 	assert.Equal(t, "{\"code\":2,\"data\":{\"power\":0}}", string(content))
 	assert.Nil(t, err)
@@ -33,14 +34,128 @@ func TestNewEventCommand(t *testing.T) {
 
 // TestEvent_Unmarshal 测试事件反序列化。
 func TestEvent_Unmarshal(t *testing.T) {
-	event := NewEventCommand(EventCommandPowerData{0})
+	event := NewEventCommand(EventCommandPowerData{Power: 0})
 	content, err := json.Marshal(event)
 	assert.Equal(t, "{\"code\":2,\"data\":{\"power\":0}}", string(content))
 	assert.Nil(t, err)
 
-	event1 := NewEventCommand(EventCommandPowerData{1})
+	event1 := NewEventCommand(EventCommandPowerData{Power: 1})
 	assert.Equal(t, EventCommandPowerData(EventCommandPowerData{Power: 1}), event1.Data)
 	err = json.Unmarshal(content, &event1)
 	assert.Nil(t, err)
 	assert.Equal(t, EventCommandPowerData(EventCommandPowerData{Power: 0}), event1.Data)
 }
+
+// TestNewEventCommandFromData 测试根据命令代码和数据实例化命令事件。
+func TestNewEventCommandFromData(t *testing.T) {
+	event, err := NewEventCommandFromData(EventCodeCommandPower, "{\"power\":50}")
+	assert.Nil(t, err)
+	assert.Equal(t, EventCommandPowerData{Power: 50}, event.(*EventBase[EventCommandPowerData]).Data)
+
+	_, err = NewEventCommandFromData(EventCodeCommandPower, "{\"watt\":50}")
+	assert.NotNil(t, err)
+
+	_, err = NewEventCommandFromData(EventCodeCommandPower, "50")
+	assert.NotNil(t, err)
+
+	event, err = NewEventCommandFromData(EventCodeCommandPower, "{\"power\":-1}")
+	assert.Nil(t, err)
+	assert.Equal(t, EventCommandPowerData{Power: CommandPowerUnlimited}, event.(*EventBase[EventCommandPowerData]).Data)
+
+	_, err = NewEventCommandFromData(EventCodeCommandPower, "{\"power\":-2}")
+	assert.ErrorIs(t, err, ErrEventCommandPowerInvalid)
+
+	event, err = NewEventCommandFromData(EventCodeCommandSetpoint, "{\"setpoint\":21.5}")
+	assert.Nil(t, err)
+	assert.Equal(t, EventCommandSetpointData{Setpoint: 21.5}, event.(*EventBase[EventCommandSetpointData]).Data)
+
+	_, err = NewEventCommandFromData(EventCodeCommandSetpoint, "{\"power\":50}")
+	assert.NotNil(t, err)
+
+	_, err = NewEventCommandFromData(EventCodeCommandSetpoint, "{\"setpoint\":40}")
+	assert.ErrorIs(t, err, ErrEventCommandSetpointInvalid)
+
+	event, err = NewEventCommandFromData(EventCodeCommandBattery, "{\"mode\":\"charge\",\"power\":2000}")
+	assert.Nil(t, err)
+	assert.Equal(t, EventCommandBatteryData{Mode: BatteryModeCharge, Power: 2000}, event.(*EventBase[EventCommandBatteryData]).Data)
+
+	_, err = NewEventCommandFromData(EventCodeCommandBattery, "{\"mode\":\"boost\",\"power\":2000}")
+	assert.ErrorIs(t, err, ErrEventCommandBatteryInvalid)
+
+	_, err = NewEventCommandFromData(EventCodeCommandBattery, "{\"mode\":\"idle\",\"power\":100}")
+	assert.ErrorIs(t, err, ErrEventCommandBatteryInvalid)
+
+	_, err = NewEventCommandFromData(EventCodeMessage, "{\"message\":\"\"}")
+	assert.ErrorAs(t, err, &ErrEventCommandNotSupported{})
+}
+
+// TestNewEventCommandBattery 测试以有符号功率实例化储能充放电命令。
+func TestNewEventCommandBattery(t *testing.T) {
+	assert.Equal(t, EventCommandBatteryData{Mode: BatteryModeCharge, Power: 1500}, NewEventCommandBattery(1500).Data)
+	assert.Equal(t, EventCommandBatteryData{Mode: BatteryModeDischarge, Power: 800}, NewEventCommandBattery(-800).Data)
+	assert.Equal(t, EventCommandBatteryData{Mode: BatteryModeIdle}, NewEventCommandBattery(0).Data)
+	assert.Equal(t, "{\"mode\":\"idle\",\"power\":0}", NewEventCommandBattery(0).MarshalData())
+}
+
+// TestEventBase_SetCommandID 测试设置命令ID。命令ID随命令内容一起序列化，非命令事件忽略。
+func TestEventBase_SetCommandID(t *testing.T) {
+	event := NewEventCommandPower(100)
+	assert.Equal(t, "{\"power\":100}", event.MarshalData())
+	event.SetCommandID("abc")
+	assert.Equal(t, "{\"power\":100,\"id\":\"abc\"}", event.MarshalData())
+
+	setpoint := NewEventCommandSetpoint(22)
+	setpoint.SetCommandID("def")
+	assert.Equal(t, "{\"setpoint\":22,\"id\":\"def\"}", setpoint.MarshalData())
+
+	message := NewEventMessage("hello")
+	message.SetCommandID("abc")
+	assert.Equal(t, "{\"message\":\"hello\"}", message.MarshalData())
+}
+
+// TestEventCommandPowerData_Check 测试功率不能为负数，CommandPowerUnlimited 除外。
+func TestEventCommandPowerData_Check(t *testing.T) {
+	tests := []struct {
+		power int
+		valid bool
+	}{
+		{0, true},
+		{500, true},
+		{CommandPowerUnlimited, true},
+		{-2, false},
+		{-500, false},
+	}
+	for _, tt := range tests {
+		d := EventCommandPowerData{Power: tt.power}
+		if tt.valid {
+			assert.Nil(t, d.Check(), tt.power)
+		} else {
+			assert.ErrorIs(t, d.Check(), ErrEventCommandPowerInvalid, tt.power)
+		}
+	}
+}
+
+// TestEventCommandSetpointData_Check 测试目标温度的范围。
+func TestEventCommandSetpointData_Check(t *testing.T) {
+	tests := []struct {
+		setpoint float64
+		valid    bool
+	}{
+		{CommandSetpointMin, true},
+		{21.5, true},
+		{CommandSetpointMax, true},
+		{CommandSetpointMin - 0.1, false},
+		{CommandSetpointMax + 0.1, false},
+		{math.NaN(), false},
+		{math.Inf(1), false},
+		{math.Inf(-1), false},
+	}
+	for _, tt := range tests {
+		d := EventCommandSetpointData{Setpoint: tt.setpoint}
+		if tt.valid {
+			assert.Nil(t, d.Check(), tt.setpoint)
+		} else {
+			assert.ErrorIs(t, d.Check(), ErrEventCommandSetpointInvalid, tt.setpoint)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package common
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vistart/project20240227/server/models"
+)
+
+// LivePower 表示某个在线客户端最近一次报告的功率。
+type LivePower struct {
+	ClientID   string            `json:"client_id"`
+	Type       models.ClientType `json:"type"`
+	Power      float64           `json:"power"`       // 单位：瓦。为负数时表示发电。
+	RecordedAt time.Time         `json:"recorded_at"` // 客户端记录时间
+	ReceivedAt time.Time         `json:"received_at"` // 服务端接收时间
+}
+
+// HomePower 在内存中保存每个在线客户端最近一次报告的功率，用于计算全屋实时功率。
+type HomePower struct {
+	powers map[string]LivePower // 键为客户端ID。
+	mu     sync.RWMutex         // powers 读写锁。
+}
+
+func NewHomePower() *HomePower {
+	return &HomePower{powers: make(map[string]LivePower)}
+}
+
+// Update 更新客户端最近一次报告的功率。早于已保存记录的报告会被忽略。
+func (h *HomePower) Update(client *Client, power float64, recordedAt time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if last, existed := h.powers[client.ID()]; existed && recordedAt.Before(last.RecordedAt) {
+		return
+	}
+	h.powers[client.ID()] = LivePower{
+		ClientID:   client.ID(),
+		Type:       client.Type(),
+		Power:      power,
+		RecordedAt: recordedAt,
+		ReceivedAt: time.Now(),
+	}
+}
+
+// Remove 移除客户端。客户端断开后调用。
+func (h *HomePower) Remove(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.powers, id)
+}
+
+// Get 获取某个客户端最近一次报告的功率。
+func (h *HomePower) Get(id string) (LivePower, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	power, existed := h.powers[id]
+	return power, existed
+}
+
+// Snapshot 返回所有客户端最近一次报告的功率，按客户端ID排序。
+func (h *HomePower) Snapshot() []LivePower {
+	h.mu.RLock()
+	powers := make([]LivePower, 0, len(h.powers))
+	for _, power := range h.powers {
+		powers = append(powers, power)
+	}
+	h.mu.RUnlock()
+	sort.Slice(powers, func(i, j int) bool {
+		return powers[i].ClientID < powers[j].ClientID
+	})
+	return powers
+}
+
+// Total 返回所有客户端功率之和，即全屋的净功率。
+func (h *HomePower) Total() float64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var total float64
+	for _, power := range h.powers {
+		total += power.Power
+	}
+	return total
+}
+
+// HomePowerBalance 表示全屋的功率平衡，单位均为瓦。
+type HomePowerBalance struct {
+	Load            float64 `json:"load"`             // 用电客户端的功率之和。
+	Generation      float64 `json:"generation"`       // 发电客户端（报告负功率）的发电功率之和。
+	Import          float64 `json:"import"`           // 从电网取用的功率。
+	Export          float64 `json:"export"`           // 向电网输出的功率。
+	SelfConsumption float64 `json:"self_consumption"` // 发电功率中由全屋自用的部分。
+}
+
+// NewHomePowerBalance 根据各客户端的功率计算全屋的功率平衡。
+// 发电优先供全屋自用，不足部分从电网取用，剩余部分向电网输出。
+func NewHomePowerBalance(powers []LivePower) HomePowerBalance {
+	var balance HomePowerBalance
+	for _, power := range powers {
+		if power.Power >= 0 {
+			balance.Load += power.Power
+		} else {
+			balance.Generation -= power.Power
+		}
+	}
+	if balance.Load > balance.Generation {
+		balance.Import = balance.Load - balance.Generation
+		balance.SelfConsumption = balance.Generation
+	} else {
+		balance.Export = balance.Generation - balance.Load
+		balance.SelfConsumption = balance.Load
+	}
+	return balance
+}
+
+// Balance 返回全屋的功率平衡。
+func (h *HomePower) Balance() HomePowerBalance {
+	return NewHomePowerBalance(h.Snapshot())
+}
+
+var GlobalHomePower = NewHomePower()
@@ -0,0 +1,48 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHomePower 测试全屋实时功率。
+func TestHomePower(t *testing.T) {
+	home := NewHomePower()
+	now := time.Now()
+	a := NewClient("a", ClientType1)
+	b := NewClient("b", ClientType2)
+
+	home.Update(a, 100, now)
+	home.Update(b, 50, now)
+	assert.Equal(t, float64(150), home.Total())
+
+	// 较早的报告被忽略。
+	home.Update(a, 10, now.Add(-time.Second))
+	assert.Equal(t, float64(150), home.Total())
+	home.Update(a, 200, now.Add(time.Second))
+	assert.Equal(t, float64(250), home.Total())
+
+	snapshot := home.Snapshot()
+	assert.Len(t, snapshot, 2)
+	assert.Equal(t, "a", snapshot[0].ClientID)
+	assert.Equal(t, float64(200), snapshot[0].Power)
+
+	home.Remove("a")
+	assert.Equal(t, float64(50), home.Total())
+	_, existed := home.Get("a")
+	assert.False(t, existed)
+}
+
+// TestNewHomePowerBalance 测试全屋功率平衡。发电优先自用，剩余部分向电网输出。
+func TestNewHomePowerBalance(t *testing.T) {
+	balance := NewHomePowerBalance([]LivePower{{Power: 300}, {Power: 200}, {Power: -800}})
+	assert.Equal(t, HomePowerBalance{Load: 500, Generation: 800, Export: 300, SelfConsumption: 500}, balance)
+
+	balance = NewHomePowerBalance([]LivePower{{Power: 1000}, {Power: -400}})
+	assert.Equal(t, HomePowerBalance{Load: 1000, Generation: 400, Import: 600, SelfConsumption: 400}, balance)
+
+	balance = NewHomePowerBalance(nil)
+	assert.Equal(t, HomePowerBalance{}, balance)
+}
@@ -0,0 +1,222 @@
+package common
+
+import (
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vistart/project20240227/server/models"
+)
+
+const (
+	LoadSheddingActionNone    = iota // 无动作。
+	LoadSheddingActionShed           // 切除一个客户端。
+	LoadSheddingActionRestore        // 恢复一个客户端。
+)
+
+// LoadSheddingCandidate 表示一个在线且报告过功率的客户端。
+type LoadSheddingCandidate struct {
+	ClientID string
+	Priority int32
+	Power    float64
+}
+
+// LoadSheddingRecord 表示一个已被切除的客户端。
+type LoadSheddingRecord struct {
+	ClientID string    `json:"client_id"`
+	Priority int32     `json:"priority"`
+	Previous int       `json:"previous"` // 切除前测得的功率，用于估算恢复后的总功率。
+	Restore  int       `json:"restore"`  // 恢复时下发的功率：切除前最后生效的功率命令的值，没有时为 CommandPowerUnlimited。
+	ShedAt   time.Time `json:"shed_at"`
+}
+
+// LoadShedder 在全屋总功率持续超出上限时，按优先级从低到高依次切除客户端；
+// 在总功率持续留有余量时，按切除的相反顺序依次恢复客户端。
+// 每次切除或恢复后重新计时，以便客户端报告新的功率后再做下一次判断。
+type LoadShedder struct {
+	config     ConfigLoadShedding
+	dispatcher models.CommandDispatcher
+	shed       []LoadSheddingRecord // 已切除的客户端，按切除顺序排列。
+	overSince  time.Time            // 总功率开始超出上限的时刻。
+	underSince time.Time            // 开始满足恢复条件的时刻。
+	mu         sync.Mutex
+}
+
+func NewLoadShedder(config ConfigLoadShedding, dispatcher models.CommandDispatcher) *LoadShedder {
+	return &LoadShedder{
+		config:     config,
+		dispatcher: dispatcher,
+		shed:       make([]LoadSheddingRecord, 0),
+	}
+}
+
+// Serve 提供服务。每秒检查一次全屋总功率。
+func (l *LoadShedder) Serve() {
+	ticker := time.NewTicker(time.Second)
+	for now := range ticker.C {
+		l.Run(now)
+	}
+}
+
+// Run 根据当前全屋实时功率执行一次切除或恢复。
+func (l *LoadShedder) Run(now time.Time) {
+	powers := GlobalHomePower.Snapshot()
+	ids := make([]string, len(powers))
+	for i, power := range powers {
+		ids[i] = power.ClientID
+	}
+	priorities, err := models.GetClientPriorities(DB, ids)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+	var total float64
+	candidates := make([]LoadSheddingCandidate, len(powers))
+	for i, power := range powers {
+		total += power.Power
+		candidates[i] = LoadSheddingCandidate{
+			ClientID: power.ClientID,
+			Priority: priorities[power.ClientID],
+			Power:    power.Power,
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	action, record := l.decide(now, total, candidates)
+	switch action {
+	case LoadSheddingActionShed:
+		l.overSince = now
+		record.Restore = restorePower(record.ClientID, now)
+		if err := l.send(record.ClientID, l.config.ShedPower, models.ClientCommandExecutionReasonLoadShedding); err != nil {
+			log.Printf("Load shedding of client[%s] failed: %s", record.ClientID, err.Error())
+			return
+		}
+		l.shed = append(l.shed, record)
+		log.Printf("Client[%s] shed: total %.0f W exceeds limit %.0f W.", record.ClientID, total, l.config.Limit)
+	case LoadSheddingActionRestore:
+		l.underSince = now
+		if err := l.send(record.ClientID, record.Restore, models.ClientCommandExecutionReasonLoadRestore); err != nil {
+			log.Printf("Load restoring of client[%s] failed: %s", record.ClientID, err.Error())
+			return
+		}
+		l.shed = l.shed[:len(l.shed)-1]
+		log.Printf("Client[%s] restored to %d W.", record.ClientID, record.Restore)
+	}
+}
+
+// decide 根据 now 时刻的总功率和候选客户端决定下一步动作，并更新计时。
+// 已断开的客户端不再视为已切除。调用者须持有锁。
+func (l *LoadShedder) decide(now time.Time, total float64, candidates []LoadSheddingCandidate) (int, LoadSheddingRecord) {
+	online := make(map[string]LoadSheddingCandidate, len(candidates))
+	for _, candidate := range candidates {
+		online[candidate.ClientID] = candidate
+	}
+	shed := l.shed[:0]
+	for _, record := range l.shed {
+		if _, existed := online[record.ClientID]; existed {
+			shed = append(shed, record)
+		}
+	}
+	l.shed = shed
+
+	if total > l.config.Limit {
+		l.underSince = time.Time{}
+		if l.overSince.IsZero() {
+			l.overSince = now
+		}
+		if now.Sub(l.overSince) < time.Duration(l.config.ShedAfter)*time.Second {
+			return LoadSheddingActionNone, LoadSheddingRecord{}
+		}
+		if target, ok := l.selectShed(candidates); ok {
+			return LoadSheddingActionShed, LoadSheddingRecord{
+				ClientID: target.ClientID,
+				Priority: target.Priority,
+				Previous: int(math.Round(target.Power)),
+				ShedAt:   now,
+			}
+		}
+		return LoadSheddingActionNone, LoadSheddingRecord{}
+	}
+
+	l.overSince = time.Time{}
+	if len(l.shed) == 0 {
+		l.underSince = time.Time{}
+		return LoadSheddingActionNone, LoadSheddingRecord{}
+	}
+	last := l.shed[len(l.shed)-1]
+	projected := total - online[last.ClientID].Power + float64(last.Previous)
+	if projected > l.config.Limit-l.config.RestoreMargin {
+		l.underSince = time.Time{}
+		return LoadSheddingActionNone, LoadSheddingRecord{}
+	}
+	if l.underSince.IsZero() {
+		l.underSince = now
+	}
+	if now.Sub(l.underSince) < time.Duration(l.config.RestoreAfter)*time.Second {
+		return LoadSheddingActionNone, LoadSheddingRecord{}
+	}
+	return LoadSheddingActionRestore, last
+}
+
+// selectShed 选出下一个切除的客户端：尚未切除、功率高于切除功率的客户端中，优先级最低者；优先级相同时，功率最高者。
+func (l *LoadShedder) selectShed(candidates []LoadSheddingCandidate) (LoadSheddingCandidate, bool) {
+	shed := make(map[string]bool, len(l.shed))
+	for _, record := range l.shed {
+		shed[record.ClientID] = true
+	}
+	eligible := make([]LoadSheddingCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		if !shed[candidate.ClientID] && candidate.Power > float64(l.config.ShedPower) {
+			eligible = append(eligible, candidate)
+		}
+	}
+	if len(eligible) == 0 {
+		return LoadSheddingCandidate{}, false
+	}
+	sort.Slice(eligible, func(i, j int) bool {
+		if eligible[i].Priority != eligible[j].Priority {
+			return eligible[i].Priority < eligible[j].Priority
+		}
+		if eligible[i].Power != eligible[j].Power {
+			return eligible[i].Power > eligible[j].Power
+		}
+		return eligible[i].ClientID < eligible[j].ClientID
+	})
+	return eligible[0], true
+}
+
+// restorePower 返回恢复客户端时下发的功率：before 之前最后一条已确认、不是因切除负载而发送的功率命令的实际生效值。
+// 切除前设备没有被命令限制功率时，返回 CommandPowerUnlimited，客户端据此取消切除时设定的限制。
+func restorePower(clientID string, before time.Time) int {
+	execution := models.GetLastAckedClientCommandExecution(DB, clientID, EventCodeCommandPower, before, models.ClientCommandExecutionReasonLoadShedding)
+	if execution == nil || execution.AppliedValue == nil {
+		return CommandPowerUnlimited
+	}
+	power, err := strconv.Atoi(*execution.AppliedValue)
+	if err != nil {
+		return CommandPowerUnlimited
+	}
+	return power
+}
+
+// send 向客户端下发功率命令，并以 reason 记录到命令执行历史。
+func (l *LoadShedder) send(clientID string, power int, reason string) error {
+	command := NewEventCommandPower(power)
+	_, err := l.dispatcher.DispatchCommand(clientID, command.Code, command.MarshalData(), reason)
+	return err
+}
+
+// Shed 返回目前已切除的客户端，按切除顺序排列。
+func (l *LoadShedder) Shed() []LoadSheddingRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	shed := make([]LoadSheddingRecord, len(l.shed))
+	copy(shed, l.shed)
+	return shed
+}
+
+var GlobalLoadShedder *LoadShedder
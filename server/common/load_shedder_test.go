@@ -0,0 +1,93 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testLoadSheddingConfig = ConfigLoadShedding{
+	Enabled:       true,
+	Limit:         1000,
+	ShedAfter:     10,
+	ShedPower:     0,
+	RestoreMargin: 100,
+	RestoreAfter:  30,
+}
+
+// TestLoadShedder_decide 测试负载切除与恢复的判断。
+func TestLoadShedder_decide(t *testing.T) {
+	l := NewLoadShedder(testLoadSheddingConfig, nil)
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	candidates := []LoadSheddingCandidate{
+		{ClientID: "heater", Priority: 1, Power: 600},
+		{ClientID: "fridge", Priority: 9, Power: 200},
+		{ClientID: "washer", Priority: 1, Power: 400},
+	}
+
+	// 超出上限，但持续时间不足。
+	action, _ := l.decide(now, 1200, candidates)
+	assert.Equal(t, LoadSheddingActionNone, action)
+	action, _ = l.decide(now.Add(9*time.Second), 1200, candidates)
+	assert.Equal(t, LoadSheddingActionNone, action)
+
+	// 持续超出上限后，切除优先级最低且功率最高的客户端。
+	action, record := l.decide(now.Add(10*time.Second), 1200, candidates)
+	assert.Equal(t, LoadSheddingActionShed, action)
+	assert.Equal(t, "heater", record.ClientID)
+	assert.Equal(t, 600, record.Previous)
+	l.shed = append(l.shed, record)
+	l.overSince = now.Add(10 * time.Second)
+
+	// 切除后客户端报告了新的功率，总功率低于上限，但恢复后预计超出上限减去余量，不恢复。
+	candidates[0].Power = 0
+	action, _ = l.decide(now.Add(11*time.Second), 600, candidates)
+	assert.Equal(t, LoadSheddingActionNone, action)
+	assert.True(t, l.underSince.IsZero())
+
+	// 总功率降低后，持续满足恢复条件才恢复。
+	candidates[2].Power = 100
+	action, _ = l.decide(now.Add(20*time.Second), 300, candidates)
+	assert.Equal(t, LoadSheddingActionNone, action)
+	action, record = l.decide(now.Add(50*time.Second), 300, candidates)
+	assert.Equal(t, LoadSheddingActionRestore, action)
+	assert.Equal(t, "heater", record.ClientID)
+	assert.Equal(t, 600, record.Previous)
+}
+
+// TestLoadShedder_decideOrder 测试按切除的相反顺序恢复，且已断开的客户端不再视为已切除。
+func TestLoadShedder_decideOrder(t *testing.T) {
+	l := NewLoadShedder(testLoadSheddingConfig, nil)
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	candidates := []LoadSheddingCandidate{
+		{ClientID: "a", Priority: 1, Power: 0},
+		{ClientID: "b", Priority: 2, Power: 0},
+		{ClientID: "c", Priority: 3, Power: 100},
+	}
+	l.shed = []LoadSheddingRecord{
+		{ClientID: "a", Priority: 1, Previous: 300},
+		{ClientID: "b", Priority: 2, Previous: 300},
+	}
+	l.decide(now, 100, candidates)
+	action, record := l.decide(now.Add(30*time.Second), 100, candidates)
+	assert.Equal(t, LoadSheddingActionRestore, action)
+	assert.Equal(t, "b", record.ClientID)
+
+	// b 断开后，下一个恢复的是 a。
+	action, record = l.decide(now.Add(60*time.Second), 100, candidates[0:1])
+	assert.Equal(t, LoadSheddingActionRestore, action)
+	assert.Equal(t, "a", record.ClientID)
+	assert.Len(t, l.shed, 1)
+}
+
+// TestLoadShedder_selectShed 测试没有可切除的客户端。
+func TestLoadShedder_selectShed(t *testing.T) {
+	l := NewLoadShedder(testLoadSheddingConfig, nil)
+	l.shed = []LoadSheddingRecord{{ClientID: "a"}}
+	_, ok := l.selectShed([]LoadSheddingCandidate{
+		{ClientID: "a", Power: 500},
+		{ClientID: "b", Power: 0},
+	})
+	assert.False(t, ok)
+}
@@ -0,0 +1,58 @@
+package common
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RedactedQueryParams 表示记录日志时需要隐去值的查询参数。
+var RedactedQueryParams = []string{"token"}
+
+// RedactQuery 将 path 中 RedactedQueryParams 查询参数的值替换为 REDACTED，其余部分保持不变。
+func RedactQuery(path string) string {
+	i := strings.IndexByte(path, '?')
+	if i < 0 {
+		return path
+	}
+	params := strings.Split(path[i+1:], "&")
+	for j, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(key); err == nil {
+			key = name
+		}
+		for _, redacted := range RedactedQueryParams {
+			if key == redacted {
+				params[j] = redacted + "=REDACTED"
+			}
+		}
+	}
+	return path[:i+1] + strings.Join(params, "&")
+}
+
+// Logger 返回记录请求日志的中间件。格式与 gin 默认的日志相同，但隐去令牌等敏感的查询参数。
+func Logger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		var statusColor, methodColor, resetColor string
+		if param.IsOutputColor() {
+			statusColor = param.StatusCodeColor()
+			methodColor = param.MethodColor()
+			resetColor = param.ResetColor()
+		}
+		if param.Latency > time.Minute {
+			param.Latency = param.Latency.Truncate(time.Second)
+		}
+		return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			statusColor, param.StatusCode, resetColor,
+			param.Latency,
+			param.ClientIP,
+			methodColor, param.Method, resetColor,
+			RedactQuery(param.Path),
+			param.ErrorMessage,
+		)
+	})
+}
@@ -0,0 +1,25 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRedactQuery 测试隐去日志中的令牌查询参数。
+func TestRedactQuery(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/user/stream", "/user/stream"},
+		{"/user/stream?token=abc", "/user/stream?token=REDACTED"},
+		{"/user/stream?a=1&token=abc&b=2", "/user/stream?a=1&token=REDACTED&b=2"},
+		{"/user/stream?to%6Ben=abc", "/user/stream?token=REDACTED"},
+		{"/user/stream?token", "/user/stream?token=REDACTED"},
+		{"/user/stream?tokens=abc&", "/user/stream?tokens=abc&"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, RedactQuery(tt.path))
+	}
+}
@@ -0,0 +1,118 @@
+package common
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/vistart/project20240227/server/models"
+)
+
+// PendingCommandQueue 管理等待离线客户端连接后下发的命令。
+// 命令保存在 client_pending_command 中，客户端连接后按加入队列的顺序下发仍在有效期内的命令。
+type PendingCommandQueue struct {
+	sessions *SessionManager
+	locks    map[string]*sync.Mutex // 每个客户端一把锁，避免客户端快速重连时重复下发。键为客户端ID。
+	mu       sync.Mutex
+}
+
+func NewPendingCommandQueue(sessions *SessionManager) *PendingCommandQueue {
+	return &PendingCommandQueue{
+		sessions: sessions,
+		locks:    make(map[string]*sync.Mutex),
+	}
+}
+
+// Enqueue 将命令加入客户端的队列。expiresAt 为空时不过期。
+// 命令在加入队列前检查是否合法。如果加入队列时客户端恰好已连接，则立即下发。
+func (q *PendingCommandQueue) Enqueue(clientID string, code int, data string, reason string, expiresAt *time.Time) (*models.ClientPendingCommand, error) {
+	if _, err := NewEventCommandFromData(code, data); err != nil {
+		return nil, err
+	}
+	command := models.NewClientPendingCommand(clientID, code, data, reason, expiresAt)
+	if _, err := models.EnqueueClientPendingCommand(DB, command); err != nil {
+		return nil, err
+	}
+	if q.sessions.GetClient(clientID) != nil {
+		go q.Flush(clientID)
+	}
+	return command, nil
+}
+
+func (q *PendingCommandQueue) lock(clientID string) *sync.Mutex {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	lock, existed := q.locks[clientID]
+	if !existed {
+		lock = &sync.Mutex{}
+		q.locks[clientID] = lock
+	}
+	return lock
+}
+
+// Flush 按顺序向客户端下发队列中的命令，已过期的命令标记为已过期。
+// 每条命令先标记为正在下发，标记成功后才下发，以免与取消或另一次下发冲突。
+// 某条命令下发失败（例如客户端再次断开）时恢复为等待下发并停止，其余命令留待下次连接时下发，以保持顺序。
+func (q *PendingCommandQueue) Flush(clientID string) {
+	lock := q.lock(clientID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	client, err := models.GetClient(DB, clientID)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+	commands, err := client.GetDuePendingCommands(DB)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+	for i := range commands {
+		command := &commands[i]
+		now := time.Now()
+		if command.IsExpired(now) {
+			if _, err := command.MarkExpired(DB); err != nil {
+				log.Println(err.Error())
+			}
+			continue
+		}
+		if _, err := command.Claim(DB); err != nil {
+			// 已被取消或已下发。
+			if !errors.Is(err, models.ErrPendingCommandNotPending) {
+				log.Println(err.Error())
+			}
+			continue
+		}
+		execution, err := q.sessions.DispatchCommand(clientID, command.Code, command.Data, command.Reason)
+		if err != nil {
+			log.Printf("Pending command[%d] to client[%s] not delivered: %s", command.ID, clientID, err.Error())
+			if _, err := command.Release(DB); err != nil {
+				log.Println(err.Error())
+			}
+			return
+		}
+		if _, err := command.MarkDelivered(DB, execution, now); err != nil {
+			log.Println(err.Error())
+		}
+		log.Printf("Pending command[%d] delivered to client[%s].", command.ID, clientID)
+	}
+}
+
+// Serve 提供服务。启动时恢复上次运行时下发中断的命令；
+// 之后每分钟将过期的命令标记为已过期，使客户端长期离线时也能在队列中看到命令已过期。
+func (q *PendingCommandQueue) Serve() {
+	if _, err := models.ReleaseClientPendingCommands(DB); err != nil {
+		log.Println(err.Error())
+	}
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if _, err := models.ExpireClientPendingCommands(DB, now); err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
+
+var GlobalPendingCommandQueue *PendingCommandQueue
@@ -0,0 +1,303 @@
+package common
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/vistart/project20240227/server/analytics"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// DeferrablePlanSlot 表示计划时段的长度。计划按该长度对齐划分时段。
+const DeferrablePlanSlot = 15 * time.Minute
+
+// DeferrableMaxHorizon 表示用电需求的截止时间距现在的最长时间。计划的时段数随之增长，需加以限制。
+const DeferrableMaxHorizon = 7 * 24 * time.Hour
+
+// DeferrablePlan 表示某个可延后负载当前的运行计划。
+type DeferrablePlan struct {
+	ClientID  string          `json:"client_id"`
+	Required  float64         `json:"required"`  // 所需能量，单位：千瓦时
+	Delivered float64         `json:"delivered"` // 自开始以来已消耗的能量，单位：千瓦时
+	Remaining float64         `json:"remaining"` // 尚需的能量，单位：千瓦时
+	Deadline  time.Time       `json:"deadline"`
+	MaxPower  int             `json:"max_power"`
+	TariffID  uint64          `json:"tariff_id"` // 计划所依据的电价方案。没有默认电价时为 0，此时计划尽早运行。
+	Currency  string          `json:"currency"`
+	PlannedAt time.Time       `json:"planned_at"`
+	Plan      *analytics.Plan `json:"plan"`
+}
+
+// Finished 判断计划在 now 时是否已结束：所需能量已满足，或已到截止时间。
+func (p *DeferrablePlan) Finished(now time.Time) bool {
+	return p.Remaining <= 0 || !now.Before(p.Deadline)
+}
+
+// DeferrablePlanner 根据默认电价方案为可延后负载计算最低费用的运行计划，并按计划向客户端下发 command-power 命令。
+// 在每个计划时段开始时、客户端连接或断开时、用电需求或电价方案变化时重新计划。
+// 仅在计划的功率与最近一次下发的不同时下发；客户端被负载切除期间暂停下发，恢复后再按计划下发。
+// 计划完成（所需能量已满足）、超过截止时间或被取消后，删除用电需求，并下发一次 CommandPowerUnlimited 取消计划设定的限制。
+type DeferrablePlanner struct {
+	dispatcher models.CommandDispatcher
+	plans      map[string]*DeferrablePlan // 键为客户端ID。
+	sent       map[string]int             // 最近一次向客户端下发的功率。客户端连接、断开或被切除后清除，以便重新下发。
+	pending    map[string]struct{}        // 等待重新计划的客户端。空字符串表示所有客户端。
+	release    map[string]struct{}        // 计划已结束、等待取消功率限制的客户端。
+	wake       chan struct{}
+	mu         sync.RWMutex
+}
+
+func NewDeferrablePlanner(dispatcher models.CommandDispatcher) *DeferrablePlanner {
+	return &DeferrablePlanner{
+		dispatcher: dispatcher,
+		plans:      make(map[string]*DeferrablePlan),
+		sent:       make(map[string]int),
+		pending:    map[string]struct{}{"": {}},
+		release:    make(map[string]struct{}),
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Serve 提供服务。每 10 秒按计划下发命令；每个计划时段开始时重新计划所有客户端。
+func (p *DeferrablePlanner) Serve() {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	slot := time.Now().Truncate(DeferrablePlanSlot)
+	for {
+		now := time.Now()
+		if current := now.Truncate(DeferrablePlanSlot); current.After(slot) {
+			slot = current
+			p.ReplanAll()
+		}
+		p.replanPending(now)
+		p.apply(now, loadShedClients())
+		select {
+		case <-ticker.C:
+		case <-p.wake:
+		}
+	}
+}
+
+// Replan 请求重新计划某个客户端。
+func (p *DeferrablePlanner) Replan(clientID string) {
+	p.mu.Lock()
+	p.pending[clientID] = struct{}{}
+	p.mu.Unlock()
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Reconnect 请求重新计划某个客户端，并在之后重新下发计划的功率。客户端连接或断开时调用。
+func (p *DeferrablePlanner) Reconnect(clientID string) {
+	p.mu.Lock()
+	delete(p.sent, clientID)
+	p.mu.Unlock()
+	p.Replan(clientID)
+}
+
+// ReplanAll 请求重新计划所有客户端。例如电价方案变化时。
+func (p *DeferrablePlanner) ReplanAll() {
+	p.Replan("")
+}
+
+// GetPlan 获取某个客户端当前的运行计划。
+func (p *DeferrablePlanner) GetPlan(clientID string) (*DeferrablePlan, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	plan, existed := p.plans[clientID]
+	return plan, existed
+}
+
+func (p *DeferrablePlanner) replanPending(now time.Time) {
+	p.mu.Lock()
+	pending := p.pending
+	p.pending = make(map[string]struct{})
+	p.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	deferrables, err := models.GetClientDeferrables(DB)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+	tariff, schedule := loadDefaultTariffSchedule()
+	_, all := pending[""]
+	existed := make(map[string]bool, len(deferrables))
+	for _, deferrable := range deferrables {
+		if _, ok := pending[deferrable.ClientID]; !ok && !all {
+			existed[deferrable.ClientID] = true
+			continue
+		}
+		plan, err := newDeferrablePlan(deferrable, tariff, schedule, now)
+		if err != nil {
+			log.Printf("Planning of client[%s] failed: %s", deferrable.ClientID, err.Error())
+			existed[deferrable.ClientID] = true
+			continue
+		}
+		if plan.Finished(now) {
+			// 计划已结束，删除用电需求。删除失败时下次重新计划再删除。
+			if _, err := deferrable.Delete(DB); err != nil {
+				log.Println(err.Error())
+			}
+			log.Printf("Deferrable of client[%s] finished.", deferrable.ClientID)
+			p.end(deferrable.ClientID)
+			continue
+		}
+		existed[deferrable.ClientID] = true
+		p.mu.Lock()
+		p.plans[deferrable.ClientID] = plan
+		delete(p.release, deferrable.ClientID)
+		p.mu.Unlock()
+	}
+	// 结束已取消的用电需求的计划。
+	p.mu.RLock()
+	var cancelled []string
+	for clientID := range p.plans {
+		if !existed[clientID] {
+			cancelled = append(cancelled, clientID)
+		}
+	}
+	p.mu.RUnlock()
+	for _, clientID := range cancelled {
+		p.end(clientID)
+	}
+}
+
+// end 结束某个客户端的计划，之后下发一次 CommandPowerUnlimited 取消计划设定的限制。
+func (p *DeferrablePlanner) end(clientID string) {
+	p.mu.Lock()
+	delete(p.plans, clientID)
+	delete(p.sent, clientID)
+	p.release[clientID] = struct{}{}
+	p.mu.Unlock()
+}
+
+// loadDefaultTariffSchedule 加载默认电价方案。没有默认电价方案或加载失败时返回 nil。
+func loadDefaultTariffSchedule() (*models.Tariff, *analytics.TariffSchedule) {
+	tariff := models.GetDefaultTariff(DB)
+	if tariff == nil {
+		return nil, nil
+	}
+	versions, err := tariff.GetVersions(DB)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, nil
+	}
+	schedule, err := analytics.NewTariffSchedule(tariff, versions)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, nil
+	}
+	return tariff, schedule
+}
+
+func newDeferrablePlan(deferrable models.ClientDeferrable, tariff *models.Tariff, schedule *analytics.TariffSchedule, now time.Time) (*DeferrablePlan, error) {
+	client, err := models.GetClient(DB, deferrable.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	energy, err := analytics.ClientEnergy(DB, client, deferrable.StartedAt, now)
+	if err != nil {
+		return nil, err
+	}
+	plan := &DeferrablePlan{
+		ClientID:  deferrable.ClientID,
+		Required:  deferrable.Energy,
+		Delivered: energy.Energy,
+		Deadline:  deferrable.Deadline,
+		MaxPower:  deferrable.MaxPower,
+		PlannedAt: now,
+	}
+	if plan.Delivered < plan.Required {
+		plan.Remaining = plan.Required - plan.Delivered
+	}
+	if tariff != nil {
+		plan.TariffID = tariff.ID
+		plan.Currency = tariff.Currency
+	}
+	// 客户端离线时不能运行，但仍然计划，以便连接后立即按计划运行。
+	plan.Plan = analytics.PlanDeferrable(now, deferrable.Deadline, plan.Remaining, deferrable.MaxPower, schedule, DeferrablePlanSlot)
+	return plan, nil
+}
+
+// loadShedClients 返回目前被负载切除的客户端。
+func loadShedClients() map[string]bool {
+	shed := make(map[string]bool)
+	if GlobalLoadShedder == nil {
+		return shed
+	}
+	for _, record := range GlobalLoadShedder.Shed() {
+		shed[record.ClientID] = true
+	}
+	return shed
+}
+
+// apply 按计划向在线客户端下发功率。仅在功率与最近一次下发的不同时下发。
+// shed 中的客户端已被负载切除，暂不下发，以免与负载切除相互覆盖；恢复后重新下发。
+// 到达截止时间的计划随即结束，并请求重新计划以删除用电需求；计划已结束的客户端下发一次 CommandPowerUnlimited。
+func (p *DeferrablePlanner) apply(now time.Time, shed map[string]bool) {
+	p.mu.RLock()
+	plans := make([]*DeferrablePlan, 0, len(p.plans))
+	for _, plan := range p.plans {
+		plans = append(plans, plan)
+	}
+	p.mu.RUnlock()
+
+	for _, plan := range plans {
+		if plan.Finished(now) {
+			p.end(plan.ClientID)
+			p.Replan(plan.ClientID)
+			continue
+		}
+		if shed[plan.ClientID] {
+			p.mu.Lock()
+			delete(p.sent, plan.ClientID)
+			p.mu.Unlock()
+			continue
+		}
+		power := plan.Plan.PowerAt(now)
+		p.mu.RLock()
+		sent, existed := p.sent[plan.ClientID]
+		p.mu.RUnlock()
+		if existed && sent == power {
+			continue
+		}
+		command := NewEventCommandPower(power)
+		if _, err := p.dispatcher.DispatchCommand(plan.ClientID, command.Code, command.MarshalData(), models.ClientCommandExecutionReasonDeferrable); err != nil {
+			continue
+		}
+		p.mu.Lock()
+		p.sent[plan.ClientID] = power
+		p.mu.Unlock()
+		log.Printf("Client[%s] set to %d W by plan.", plan.ClientID, power)
+	}
+
+	p.mu.RLock()
+	released := make([]string, 0, len(p.release))
+	for clientID := range p.release {
+		released = append(released, clientID)
+	}
+	p.mu.RUnlock()
+
+	// 被切除的客户端恢复后再取消限制。下发失败（例如客户端离线）时保留，之后重试。
+	for _, clientID := range released {
+		if shed[clientID] {
+			continue
+		}
+		command := NewEventCommandPower(CommandPowerUnlimited)
+		if _, err := p.dispatcher.DispatchCommand(clientID, command.Code, command.MarshalData(), models.ClientCommandExecutionReasonDeferrable); err != nil {
+			continue
+		}
+		p.mu.Lock()
+		delete(p.release, clientID)
+		p.mu.Unlock()
+		log.Printf("Client[%s] released from plan.", clientID)
+	}
+}
+
+var GlobalDeferrablePlanner *DeferrablePlanner
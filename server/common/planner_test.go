@@ -0,0 +1,82 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vistart/project20240227/server/analytics"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// testDispatcher 记录下发的命令。
+type testDispatcher struct {
+	commands []string
+}
+
+func (d *testDispatcher) DispatchCommand(clientID string, code int, data string, reason string) (*models.ClientCommandExecution, error) {
+	d.commands = append(d.commands, clientID+" "+data)
+	return &models.ClientCommandExecution{ClientID: clientID, Code: code, Data: data, Reason: reason}, nil
+}
+
+// TestDeferrablePlanner_apply 测试仅在计划的功率变化时下发，重新计划不会重复下发，被切除的客户端暂停下发。
+func TestDeferrablePlanner_apply(t *testing.T) {
+	dispatcher := &testDispatcher{}
+	p := NewDeferrablePlanner(dispatcher)
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	plan := func() *DeferrablePlan {
+		return &DeferrablePlan{ClientID: "washer", Remaining: 1, Deadline: now.Add(time.Hour), Plan: &analytics.Plan{Slots: []analytics.PlanSlot{
+			{Start: now, End: now.Add(DeferrablePlanSlot), Power: 500},
+		}}}
+	}
+	p.plans["washer"] = plan()
+
+	p.apply(now, nil)
+	p.apply(now.Add(10*time.Second), nil)
+	assert.Equal(t, []string{"washer {\"power\":500}"}, dispatcher.commands)
+
+	// 重新计划得到相同的功率，不再下发。
+	p.plans["washer"] = plan()
+	p.apply(now.Add(20*time.Second), nil)
+	assert.Len(t, dispatcher.commands, 1)
+
+	// 被切除期间不下发，恢复后按计划重新下发。
+	p.apply(now.Add(30*time.Second), map[string]bool{"washer": true})
+	assert.Len(t, dispatcher.commands, 1)
+	p.apply(now.Add(40*time.Second), nil)
+	assert.Equal(t, []string{"washer {\"power\":500}", "washer {\"power\":500}"}, dispatcher.commands)
+
+	// 计划的时段结束后功率变为 0。
+	p.apply(now.Add(DeferrablePlanSlot), nil)
+	assert.Equal(t, "washer {\"power\":0}", dispatcher.commands[2])
+}
+
+// TestDeferrablePlanner_apply_finished 测试计划到达截止时间后结束，并仅下发一次 CommandPowerUnlimited。
+func TestDeferrablePlanner_apply_finished(t *testing.T) {
+	dispatcher := &testDispatcher{}
+	p := NewDeferrablePlanner(dispatcher)
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	p.plans["washer"] = &DeferrablePlan{ClientID: "washer", Remaining: 1, Deadline: now.Add(DeferrablePlanSlot), Plan: &analytics.Plan{Slots: []analytics.PlanSlot{
+		{Start: now, End: now.Add(DeferrablePlanSlot), Power: 500},
+	}}}
+
+	p.apply(now, nil)
+	assert.Equal(t, []string{"washer {\"power\":500}"}, dispatcher.commands)
+
+	// 到达截止时间后计划结束，取消限制并请求重新计划以删除用电需求。
+	p.apply(now.Add(DeferrablePlanSlot), nil)
+	assert.Equal(t, []string{"washer {\"power\":500}", "washer {\"power\":-1}"}, dispatcher.commands)
+	_, existed := p.GetPlan("washer")
+	assert.False(t, existed)
+	assert.Contains(t, p.pending, "washer")
+
+	p.apply(now.Add(DeferrablePlanSlot+10*time.Second), nil)
+	assert.Len(t, dispatcher.commands, 2)
+
+	// 被切除期间不取消限制，恢复后再取消。
+	p.end("washer")
+	p.apply(now.Add(DeferrablePlanSlot+20*time.Second), map[string]bool{"washer": true})
+	assert.Len(t, dispatcher.commands, 2)
+	p.apply(now.Add(DeferrablePlanSlot+30*time.Second), nil)
+	assert.Equal(t, "washer {\"power\":-1}", dispatcher.commands[2])
+}
@@ -1,12 +1,17 @@
 package common
 
 import (
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/vistart/project20240227/server/models"
 	"gorm.io/gorm"
@@ -15,11 +20,26 @@ import (
 const RequestClientID = "x-request-client-id"
 const RequestClientType = "x-request-client-type"
 const RequestAuthorization = "x-request-authorization"
+const RequestTimestamp = "x-request-timestamp"
+const RequestNonce = "x-request-nonce"
+const RequestSignature = "x-request-signature"
 
+// RequestLastEventID 客户端重连时携带的最后收到的事件ID。
+const RequestLastEventID = "Last-Event-ID"
+
+// RequestClientAuthorization 表示客户端请求的验证信息。
+// 提交了 Signature 时使用 HMAC-SHA256 签名验证，否则在允许的情况下使用旧的 MD5 验证。
 type RequestClientAuthorization struct {
 	ClientID      string
 	ClientType    models.ClientType
 	Authorization string
+
+	Method    string
+	Path      string
+	Timestamp string
+	Nonce     string
+	Signature string
+	Body      []byte
 }
 
 func NewRequestClientAuthorization(id string, clientType models.ClientType, authorization string) *RequestClientAuthorization {
@@ -66,6 +86,79 @@ func (e ErrRequestAuthFailed) Error() string {
 	return fmt.Sprintf("auth failed: %s", e.Authorization)
 }
 
+type ErrRequestStaleTimestamp struct {
+	Timestamp string
+	error
+}
+
+func (e ErrRequestStaleTimestamp) Error() string {
+	return fmt.Sprintf("stale timestamp: %s", e.Timestamp)
+}
+
+type ErrRequestReplayedNonce struct {
+	Nonce string
+	error
+}
+
+func (e ErrRequestReplayedNonce) Error() string {
+	return fmt.Sprintf("replayed nonce: %s", e.Nonce)
+}
+
+// SignRequest 计算请求签名。
+// 签名内容为请求方法、路径、时间戳、随机数及请求体 SHA256 摘要，以换行符连接，再以客户端密钥计算 HMAC-SHA256。
+func SignRequest(secret, method, path, timestamp, nonce string, body []byte) string {
+	bodySum := sha256.Sum256(body)
+	content := strings.Join([]string{method, path, timestamp, nonce, hex.EncodeToString(bodySum[:])}, "\n")
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(content))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NonceCache 记录一段时间内使用过的随机数，用于拒绝重放的请求。过期的随机数由 Serve 定期清除。
+type NonceCache struct {
+	nonces map[string]time.Time // 键为随机数，值为过期时间。
+	mu     sync.Mutex
+}
+
+func NewNonceCache() *NonceCache {
+	return &NonceCache{nonces: make(map[string]time.Time)}
+}
+
+// NonceCacheExpireInterval 表示清除过期随机数的间隔。
+const NonceCacheExpireInterval = time.Minute
+
+// Serve 提供服务。每隔 NonceCacheExpireInterval 清除一次过期的随机数。
+func (n *NonceCache) Serve() {
+	ticker := time.NewTicker(NonceCacheExpireInterval)
+	for now := range ticker.C {
+		n.Expire(now)
+	}
+}
+
+// Expire 清除 now 之前过期的随机数。
+func (n *NonceCache) Expire(now time.Time) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for k, v := range n.nonces {
+		if v.Before(now) {
+			delete(n.nonces, k)
+		}
+	}
+}
+
+// Use 使用随机数。如果该随机数尚未过期，则表示重放，返回 false。
+func (n *NonceCache) Use(nonce string, expiresAt time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if v, existed := n.nonces[nonce]; existed && !v.Before(time.Now()) {
+		return false
+	}
+	n.nonces[nonce] = expiresAt
+	return true
+}
+
+var GlobalNonceCache = NewNonceCache()
+
 func (r *RequestClientAuthorization) Auth() error {
 	// 定义字符串
 	charRange := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
@@ -78,12 +171,28 @@ func (r *RequestClientAuthorization) Auth() error {
 		}
 	}
 	client, err := models.GetClient(DB, r.ClientID)
-	if !errors.Is(err, gorm.ErrRecordNotFound) && err != nil {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return NewErrClientNotFound(r.ClientID)
+	}
+	if err != nil {
 		return err
 	}
-	if client != nil && client.Type != r.ClientType {
+	if client.Type != r.ClientType {
 		return ErrRequestBadClientType{Type: r.ClientType}
 	}
+	if len(r.Signature) > 0 {
+		return r.authHMAC(client)
+	}
+	if GlobalConfig == nil || !GlobalConfig.Auth.LegacyMD5 {
+		return ErrRequestAuthFailed{
+			Authorization: r.Authorization,
+		}
+	}
+	return r.authMD5()
+}
+
+// authMD5 为旧的验证方式，即 md5(clientID + byte(type))。
+func (r *RequestClientAuthorization) authMD5() error {
 	clientID := []byte(r.ClientID)
 	sum := md5.Sum(append(clientID, byte(r.ClientType)))
 	log.Println(hex.EncodeToString(sum[:]))
@@ -94,3 +203,38 @@ func (r *RequestClientAuthorization) Auth() error {
 	}
 	return nil
 }
+
+// authHMAC 使用客户端密钥验证签名，并拒绝过期的时间戳和重放的随机数。
+func (r *RequestClientAuthorization) authHMAC(client *models.Client) error {
+	if len(client.Secret) == 0 {
+		return ErrRequestAuthFailed{
+			Authorization: r.Signature,
+		}
+	}
+	timestamp, err := strconv.ParseInt(r.Timestamp, 10, 64)
+	if err != nil {
+		return ErrRequestStaleTimestamp{Timestamp: r.Timestamp}
+	}
+	tolerance := time.Duration(300) * time.Second
+	if GlobalConfig != nil {
+		tolerance = time.Duration(GlobalConfig.Auth.TimestampTolerance) * time.Second
+	}
+	signedAt := time.Unix(timestamp, 0)
+	if d := time.Since(signedAt); d > tolerance || d < -tolerance {
+		return ErrRequestStaleTimestamp{Timestamp: r.Timestamp}
+	}
+	if len(r.Nonce) == 0 {
+		return ErrRequestReplayedNonce{Nonce: r.Nonce}
+	}
+	expected := SignRequest(client.Secret, r.Method, r.Path, r.Timestamp, r.Nonce, r.Body)
+	if !hmac.Equal([]byte(expected), []byte(r.Signature)) {
+		return ErrRequestAuthFailed{
+			Authorization: r.Signature,
+		}
+	}
+	// 签名验证通过后再记录随机数，避免伪造的请求占用随机数。
+	if !GlobalNonceCache.Use(r.ClientID+":"+r.Nonce, signedAt.Add(tolerance)) {
+		return ErrRequestReplayedNonce{Nonce: r.Nonce}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSignRequest 测试请求签名。签名内容任何部分变化都会导致签名变化。
+func TestSignRequest(t *testing.T) {
+	signature := SignRequest("secret", "POST", "/client/report", "1709251200", "nonce", []byte("consumption=50"))
+	assert.Len(t, signature, 64)
+	assert.Equal(t, signature, SignRequest("secret", "POST", "/client/report", "1709251200", "nonce", []byte("consumption=50")))
+
+	assert.NotEqual(t, signature, SignRequest("secret1", "POST", "/client/report", "1709251200", "nonce", []byte("consumption=50")))
+	assert.NotEqual(t, signature, SignRequest("secret", "GET", "/client/report", "1709251200", "nonce", []byte("consumption=50")))
+	assert.NotEqual(t, signature, SignRequest("secret", "POST", "/client/register", "1709251200", "nonce", []byte("consumption=50")))
+	assert.NotEqual(t, signature, SignRequest("secret", "POST", "/client/report", "1709251201", "nonce", []byte("consumption=50")))
+	assert.NotEqual(t, signature, SignRequest("secret", "POST", "/client/report", "1709251200", "nonce1", []byte("consumption=50")))
+	assert.NotEqual(t, signature, SignRequest("secret", "POST", "/client/report", "1709251200", "nonce", []byte("consumption=51")))
+}
+
+// TestNonceCache_Use 测试随机数只能使用一次，过期后可以再次使用。
+func TestNonceCache_Use(t *testing.T) {
+	cache := NewNonceCache()
+	assert.True(t, cache.Use("a", time.Now().Add(time.Minute)))
+	assert.False(t, cache.Use("a", time.Now().Add(time.Minute)))
+	assert.True(t, cache.Use("b", time.Now().Add(-time.Second)))
+	assert.True(t, cache.Use("b", time.Now().Add(time.Minute)))
+
+	// 清除过期的随机数，未过期的随机数仍不能再次使用。
+	cache.Use("c", time.Now().Add(-time.Second))
+	cache.Expire(time.Now())
+	assert.Len(t, cache.nonces, 2)
+	assert.False(t, cache.Use("a", time.Now().Add(time.Minute)))
+	cache.Expire(time.Now().Add(2 * time.Minute))
+	assert.Len(t, cache.nonces, 0)
+}
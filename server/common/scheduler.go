@@ -0,0 +1,109 @@
+package common
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/vistart/project20240227/server/models"
+)
+
+// PowerModeScheduler 按照执行计划自动执行能耗模式。
+// 每分钟检查一次所有启用的执行计划，执行当前分钟需要触发且尚未触发过的计划。各计划的能耗模式并发执行，
+// 下发命令耗时较长时不会推迟之后的检查；检查本身耗时超过一分钟时，依次补检查错过的分钟。
+// 服务端停止期间错过的触发不会补执行。
+type PowerModeScheduler struct {
+	dispatcher models.CommandDispatcher
+}
+
+func NewPowerModeScheduler(dispatcher models.CommandDispatcher) *PowerModeScheduler {
+	return &PowerModeScheduler{dispatcher: dispatcher}
+}
+
+// PowerModeSchedulerMaxCatchUp 表示最多补检查的时长。落后更多时（例如系统休眠后）跳过错过的分钟，与服务端停止期间一致。
+const PowerModeSchedulerMaxCatchUp = 10 * time.Minute
+
+// Serve 提供服务。在每分钟开始时检查执行计划。
+func (s *PowerModeScheduler) Serve() {
+	last := time.Now().Truncate(time.Minute)
+	for {
+		next := s.next(last, time.Now())
+		time.Sleep(time.Until(next))
+		s.Run(next)
+		last = next
+	}
+}
+
+// next 返回上次检查 last 之后下一次检查的分钟。当前时刻 now 已超过该分钟时立即补检查，落后超过 PowerModeSchedulerMaxCatchUp 时跳过错过的分钟。
+func (s *PowerModeScheduler) next(last time.Time, now time.Time) time.Time {
+	next := last.Add(time.Minute)
+	if now.Sub(next) > PowerModeSchedulerMaxCatchUp {
+		next = now.Truncate(time.Minute)
+	}
+	return next
+}
+
+// Run 执行 at 所在分钟需要触发的执行计划。先逐个更新计划的上次触发时刻，再并发执行各计划的能耗模式，不等待执行完成。
+func (s *PowerModeScheduler) Run(at time.Time) {
+	at = at.Truncate(time.Minute)
+	schedules, err := models.GetEnabledPowerModeSchedules(DB)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+	for _, schedule := range s.due(schedules, at) {
+		// 同一分钟只触发一次，即使多次检查同一分钟。
+		if _, err := schedule.UpdateLastFiredAt(DB, at); err != nil {
+			if !errors.Is(err, models.ErrPowerModeScheduleFired) {
+				log.Println(err.Error())
+			}
+			continue
+		}
+		go s.execute(schedule)
+	}
+}
+
+// due 返回 at 所在分钟需要触发且尚未触发过的执行计划。
+func (s *PowerModeScheduler) due(schedules []models.PowerModeSchedule, at time.Time) []*models.PowerModeSchedule {
+	var due []*models.PowerModeSchedule
+	for i := range schedules {
+		schedule := &schedules[i]
+		if schedule.PowerMode == nil {
+			continue
+		}
+		if schedule.LastFiredAt != nil && !schedule.LastFiredAt.Before(at) {
+			continue
+		}
+		cron, err := ParsePowerModeSchedule(schedule.CronExpression, schedule.TimeZone)
+		if err != nil {
+			log.Printf("Power mode schedule[%d] skipped: %s", schedule.ID, err.Error())
+			continue
+		}
+		if !cron.Matches(at) {
+			continue
+		}
+		due = append(due, schedule)
+	}
+	return due
+}
+
+// execute 执行计划对应的能耗模式。
+func (s *PowerModeScheduler) execute(schedule *models.PowerModeSchedule) {
+	delivered, skipped, err := schedule.PowerMode.Execute(DB, s.dispatcher, models.PowerModeExecutionTriggerSchedule)
+	if err != nil {
+		log.Printf("Power mode schedule[%d] failed: %s", schedule.ID, err.Error())
+		return
+	}
+	log.Printf("Power mode schedule[%d] executed power mode[%d]: %d delivered, %d skipped.", schedule.ID, schedule.PowerModeID, delivered, skipped)
+}
+
+// ParsePowerModeSchedule 按照指定时区解析执行计划的 cron 表达式。
+func ParsePowerModeSchedule(cronExpression string, timeZone string) (*CronSchedule, error) {
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCron(cronExpression, location)
+}
+
+var GlobalPowerModeScheduler *PowerModeScheduler
@@ -0,0 +1,55 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// TestPowerModeScheduler_due 测试按 cron 表达式和上次触发时刻选择需要触发的执行计划。
+func TestPowerModeScheduler_due(t *testing.T) {
+	s := NewPowerModeScheduler(nil)
+	at := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	before := at.Add(-time.Minute)
+	mode := &models.PowerMode{}
+	schedules := []models.PowerModeSchedule{
+		{ID: 1, CronExpression: "0 8 * * *", TimeZone: "UTC", PowerMode: mode},
+		{ID: 2, CronExpression: "0 8 * * *", TimeZone: "UTC", PowerMode: mode, LastFiredAt: &before},
+		// 已在该分钟触发过。
+		{ID: 3, CronExpression: "0 8 * * *", TimeZone: "UTC", PowerMode: mode, LastFiredAt: &at},
+		{ID: 4, CronExpression: "1 8 * * *", TimeZone: "UTC", PowerMode: mode},
+		// 上海时间 16 时为 UTC 8 时。
+		{ID: 5, CronExpression: "0 16 * * *", TimeZone: "Asia/Shanghai", PowerMode: mode},
+		{ID: 6, CronExpression: "0 8 * * *", TimeZone: "Asia/Shanghai", PowerMode: mode},
+		{ID: 7, CronExpression: "0 8 * * *", TimeZone: "UTC"},
+		{ID: 8, CronExpression: "bad", TimeZone: "UTC", PowerMode: mode},
+		{ID: 9, CronExpression: "0 8 * * *", TimeZone: "Mars/Olympus", PowerMode: mode},
+	}
+	var ids []uint64
+	for _, schedule := range s.due(schedules, at) {
+		ids = append(ids, schedule.ID)
+	}
+	assert.Equal(t, []uint64{1, 2, 5}, ids)
+	assert.Empty(t, s.due(schedules, at.Add(time.Minute*2)))
+}
+
+// TestPowerModeScheduler_next 测试检查耗时超过一分钟时补检查错过的分钟，落后过多时跳过。
+func TestPowerModeScheduler_next(t *testing.T) {
+	s := NewPowerModeScheduler(nil)
+	last := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		now      time.Time
+		expected time.Time
+	}{
+		{last.Add(30 * time.Second), last.Add(time.Minute)},
+		{last.Add(time.Minute + 5*time.Second), last.Add(time.Minute)},
+		{last.Add(3*time.Minute + 5*time.Second), last.Add(time.Minute)},
+		{last.Add(PowerModeSchedulerMaxCatchUp + time.Minute), last.Add(time.Minute)},
+		{last.Add(PowerModeSchedulerMaxCatchUp + 2*time.Minute + 5*time.Second), last.Add(PowerModeSchedulerMaxCatchUp + 2*time.Minute)},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, s.next(last, tt.now), tt.now.String())
+	}
+}
@@ -12,26 +12,45 @@ import (
 )
 
 type SessionManager struct {
-	Message       SessionChannel     // 向所有目前活跃的会话广播消息。
-	NewClients    chan *Client       // 接收新加入的客户端。
-	ClosedClients chan *Client       // 接收退出的客户端。
-	TotalClients  map[string]*Client // 目前活跃客户端。键为客户端ID。
-	mu            sync.RWMutex       // TotalClients 读写锁。
+	Message          SessionChannel                // 向所有目前活跃的会话广播消息。
+	NewClients       chan *Client                  // 接收新加入的客户端。
+	ClosedClients    chan *Client                  // 接收退出的客户端。
+	TotalClients     map[string]*Client            // 目前活跃客户端。键为客户端ID。
+	mu               sync.RWMutex                  // TotalClients 读写锁。
+	replayBuffers    map[string]*EventReplayBuffer // 每个客户端的事件重放缓冲区。键为客户端ID。客户端断开后仍保留。
+	replayBufferSize int
+	replayMu         sync.Mutex
 }
 
-func NewSessionManager() (session *SessionManager) {
+// NewSessionManager 实例化会话管理器。replayBufferSize 为每个客户端最多保存的可重放事件数。
+func NewSessionManager(replayBufferSize int) (session *SessionManager) {
 	session = &SessionManager{
-		Message:       make(SessionChannel),
-		NewClients:    make(chan *Client),
-		ClosedClients: make(chan *Client),
-		TotalClients:  make(map[string]*Client),
+		Message:          make(SessionChannel),
+		NewClients:       make(chan *Client),
+		ClosedClients:    make(chan *Client),
+		TotalClients:     make(map[string]*Client),
+		replayBuffers:    make(map[string]*EventReplayBuffer),
+		replayBufferSize: replayBufferSize,
 	}
 	return session
 }
 
+// GetReplayBuffer 获取客户端的事件重放缓冲区。不存在时创建。
+func (s *SessionManager) GetReplayBuffer(clientID string) *EventReplayBuffer {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+	buffer, existed := s.replayBuffers[clientID]
+	if !existed {
+		buffer = NewEventReplayBuffer(s.replayBufferSize, newEventReplayBufferStart())
+		s.replayBuffers[clientID] = buffer
+	}
+	return buffer
+}
+
 const GinKeySessionChannel = "session_channel"
 
 // NewSessionChannelHandler 为 gin 的请求准备的实例化会话通道的句柄。
+// 客户端必须已通过注册码注册，未知的客户端在验证阶段即被拒绝。
 // 1. 实例化一个新的 Client 结构体。该结构体会实例化新的会话通道。
 // 2. 该新实例化的 Client 结构体送入 NewClients 通道。
 // 3. 当该请求断开时，该新实例化的 Client 结构体送入 ClosedClients 通道。
@@ -44,16 +63,7 @@ func (s *SessionManager) NewSessionChannelHandler() gin.HandlerFunc {
 			return
 		}
 		client := NewClient(clientID.(string), clientType.(models.ClientType))
-		newly, err := client.InsertNewClient()
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
-			return
-		}
-		if newly > 0 {
-			log.Printf("New client[%s] added.", clientID)
-		} else {
-			log.Printf("The existed client[%s] connected.", clientID)
-		}
+		log.Printf("Client[%s] connected.", clientID)
 		client.CreateSessionChannel()
 		s.SetClient(clientID.(string), client)
 		s.NewClients <- client
@@ -143,6 +153,17 @@ func (s *SessionManager) GetIsActive(id string) bool {
 	return false
 }
 
+// Clients 返回目前活跃客户端的快照。
+func (s *SessionManager) Clients() []*Client {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	clients := make([]*Client, 0, len(s.TotalClients))
+	for _, client := range s.TotalClients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
 // Count 检查客户端活跃客户数。
 func (s *SessionManager) Count() int {
 	s.mu.RLock()
@@ -150,8 +171,50 @@ func (s *SessionManager) Count() int {
 	return len(s.TotalClients)
 }
 
+// DispatchCommandTimeout 表示 DispatchCommand 等待客户端接收命令的最长时间。
+const DispatchCommandTimeout = 5 * time.Second
+
+// DispatchCommand 向指定客户端发送命令，实现了 models.CommandDispatcher 接口。
+// 每条命令附带新生成的命令ID，并以 reason 记录到命令执行历史，之后由 GlobalCommandTracker 等待客户端确认。
+// 如果客户端未连接，则返回 models.ErrClientOffline；如果客户端未及时接收，则返回 models.ErrCommandTimeout。
+func (s *SessionManager) DispatchCommand(clientID string, code int, data string, reason string) (*models.ClientCommandExecution, error) {
+	event, err := NewEventCommandFromData(code, data)
+	if err != nil {
+		return nil, err
+	}
+	client := s.GetClient(clientID)
+	if client == nil {
+		return nil, models.ErrClientOffline
+	}
+	commandID, err := models.NewCommandID()
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := event.(interface{ SetCommandID(id string) }); ok {
+		e.SetCommandID(commandID)
+	}
+	now := time.Now()
+	execution := models.NewClientCommandExecution(clientID, code, data, now, reason)
+	execution.CommandID = commandID
+	// 先记录再发送，以免客户端的确认先于记录到达。
+	if err := DB.Create(execution).Error; err != nil {
+		return nil, err
+	}
+	if !client.SendToSessionChannelWithTimeout(event, DispatchCommandTimeout) {
+		if err := DB.Delete(execution).Error; err != nil {
+			log.Println(err.Error())
+		}
+		return nil, models.ErrCommandTimeout
+	}
+	if GlobalCommandTracker != nil {
+		GlobalCommandTracker.Track(execution, event, now)
+	}
+	GlobalDashboardHub.PublishCommand(clientID, code, data, now)
+	return execution, nil
+}
+
 // Serve 提供服务。
-// 当有客户端连接或断开时，输出日志并记录到数据库中。
+// 当有客户端连接或断开时，输出日志、记录到数据库中并通知仪表盘。客户端连接时，下发其离线期间加入队列的命令。
 // 当有需要发广播消息时，为每个客户端广播消息。
 func (s *SessionManager) Serve() {
 	for {
@@ -159,11 +222,24 @@ func (s *SessionManager) Serve() {
 		case client := <-s.NewClients:
 			log.Printf("Client[%s] added. %d registered client(s)", client.ID(), s.Count())
 			client.ReceiveActivity(ClientActivityOn)
+			GlobalDashboardHub.PublishPresence(client, true)
+			if GlobalDeferrablePlanner != nil {
+				GlobalDeferrablePlanner.Reconnect(client.ID())
+			}
 			channel := client.GetSessionChannel()
 			channel <- NewEventMessage("connected")
+			// 下发客户端离线期间加入队列的命令。
+			if GlobalPendingCommandQueue != nil {
+				go GlobalPendingCommandQueue.Flush(client.ID())
+			}
 		case client := <-s.ClosedClients:
 			log.Printf("Client[%s] removed. %d registered client(s).", client.ID(), s.Count())
 			client.ReceiveActivity(ClientActivityOff)
+			GlobalHomePower.Remove(client.ID())
+			GlobalDashboardHub.PublishPresence(client, false)
+			if GlobalDeferrablePlanner != nil {
+				GlobalDeferrablePlanner.Reconnect(client.ID())
+			}
 		case message := <-s.Message:
 			for _, client := range s.TotalClients {
 				client.GetSessionChannel() <- message
@@ -0,0 +1,26 @@
+package common
+
+import (
+	"log"
+
+	"github.com/vistart/project20240227/server/models"
+)
+
+// PrepareAdminUser 在不存在任何用户时，创建初始管理员。
+func PrepareAdminUser(username string, password string) {
+	count, err := models.CountUsers(DB)
+	if err != nil {
+		panic(err)
+	}
+	if count > 0 || len(username) == 0 || len(password) == 0 {
+		return
+	}
+	user, err := models.NewUser(username, password, models.UserRoleAdmin)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := models.CreateNewUser(DB, user); err != nil {
+		panic(err)
+	}
+	log.Printf("Initial admin user[%s] created.", username)
+}
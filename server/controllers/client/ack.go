@@ -0,0 +1,37 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// Ack 客户端确认已执行服务端下发的命令。
+// 客户端需要提交命令事件中的 id，以及实际生效的值 value。
+// 重新发送的命令可能被确认多次，重复的确认视为成功。
+func Ack(c *gin.Context) {
+	clientID := c.GetString("client-id")
+	commandID, _ := c.GetPostForm("id")
+	if len(commandID) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "empty command id")
+		return
+	}
+	value, _ := c.GetPostForm("value")
+	execution := models.GetClientCommandExecutionByCommandID(common.DB, clientID, commandID)
+	if execution == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, "command not found")
+		return
+	}
+	if _, err := execution.Ack(common.DB, value, time.Now()); err != nil && !errors.Is(err, models.ErrCommandAcknowledged) {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if common.GlobalCommandTracker != nil {
+		common.GlobalCommandTracker.Remove(commandID)
+	}
+	c.JSON(http.StatusOK, "success")
+}
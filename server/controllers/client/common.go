@@ -1,6 +1,9 @@
 package client
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 
@@ -9,8 +12,13 @@ import (
 	"github.com/vistart/project20240227/server/models"
 )
 
+// RequestMaxBodySize 表示客户端请求体的最大字节数。
+// 批量报告最多 ReportBatchMaxSize 条记录，每条记录的表单编码通常不超过 200 字节，留有足够余量。
+const RequestMaxBodySize = 1 << 20
+
 // Authorize 表示客户端通用验证逻辑。
-// 客户端需要在请求的 header 中提交 client-id, client-type, authorization 字段值。
+// 客户端需要在请求的 header 中提交 client-id, client-type 字段值，以及 timestamp, nonce, signature 签名字段值。
+// 迁移期间，若服务端允许，也可以提交旧的 authorization 字段值代替签名。
 // 如果验证通过，则继续后续逻辑。如果验证不通过，则直接中止。
 func Authorize(c *gin.Context) {
 	clientID := c.GetHeader(common.RequestClientID)
@@ -22,10 +30,31 @@ func Authorize(c *gin.Context) {
 	}
 	authorization := c.GetHeader(common.RequestAuthorization)
 
+	// 读取请求体用于计算签名，并放回以便后续读取。验证之前读取，因此限制请求体的大小。
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, RequestMaxBodySize))
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		c.String(http.StatusRequestEntityTooLarge, err.Error())
+		c.Abort()
+		return
+	}
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		c.Abort()
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
 	auth := common.RequestClientAuthorization{
 		ClientID:      clientID,
 		ClientType:    models.ClientType(clientType),
 		Authorization: authorization,
+		Method:        c.Request.Method,
+		Path:          c.Request.URL.Path,
+		Timestamp:     c.GetHeader(common.RequestTimestamp),
+		Nonce:         c.GetHeader(common.RequestNonce),
+		Signature:     c.GetHeader(common.RequestSignature),
+		Body:          body,
 	}
 	if err = auth.Auth(); err != nil {
 		c.String(http.StatusBadRequest, err.Error())
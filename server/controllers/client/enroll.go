@@ -0,0 +1,43 @@
+package client
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+type ResponseEnrollData struct {
+	ID     string            `json:"id"`
+	Name   string            `json:"name"`
+	Type   models.ClientType `json:"type"`
+	Secret string            `json:"secret"`
+}
+
+// Enroll 设备使用管理员提供的注册码换取客户端编号和密钥。注册码只能使用一次。
+func Enroll(c *gin.Context) {
+	code := c.PostForm("code")
+	if len(code) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "enrollment code not specified")
+		return
+	}
+	client, err := models.RedeemClientEnrollment(common.DB, code)
+	if errors.Is(err, models.ErrClientEnrollmentInvalid) || errors.Is(err, models.ErrClientEnrollmentRedeemed) || errors.Is(err, models.ErrClientEnrollmentExpired) {
+		c.AbortWithStatusJSON(http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Printf("New client[%s] enrolled.", client.ID)
+	c.JSON(http.StatusOK, ResponseEnrollData{
+		ID:     client.ID,
+		Name:   client.Name,
+		Type:   client.Type,
+		Secret: client.Secret,
+	})
+}
@@ -2,11 +2,16 @@ package client
 
 import (
 	"io"
+	"log"
+	"strconv"
 
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 	"github.com/vistart/project20240227/server/common"
 )
 
+// Register 以 SSE 形式向客户端推送事件。每个事件都带有该客户端单调递增的ID。
+// 客户端重连时若携带 Last-Event-ID 请求头，则先重放其后发送过的命令。广播的消息不重放。
 func Register(c *gin.Context) {
 	v, ok := c.Get("client")
 	if !ok {
@@ -16,6 +21,28 @@ func Register(c *gin.Context) {
 	if !ok {
 		return
 	}
+	buffer := common.GlobalSessionManager.GetReplayBuffer(client.ID())
+	render := func(id uint64, name string, data string) {
+		c.Render(-1, sse.Event{Id: strconv.FormatUint(id, 10), Event: name, Data: data})
+	}
+	send := func(code int, data string) {
+		name := common.EventCodeNameMap[code]
+		render(buffer.Append(name, data, common.IsEventCodeCommand(code)), name, data)
+	}
+
+	if lastEventID := c.GetHeader(common.RequestLastEventID); len(lastEventID) > 0 {
+		if id, err := strconv.ParseUint(lastEventID, 10, 64); err == nil {
+			events, complete := buffer.Since(id)
+			if !complete {
+				log.Printf("Client[%s] missed events no longer available for replay.", client.ID())
+			}
+			for _, event := range events {
+				render(event.ID, event.Name, event.Data)
+			}
+			c.Writer.Flush()
+		}
+	}
+
 	c.Stream(func(w io.Writer) bool {
 		// Stream message to client from message channel
 		channel := client.GetSessionChannel()
@@ -24,13 +51,17 @@ func Register(c *gin.Context) {
 				return true
 			}
 			if eventD, ok := event.(*common.EventBase[any]); ok {
-				c.SSEvent(common.EventCodeNameMap[eventD.Code], eventD.MarshalData())
+				send(eventD.Code, eventD.MarshalData())
 			} else if eventD, ok := event.(*common.EventBase[common.EventCommandPowerData]); ok {
-				c.SSEvent(common.EventCodeNameMap[eventD.Code], eventD.MarshalData())
+				send(eventD.Code, eventD.MarshalData())
+			} else if eventD, ok := event.(*common.EventBase[common.EventCommandSetpointData]); ok {
+				send(eventD.Code, eventD.MarshalData())
+			} else if eventD, ok := event.(*common.EventBase[common.EventCommandBatteryData]); ok {
+				send(eventD.Code, eventD.MarshalData())
 			} else if eventD, ok := event.(*common.EventBase[common.EventMessageData]); ok {
-				c.SSEvent(common.EventCodeNameMap[eventD.Code], eventD.MarshalData())
+				send(eventD.Code, eventD.MarshalData())
 			} else if eventD, ok := event.(*common.EventBase[struct{}]); ok {
-				c.SSEvent(common.EventCodeNameMap[eventD.Code], eventD.MarshalData()) // 删除后，中断连接。
+				send(eventD.Code, eventD.MarshalData()) // 删除后，中断连接。
 				return false
 			}
 			return true
@@ -1,14 +1,70 @@
 package client
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+	"gorm.io/gorm"
 )
 
+// ErrReportNotFinite 表示报告的数值为 NaN 或无穷大。
+var ErrReportNotFinite = errors.New("value not finite")
+
+// parseFinite 解析报告的数值。NaN 和无穷大（包括超出 float32 范围的数值）无法保存，返回 ErrReportNotFinite。
+func parseFinite(value string) (float64, error) {
+	v, err := strconv.ParseFloat(value, 32)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, ErrReportNotFinite
+	}
+	return v, nil
+}
+
+// parseOptionalFloat 解析报告的可选数值，例如室内温度。未报告（为空）时返回 nil。NaN 和无穷大返回 ErrReportNotFinite。
+func parseOptionalFloat(value string) (*float64, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+	t, err := parseFinite(value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// parseSoC 解析报告的荷电状态（百分比）。未报告（为空）时返回 nil。
+func parseSoC(value string) (*float64, error) {
+	soc, err := parseOptionalFloat(value)
+	if err != nil {
+		return nil, err
+	}
+	// 以取反的比较判断范围，NaN 也视为超出范围。
+	if soc != nil && !(*soc >= 0 && *soc <= 100) {
+		return nil, errors.New("soc out of range")
+	}
+	return soc, nil
+}
+
+// float32Ptr 将 *float64 转换为 *float32。
+func float32Ptr(v *float64) *float32 {
+	if v == nil {
+		return nil
+	}
+	f := float32(*v)
+	return &f
+}
+
+// Report 客户端报告功耗。可选参数 temperature 为室内温度，单位为摄氏度，适用于空调、取暖器等温控设备；
+// 可选参数 soc 为荷电状态（百分比），适用于储能设备，保存在 client_battery_state 中。
 func Report(c *gin.Context) {
 	client, existed := c.Get("client")
 	if !existed {
@@ -18,13 +74,120 @@ func Report(c *gin.Context) {
 	m := client.(*common.Client)
 	consumption, existed := c.GetPostForm("consumption")
 	cF, _ := strconv.ParseFloat(consumption, 32)
+	if math.IsNaN(cF) || math.IsInf(cF, 0) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "bad consumption")
+		return
+	}
 	recordedAt, existed := c.GetPostForm("recorded_at")
 	recordedAtInt, _ := strconv.ParseInt(recordedAt, 10, 32)
-	_, err := m.ReceiveReportConsumption(float32(cF), time.Unix(recordedAtInt, 0))
+	temperature, err := parseOptionalFloat(c.PostForm("temperature"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "bad temperature")
+		return
+	}
+	soc, err := parseSoC(c.PostForm("soc"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "bad soc")
+		return
+	}
+	_, err = m.ReceiveReportConsumption(float32(cF), float32Ptr(temperature), time.Unix(recordedAtInt, 0))
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
 		return
 	}
+	if soc != nil {
+		if _, err := m.ReceiveReportBatteryState(float32(*soc), time.Unix(recordedAtInt, 0)); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+		common.GlobalDashboardHub.PublishBattery(m, *soc, time.Unix(recordedAtInt, 0))
+	}
+	common.GlobalHomePower.Update(m, cF, time.Unix(recordedAtInt, 0))
+	common.GlobalDashboardHub.PublishConsumption(m, cF, temperature, time.Unix(recordedAtInt, 0))
 	c.JSON(http.StatusOK, "success")
 	return
 }
+
+// ReportBatchMaxSize 表示一次批量报告最多包含的记录数。
+const ReportBatchMaxSize = 1000
+
+// ReportBatch 客户端批量报告功耗，用于补报服务端不可达期间未能报告的记录。
+// consumption 和 recorded_at 按相同的顺序重复提交，一一对应。所有记录在一个事务中保存，任意一条不合法时均不保存。
+// 可选参数 temperature 和 soc 同样按顺序重复提交，未报告的记录提交空值；不提交时所有记录均无温度或荷电状态。
+// 补报的记录均为过去的记录，因此不更新实时功率，也不通知仪表盘。客户端无需保持连接。
+func ReportBatch(c *gin.Context) {
+	clientID := c.GetString("client-id")
+	consumptions := c.PostFormArray("consumption")
+	recordedAts := c.PostFormArray("recorded_at")
+	if len(consumptions) != len(recordedAts) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "consumption and recorded_at count mismatch")
+		return
+	}
+	temperatures := c.PostFormArray("temperature")
+	if len(temperatures) > 0 && len(temperatures) != len(consumptions) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "consumption and temperature count mismatch")
+		return
+	}
+	socs := c.PostFormArray("soc")
+	if len(socs) > 0 && len(socs) != len(consumptions) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "consumption and soc count mismatch")
+		return
+	}
+	if len(consumptions) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "empty batch")
+		return
+	}
+	if len(consumptions) > ReportBatchMaxSize {
+		c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("batch size exceeds %d", ReportBatchMaxSize))
+		return
+	}
+	records := make([]models.ClientConsumption, 0, len(consumptions))
+	var states []models.ClientBatteryState
+	for i := range consumptions {
+		consumption, err := parseFinite(consumptions[i])
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("bad consumption at %d", i))
+			return
+		}
+		recordedAt, err := strconv.ParseInt(recordedAts[i], 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("bad recorded_at at %d", i))
+			return
+		}
+		var temperature *float64
+		if len(temperatures) > 0 {
+			if temperature, err = parseOptionalFloat(temperatures[i]); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("bad temperature at %d", i))
+				return
+			}
+		}
+		records = append(records, models.ClientConsumption{Consumption: float32(consumption), Temperature: float32Ptr(temperature), RecordedAt: time.Unix(recordedAt, 0)})
+		if len(socs) > 0 {
+			soc, err := parseSoC(socs[i])
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, fmt.Sprintf("bad soc at %d", i))
+				return
+			}
+			if soc != nil {
+				states = append(states, models.ClientBatteryState{SoC: float32(*soc), RecordedAt: time.Unix(recordedAt, 0)})
+			}
+		}
+	}
+	client, err := models.GetClient(common.DB, clientID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "client not found")
+		return
+	}
+	err = common.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := client.InsertNewConsumptions(tx, records); err != nil {
+			return err
+		}
+		_, err := client.InsertNewBatteryStates(tx, states)
+		return err
+	})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, "success")
+}
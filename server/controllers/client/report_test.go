@@ -0,0 +1,81 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseFinite 测试报告的数值不能为 NaN 或无穷大。
+func TestParseFinite(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected float64
+		valid    bool
+	}{
+		{"50", 50, true},
+		{"-1500.5", -1500.5, true},
+		{"", 0, false},
+		{"abc", 0, false},
+		{"NaN", 0, false},
+		{"Inf", 0, false},
+		{"-Inf", 0, false},
+		{"1e39", 0, false},
+	}
+	for _, tt := range tests {
+		v, err := parseFinite(tt.value)
+		assert.Equal(t, tt.valid, err == nil, tt.value)
+		if tt.valid {
+			assert.Equal(t, tt.expected, v, tt.value)
+		}
+	}
+}
+
+// TestParseOptionalFloat 测试解析可选的室内温度，未报告时为 nil，NaN 和无穷大不合法。
+func TestParseOptionalFloat(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected *float64
+		valid    bool
+	}{
+		{"", nil, true},
+		{"21.5", float64Ptr(21.5), true},
+		{"-3", float64Ptr(-3), true},
+		{"abc", nil, false},
+		{"NaN", nil, false},
+		{"Inf", nil, false},
+		{"-Inf", nil, false},
+	}
+	for _, tt := range tests {
+		v, err := parseOptionalFloat(tt.value)
+		assert.Equal(t, tt.valid, err == nil, tt.value)
+		assert.Equal(t, tt.expected, v, tt.value)
+	}
+}
+
+// TestParseSoC 测试荷电状态须在 0 到 100 之间。
+func TestParseSoC(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected *float64
+		valid    bool
+	}{
+		{"", nil, true},
+		{"0", float64Ptr(0), true},
+		{"55.5", float64Ptr(55.5), true},
+		{"100", float64Ptr(100), true},
+		{"-0.1", nil, false},
+		{"100.1", nil, false},
+		{"NaN", nil, false},
+		{"Inf", nil, false},
+	}
+	for _, tt := range tests {
+		v, err := parseSoC(tt.value)
+		assert.Equal(t, tt.valid, err == nil, tt.value)
+		assert.Equal(t, tt.expected, v, tt.value)
+	}
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,41 @@
+package account
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/controllers/user/auth"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// 删除用户（不能删除自己）
+
+func Delete(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Query("user_id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "bad user id")
+		return
+	}
+	user := models.GetUser(common.DB, id)
+	if user == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, "user not found")
+		return
+	}
+	if current, ok := auth.GetUser(c); ok && current.ID == user.ID {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "cannot delete current user")
+		return
+	}
+
+	total, err := user.Delete(common.DB)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if total == 0 {
+		c.JSON(http.StatusOK, "user not deleted")
+	} else {
+		c.JSON(http.StatusOK, "success")
+	}
+}
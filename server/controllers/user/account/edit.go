@@ -0,0 +1,122 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// 添加、编辑用户
+
+type RequestEditParams struct {
+	UserID   string `form:"user_id"`
+	Username string `form:"username"`
+	Password string `form:"password"`
+	Role     string `form:"role"`
+}
+
+func (p *RequestEditParams) String() string {
+	// 定义输出字符串
+	var s string
+
+	// 添加字段值。不输出密码。
+	s += fmt.Sprintf("user_id=%s ", p.UserID)
+	s += fmt.Sprintf("username=%s ", p.Username)
+	s += fmt.Sprintf("role=%s", p.Role)
+
+	// 返回输出字符串
+	return s
+}
+
+func (p *RequestEditParams) Check() error {
+	if len(p.Role) > 0 && models.GetUserRoleByName(p.Role) == models.UserRoleNone {
+		return errors.New("bad role")
+	}
+	if len(p.UserID) > 0 {
+		return nil
+	}
+	if len(p.Username) == 0 || len(p.Username) > 255 {
+		return errors.New("bad username")
+	}
+	if len(p.Password) == 0 {
+		return errors.New("password not specified")
+	}
+	if len(p.Role) == 0 {
+		return errors.New("role not specified")
+	}
+	return nil
+}
+
+// Edit 添加或编辑用户。
+// 未指定 user_id 时添加新用户，需要提交 username、password、role；否则修改指定用户的密码和（或）角色。
+func Edit(c *gin.Context) {
+	params := RequestEditParams{}
+	if err := c.MustBindWith(&params, binding.Form); err == nil {
+		log.Println(params.String())
+	} else {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := params.Check(); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 添加
+	if len(params.UserID) == 0 {
+		if models.GetUserByUsername(common.DB, params.Username) != nil {
+			c.AbortWithStatusJSON(http.StatusConflict, "username duplicated")
+			return
+		}
+		user, err := models.NewUser(params.Username, params.Password, models.GetUserRoleByName(params.Role))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+		if _, err := models.CreateNewUser(common.DB, user); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, user)
+		return
+	}
+
+	// 编辑
+	id, err := strconv.ParseUint(params.UserID, 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "bad user id")
+		return
+	}
+	user := models.GetUser(common.DB, id)
+	if user == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, "user not found")
+		return
+	}
+	// 先修改角色，不能修改时不修改密码。
+	if len(params.Role) > 0 {
+		_, err := user.UpdateRole(common.DB, models.GetUserRoleByName(params.Role))
+		if errors.Is(err, models.ErrUserLastAdmin) {
+			c.AbortWithStatusJSON(http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if len(params.Password) > 0 {
+		if _, err := user.UpdatePassword(common.DB, params.Password); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	c.JSON(http.StatusOK, user)
+}
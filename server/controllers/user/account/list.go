@@ -0,0 +1,45 @@
+package account
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	userClient "github.com/vistart/project20240227/server/controllers/user/client"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// 查询用户列表
+
+type ResponseUser struct {
+	models.User
+	RoleName string `json:"RoleName"`
+}
+
+type ResponseListData struct {
+	Users []ResponseUser `json:"users"`
+}
+
+func List(c *gin.Context) {
+	p, ok := c.Get("page_size")
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "page and size not specified")
+		return
+	}
+	paramPageSize := p.(*userClient.RequestPageParams)
+
+	users, count, err := models.GetUsers(common.DB, paramPageSize.Page, paramPageSize.Size)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	data := ResponseListData{Users: make([]ResponseUser, 0, len(users))}
+	for _, user := range users {
+		data.Users = append(data.Users, ResponseUser{User: user, RoleName: models.UserRoleNames[user.Role]})
+	}
+	c.JSON(http.StatusOK, userClient.ResponseList{
+		Data:  data,
+		Count: count,
+	})
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+type ResponseLoginData struct {
+	Token     string       `json:"token"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	User      *models.User `json:"user"`
+	Role      string       `json:"role"`
+}
+
+// Login 用户登录。验证用户名和密码后返回令牌。
+func Login(c *gin.Context) {
+	username := c.PostForm("username")
+	password := c.PostForm("password")
+	if len(username) == 0 || len(password) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "username or password not specified")
+		return
+	}
+	user := models.GetUserByUsername(common.DB, username)
+	if user == nil || !user.CheckPassword(password) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, "username or password incorrect")
+		return
+	}
+	ttl := time.Duration(common.GlobalConfig.User.SessionTTL) * time.Second
+	token, session, err := models.CreateNewUserSession(common.DB, user, ttl)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, ResponseLoginData{
+		Token:     token,
+		ExpiresAt: session.ExpiresAt,
+		User:      user,
+		Role:      models.UserRoleNames[user.Role],
+	})
+}
+
+// Logout 用户注销。当前令牌立即失效。
+func Logout(c *gin.Context) {
+	v, ok := c.Get("user_session")
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, "user not authenticated")
+		return
+	}
+	if _, err := v.(*models.UserSession).Delete(common.DB); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, "success")
+}
+
+type ResponseMeData struct {
+	User *models.User `json:"user"`
+	Role string       `json:"role"`
+}
+
+// Me 获取当前用户信息。
+func Me(c *gin.Context) {
+	user, ok := GetUser(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, "user not authenticated")
+		return
+	}
+	c.JSON(http.StatusOK, ResponseMeData{
+		User: user,
+		Role: models.UserRoleNames[user.Role],
+	})
+}
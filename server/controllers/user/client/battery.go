@@ -0,0 +1,34 @@
+package client
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+type ResponseGetBatteryStatesData struct {
+	States []models.ClientBatteryState `json:"states"`
+}
+
+// GetBatteryStates 获取某个储能客户端在 [from, to] 内的荷电状态记录，按记录时间正序排列。
+func GetBatteryStates(c *gin.Context) {
+	clientID, _ := c.Get("client-id")
+	client, err := models.GetClient(common.DB, clientID.(string))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "client not found")
+		return
+	}
+	from, to := GetTimeRange(c)
+
+	states, err := client.GetBatteryStatesBetween(common.DB, from, to)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, ResponseList{
+		Data:  ResponseGetBatteryStatesData{States: states},
+		Count: int64(len(states)),
+	})
+}
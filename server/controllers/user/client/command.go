@@ -17,6 +17,7 @@ import (
 type RequestSendCommandParams struct {
 	Command string `form:"command"`
 	Data    string `form:"data"`
+	TTL     int64  `form:"ttl"` // 客户端离线时命令在队列中的有效期，单位为秒。为 0 时不过期。
 }
 
 func (p *RequestSendCommandParams) String() string {
@@ -25,39 +26,86 @@ func (p *RequestSendCommandParams) String() string {
 
 	// 添加字段值
 	s += fmt.Sprintf("command=%s ", p.Command)
-	s += fmt.Sprintf("data=%s", p.Data)
+	s += fmt.Sprintf("data=%s ", p.Data)
+	s += fmt.Sprintf("ttl=%d", p.TTL)
 
 	// 返回输出字符串
 	return s
 }
 
+// Check 检查参数。power 命令的 data 为非负整数（瓦），为 -1 时取消功率上限；setpoint 命令的 data 为 5 到 35 的温度（摄氏度），
+// battery 命令的 data 为有符号整数（瓦）：正数充电，负数放电，0 待机。
 func (p *RequestSendCommandParams) Check() error {
 	if len(p.Command) == 0 {
 		return errors.New("empty command")
 	}
-	_, err := strconv.ParseInt(p.Data, 10, 64)
-	if err != nil {
-		return err
+	switch "command-" + p.Command {
+	case common.EventNameCommandSetpoint:
+		setpoint, err := strconv.ParseFloat(p.Data, 64)
+		if err != nil {
+			return err
+		}
+		// 拒绝 NaN、无穷大及超出范围的温度，NaN 和无穷大也无法序列化为 JSON。
+		data := common.EventCommandSetpointData{Setpoint: setpoint}
+		if err := data.Check(); err != nil {
+			return err
+		}
+	case common.EventNameCommandPower:
+		power, err := strconv.ParseInt(p.Data, 10, 64)
+		if err != nil {
+			return err
+		}
+		if power < common.CommandPowerUnlimited {
+			return errors.New("power must not be negative")
+		}
+	default:
+		if _, err := strconv.ParseInt(p.Data, 10, 64); err != nil {
+			return err
+		}
+	}
+	if p.TTL < 0 {
+		return errors.New("ttl must not be negative")
 	}
 	return nil
 }
 
-func sendCommandPower(client *common.Client, params *RequestSendCommandParams) error {
+// sendCommand 向客户端下发命令。命令执行历史由 DispatchCommand 记录，客户端确认后更新其状态。
+// 客户端离线时，命令加入队列并返回队列中的命令，待客户端连接后下发。
+func sendCommand(clientID string, code int, data string, ttl int64) (*models.ClientPendingCommand, error) {
+	_, err := common.GlobalSessionManager.DispatchCommand(clientID, code, data, models.ClientCommandExecutionReasonManual)
+	if !errors.Is(err, models.ErrClientOffline) {
+		return nil, err
+	}
+	var expiresAt *time.Time
+	if ttl > 0 {
+		t := time.Now().Add(time.Duration(ttl) * time.Second)
+		expiresAt = &t
+	}
+	return common.GlobalPendingCommandQueue.Enqueue(clientID, code, data, models.ClientCommandExecutionReasonManual, expiresAt)
+}
+
+// sendCommandPower 向客户端下发功率命令。
+func sendCommandPower(clientID string, params *RequestSendCommandParams) (*models.ClientPendingCommand, error) {
 	data, _ := strconv.Atoi(params.Data)
 
 	command := common.NewEventCommandPower(data)
-	now := time.Now()
-	client.SendToSessionChannel(command)
-	// 发送命令后，将刚才发送的内容记录到数据表中。
-	clientModel, _ := models.GetClient(common.DB, client.ID())
-	if clientModel != nil {
-		_, err := clientModel.InsertNewCommandExecution(common.DB, command.Code, command.MarshalData(), &now)
-		if err != nil {
-			log.Println(err.Error())
-			return err
-		}
-	}
-	return nil
+	return sendCommand(clientID, command.Code, command.MarshalData(), params.TTL)
+}
+
+// sendCommandBattery 向客户端下发储能充放电命令。
+func sendCommandBattery(clientID string, params *RequestSendCommandParams) (*models.ClientPendingCommand, error) {
+	data, _ := strconv.Atoi(params.Data)
+
+	command := common.NewEventCommandBattery(data)
+	return sendCommand(clientID, command.Code, command.MarshalData(), params.TTL)
+}
+
+// sendCommandSetpoint 向客户端下发设定温度命令。
+func sendCommandSetpoint(clientID string, params *RequestSendCommandParams) (*models.ClientPendingCommand, error) {
+	data, _ := strconv.ParseFloat(params.Data, 64)
+
+	command := common.NewEventCommandSetpoint(data)
+	return sendCommand(clientID, command.Code, command.MarshalData(), params.TTL)
 }
 
 func SendCommand(c *gin.Context) {
@@ -74,20 +122,23 @@ func SendCommand(c *gin.Context) {
 		return
 	}
 
-	clientParam, ok := c.Get("client")
-	if !ok {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, "invalid client")
+	clientID, _ := c.Get("client-id")
+	if _, err := models.GetClient(common.DB, clientID.(string)); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "client not found")
 		return
 	}
-
-	client := clientParam.(*common.Client)
+	var pending *models.ClientPendingCommand
 	var err error
 
 	command := "command-" + params.Command
 
 	switch command {
 	case common.EventNameCommandPower:
-		err = sendCommandPower(client, &params)
+		pending, err = sendCommandPower(clientID.(string), &params)
+	case common.EventNameCommandSetpoint:
+		pending, err = sendCommandSetpoint(clientID.(string), &params)
+	case common.EventNameCommandBattery:
+		pending, err = sendCommandBattery(clientID.(string), &params)
 	default:
 		c.AbortWithStatusJSON(http.StatusBadRequest, "command not supported")
 		return
@@ -97,6 +148,11 @@ func SendCommand(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
 		return
 	}
+	// 客户端离线，命令已加入队列。
+	if pending != nil {
+		c.JSON(http.StatusAccepted, pending)
+		return
+	}
 
 	c.JSON(http.StatusOK, "Success")
 }
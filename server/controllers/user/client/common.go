@@ -1,9 +1,12 @@
 package client
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/vistart/project20240227/server/common"
@@ -79,3 +82,76 @@ func BindPageSize(c *gin.Context) {
 	c.Set("page_size", params)
 	c.Next()
 }
+
+// ParseTime 解析时间参数。支持 RFC3339 格式及 Unix 时间戳（秒）。
+func ParseTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+type RequestTimeRangeParams struct {
+	From string `form:"from"`
+	To   string `form:"to"`
+}
+
+func (p *RequestTimeRangeParams) String() string {
+	// 定义输出字符串
+	var s string
+
+	// 添加字段值
+	s += fmt.Sprintf("from=%s ", p.From)
+	s += fmt.Sprintf("to=%s", p.To)
+
+	// 返回输出字符串
+	return s
+}
+
+// Range 解析时间范围。未指定 to 时为当前时刻，未指定 from 时为 to 之前一天。
+func (p *RequestTimeRangeParams) Range() (time.Time, time.Time, error) {
+	to := time.Now()
+	if len(p.To) > 0 {
+		t, err := ParseTime(p.To)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("bad to")
+		}
+		to = t
+	}
+	from := to.Add(-24 * time.Hour)
+	if len(p.From) > 0 {
+		t, err := ParseTime(p.From)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("bad from")
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("from must be before to")
+	}
+	return from, to, nil
+}
+
+// BindTimeRange 解析 from 和 to 参数，并以 from 和 to 为键保存。
+func BindTimeRange(c *gin.Context) {
+	params := RequestTimeRangeParams{}
+	if err := c.ShouldBindQuery(&params); err == nil {
+		log.Println(params.String())
+	} else {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	from, to, err := params.Range()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.Set("from", from)
+	c.Set("to", to)
+	c.Next()
+}
+
+// GetTimeRange 获取 BindTimeRange 保存的时间范围。
+func GetTimeRange(c *gin.Context) (time.Time, time.Time) {
+	return c.GetTime("from"), c.GetTime("to")
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// ConsumptionAggregateBuckets 表示支持的分桶大小。
+var ConsumptionAggregateBuckets = map[string]time.Duration{
+	"1m":  time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+// ConsumptionAggregateMaxBuckets 表示单次查询允许的最大分桶数。
+const ConsumptionAggregateMaxBuckets = 50000
+
+type RequestAggregateConsumptionsParams struct {
+	Bucket string `form:"bucket"`
+	Fn     string `form:"fn"`
+	Field  string `form:"field"` // 聚合的字段：consumption（功耗）或 temperature（室内温度）。
+}
+
+func (p *RequestAggregateConsumptionsParams) String() string {
+	// 定义输出字符串
+	var s string
+
+	// 添加字段值
+	s += fmt.Sprintf("bucket=%s ", p.Bucket)
+	s += fmt.Sprintf("fn=%s ", p.Fn)
+	s += fmt.Sprintf("field=%s", p.Field)
+
+	// 返回输出字符串
+	return s
+}
+
+// Check 检查参数。未指定时按小时求功耗的平均值。
+func (p *RequestAggregateConsumptionsParams) Check() error {
+	if len(p.Bucket) == 0 {
+		p.Bucket = "1h"
+	}
+	if len(p.Fn) == 0 {
+		p.Fn = "avg"
+	}
+	if len(p.Field) == 0 {
+		p.Field = "consumption"
+	}
+	if _, ok := ConsumptionAggregateBuckets[p.Bucket]; !ok {
+		return errors.New("bucket not supported")
+	}
+	if _, ok := models.ClientConsumptionAggregateFunctions[p.Fn]; !ok {
+		return errors.New("fn not supported")
+	}
+	if _, ok := models.ClientConsumptionAggregateFields[p.Field]; !ok {
+		return errors.New("field not supported")
+	}
+	return nil
+}
+
+type ResponseAggregateConsumptionsData struct {
+	Bucket  string                           `json:"bucket"`
+	Fn      string                           `json:"fn"`
+	Field   string                           `json:"field"`
+	From    time.Time                        `json:"from"`
+	To      time.Time                        `json:"to"`
+	Buckets []models.ClientConsumptionBucket `json:"buckets"`
+}
+
+// AggregateConsumptions 将某个客户端在 [from, to) 内的能耗记录按时间分桶聚合。聚合室内温度时，未报告温度的记录不参与聚合。
+func AggregateConsumptions(c *gin.Context) {
+	clientID, _ := c.Get("client-id")
+	params := RequestAggregateConsumptionsParams{}
+	if err := c.ShouldBindQuery(&params); err == nil {
+		log.Println(params.String())
+	} else {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := params.Check(); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	from, to := GetTimeRange(c)
+	bucket := ConsumptionAggregateBuckets[params.Bucket]
+	if to.Sub(from)/bucket > ConsumptionAggregateMaxBuckets {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "too many buckets")
+		return
+	}
+
+	client, err := models.GetClient(common.DB, clientID.(string))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "client not found")
+		return
+	}
+
+	buckets, err := client.AggregateConsumptionField(common.DB, params.Field, from, to, bucket, params.Fn)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, ResponseList{
+		Data: ResponseAggregateConsumptionsData{
+			Bucket:  params.Bucket,
+			Fn:      params.Fn,
+			Field:   params.Field,
+			From:    from,
+			To:      to,
+			Buckets: buckets,
+		},
+		Count: int64(len(buckets)),
+	})
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// 可延后负载的用电需求及运行计划
+
+type RequestSetDeferrableParams struct {
+	Energy   float64 `form:"energy"`
+	Deadline string  `form:"deadline"`
+	MaxPower int     `form:"max_power"`
+}
+
+func (p *RequestSetDeferrableParams) String() string {
+	// 定义输出字符串
+	var s string
+
+	// 添加字段值
+	s += fmt.Sprintf("energy=%f ", p.Energy)
+	s += fmt.Sprintf("deadline=%s ", p.Deadline)
+	s += fmt.Sprintf("max_power=%d", p.MaxPower)
+
+	// 返回输出字符串
+	return s
+}
+
+func (p *RequestSetDeferrableParams) Check(now time.Time) (time.Time, error) {
+	if p.Energy <= 0 {
+		return time.Time{}, errors.New("energy must be positive")
+	}
+	if p.MaxPower <= 0 {
+		return time.Time{}, errors.New("max power must be positive")
+	}
+	if len(p.Deadline) == 0 {
+		return time.Time{}, errors.New("deadline not specified")
+	}
+	deadline, err := ParseTime(p.Deadline)
+	if err != nil {
+		return time.Time{}, errors.New("bad deadline")
+	}
+	if !deadline.After(now) {
+		return time.Time{}, errors.New("deadline must be in the future")
+	}
+	if deadline.Sub(now) > common.DeferrableMaxHorizon {
+		return time.Time{}, fmt.Errorf("deadline must be within %s", common.DeferrableMaxHorizon)
+	}
+	return deadline, nil
+}
+
+// SetDeferrable 将客户端标记为可延后负载：自现在起，在 deadline 之前以不超过 max_power 瓦的功率消耗 energy 千瓦时。
+// 已有的用电需求将被替换。服务端随后按默认电价方案计算最低费用的运行计划，并按计划调整客户端的功率。
+func SetDeferrable(c *gin.Context) {
+	clientID, _ := c.Get("client-id")
+	client, err := models.GetClient(common.DB, clientID.(string))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "client not found")
+		return
+	}
+	params := RequestSetDeferrableParams{}
+	if err := c.MustBindWith(&params, binding.Form); err == nil {
+		log.Println(params.String())
+	} else {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	now := time.Now()
+	deadline, err := params.Check(now)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	deferrable, err := client.SetDeferrable(common.DB, params.Energy, deadline, params.MaxPower, now)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	common.GlobalDeferrablePlanner.Replan(client.ID)
+	c.JSON(http.StatusOK, deferrable)
+}
+
+// DeleteDeferrable 取消客户端的用电需求。之后向客户端下发一次 CommandPowerUnlimited，取消计划设定的限制，不再按计划调整。
+func DeleteDeferrable(c *gin.Context) {
+	clientID, _ := c.Get("client-id")
+	client, err := models.GetClient(common.DB, clientID.(string))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "client not found")
+		return
+	}
+	deferrable := client.GetDeferrable(common.DB)
+	if deferrable == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, "client not deferrable")
+		return
+	}
+	if _, err := deferrable.Delete(common.DB); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	common.GlobalDeferrablePlanner.Replan(client.ID)
+	c.JSON(http.StatusOK, "success")
+}
+
+// GetPlan 获取可延后负载当前的运行计划，包括已消耗和尚需的能量、各运行时段的功率及预计费用。
+func GetPlan(c *gin.Context) {
+	clientID, _ := c.Get("client-id")
+	plan, existed := common.GlobalDeferrablePlanner.GetPlan(clientID.(string))
+	if !existed {
+		c.AbortWithStatusJSON(http.StatusNotFound, "plan not found")
+		return
+	}
+	c.JSON(http.StatusOK, plan)
+}
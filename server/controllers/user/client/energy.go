@@ -0,0 +1,37 @@
+package client
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/analytics"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// EnergyMaxSpan 表示单次计算能耗允许的最长时间范围。计算时须读取范围内的所有功率记录，需加以限制。
+const EnergyMaxSpan = 24 * time.Hour
+
+// GetEnergy 获取某个客户端在 [from, to] 内的能耗（千瓦时）。
+// 能耗由瞬时功率记录按梯形法积分得到，离线期间不做插值。时间范围不能超过 EnergyMaxSpan。
+func GetEnergy(c *gin.Context) {
+	clientID, _ := c.Get("client-id")
+	from, to := GetTimeRange(c)
+	if to.Sub(from) > EnergyMaxSpan {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "time range too long")
+		return
+	}
+	client, err := models.GetClient(common.DB, clientID.(string))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "client not found")
+		return
+	}
+
+	result, err := analytics.ClientEnergy(common.DB, client, from, to)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+type RequestCreateEnrollmentParams struct {
+	Name string            `form:"name"`
+	Type models.ClientType `form:"type"`
+	TTL  int64             `form:"ttl"`
+}
+
+func (p *RequestCreateEnrollmentParams) String() string {
+	// 定义输出字符串
+	var s string
+
+	// 添加字段值
+	s += fmt.Sprintf("name=%s ", p.Name)
+	s += fmt.Sprintf("type=%d ", p.Type)
+	s += fmt.Sprintf("ttl=%d", p.TTL)
+
+	// 返回输出字符串
+	return s
+}
+
+// Check 检查参数。有效期单位为秒，未指定时为一天。
+func (p *RequestCreateEnrollmentParams) Check() error {
+	if len(p.Name) > 255 {
+		return errors.New("name too long")
+	}
+	if p.Type <= 0 {
+		return common.ErrRequestBadClientType{Type: p.Type}
+	}
+	if p.TTL <= 0 {
+		p.TTL = 86400
+	}
+	return nil
+}
+
+type ResponseCreateEnrollmentData struct {
+	Code       string                   `json:"code"`
+	Enrollment *models.ClientEnrollment `json:"enrollment"`
+}
+
+// CreateEnrollment 创建一次性注册码。注册码仅在此时返回一次。
+func CreateEnrollment(c *gin.Context) {
+	params := RequestCreateEnrollmentParams{}
+	if err := c.MustBindWith(&params, binding.Form); err == nil {
+		log.Println(params.String())
+	} else {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := params.Check(); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	code, enrollment, err := models.CreateNewClientEnrollment(common.DB, params.Name, params.Type, time.Duration(params.TTL)*time.Second)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, ResponseCreateEnrollmentData{
+		Code:       code,
+		Enrollment: enrollment,
+	})
+}
+
+type ResponseGetEnrollmentsData struct {
+	Enrollments []models.ClientEnrollment `json:"enrollments"`
+}
+
+// GetEnrollments 获取注册码列表。
+func GetEnrollments(c *gin.Context) {
+	p, ok := c.Get("page_size")
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "page and size not specified")
+		return
+	}
+	paramPageSize := p.(*RequestPageParams)
+
+	enrollments, count, err := models.GetClientEnrollments(common.DB, paramPageSize.Page, paramPageSize.Size)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, ResponseList{
+		Data:  ResponseGetEnrollmentsData{Enrollments: enrollments},
+		Count: count,
+	})
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/vistart/project20240227/server/common"
@@ -27,7 +28,8 @@ func EditInfo(c *gin.Context) {
 		return
 	}
 	name := c.PostForm("name")
-	if len(name) == 0 {
+	priority, priorityExisted := c.GetPostForm("priority")
+	if len(name) == 0 && !priorityExisted {
 		c.AbortWithStatusJSON(http.StatusBadRequest, "name not specified")
 		return
 	}
@@ -35,13 +37,31 @@ func EditInfo(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, "name too long")
 		return
 	}
-	updateName, err := client.UpdateName(common.DB, name)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
-		return
+	var updated int64
+	if len(name) > 0 {
+		updateName, err := client.UpdateName(common.DB, name)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+		updated += updateName
+	}
+	// 优先级用于负载切除，数值越小越先被切除。
+	if priorityExisted {
+		p, err := strconv.ParseInt(priority, 10, 32)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, "invalid priority")
+			return
+		}
+		updatePriority, err := client.UpdatePriority(common.DB, int32(p))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+		updated += updatePriority
 	}
-	if updateName == 0 {
-		c.JSON(http.StatusOK, "client name not changed")
+	if updated == 0 {
+		c.JSON(http.StatusOK, "client not changed")
 	} else {
 		c.JSON(http.StatusOK, "success")
 	}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// 客户端离线时等待下发的命令队列
+
+// GetPendingCommands 获取客户端的命令队列，包括已下发、已过期和已取消的命令，按加入队列的顺序排列。
+func GetPendingCommands(c *gin.Context) {
+	clientID, _ := c.Get("client-id")
+	client, err := models.GetClient(common.DB, clientID.(string))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "client not found")
+		return
+	}
+	p, ok := c.Get("page_size")
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "page and size not specified")
+		return
+	}
+	paramPageSize := p.(*RequestPageParams)
+
+	commands, count, err := client.GetPendingCommands(common.DB, paramPageSize.Page, paramPageSize.Size)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, ResponseList{Data: commands, Count: count})
+}
+
+// CancelPendingCommand 取消客户端等待下发的命令。指定 id 时仅取消该命令，否则取消该客户端所有等待下发的命令。
+func CancelPendingCommand(c *gin.Context) {
+	clientID, _ := c.Get("client-id")
+	client, err := models.GetClient(common.DB, clientID.(string))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "client not found")
+		return
+	}
+	commandID := c.PostForm("id")
+	if len(commandID) == 0 {
+		commandID = c.Query("id")
+	}
+	if len(commandID) == 0 {
+		if _, err := client.CancelPendingCommands(common.DB); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, "success")
+		return
+	}
+
+	id, err := strconv.ParseUint(commandID, 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "bad id")
+		return
+	}
+	command := models.GetClientPendingCommand(common.DB, id)
+	if command == nil || command.ClientID != client.ID {
+		c.AbortWithStatusJSON(http.StatusNotFound, "command not found")
+		return
+	}
+	if _, err := command.Cancel(common.DB); err != nil {
+		if errors.Is(err, models.ErrPendingCommandNotPending) {
+			c.AbortWithStatusJSON(http.StatusConflict, err.Error())
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, "success")
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+type ResponseResetSecretData struct {
+	ClientID string `json:"client_id"`
+	Secret   string `json:"secret"`
+}
+
+// ResetSecret 为客户端生成新的密钥，并返回该密钥。旧密钥立即失效。
+// 密钥仅在此时返回一次，需要写入客户端配置中用于请求签名。
+func ResetSecret(c *gin.Context) {
+	clientID, _ := c.Get("client-id")
+	client, err := models.GetClient(common.DB, clientID.(string))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "client not found")
+		return
+	}
+	secret, err := models.NewClientSecret()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if _, err := client.UpdateSecret(common.DB, secret); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, ResponseResetSecretData{
+		ClientID: client.ID,
+		Secret:   secret,
+	})
+}
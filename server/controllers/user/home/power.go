@@ -0,0 +1,70 @@
+package home
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// 全屋实时功率
+
+type ResponsePowerType struct {
+	Type    models.ClientType `json:"type"`
+	Power   float64           `json:"power"`
+	Clients int               `json:"clients"`
+}
+
+type ResponsePowerClient struct {
+	common.LivePower
+	Age float64 `json:"age"` // 距离上次报告的秒数
+}
+
+type ResponsePowerData struct {
+	Total float64 `json:"total"` // 净功率。有客户端发电时可能为负数。
+	common.HomePowerBalance
+	Types        []ResponsePowerType         `json:"types"`
+	Clients      []ResponsePowerClient       `json:"clients"`
+	LoadShedding []common.LoadSheddingRecord `json:"load_shedding"` // 目前已切除的客户端。未启用负载切除时为空。
+}
+
+// GetPower 获取全屋实时功率，包括总功率、从电网取用、向电网输出和自用的功率、按客户端类型分类的功率，以及每个客户端最近一次报告的功率。
+// 数据来自内存，仅包含在线且已报告过功率的客户端。
+func GetPower(c *gin.Context) {
+	now := time.Now()
+	data := ResponsePowerData{
+		Types:        make([]ResponsePowerType, 0),
+		Clients:      make([]ResponsePowerClient, 0),
+		LoadShedding: make([]common.LoadSheddingRecord, 0),
+	}
+	if common.GlobalLoadShedder != nil {
+		data.LoadShedding = common.GlobalLoadShedder.Shed()
+	}
+	types := make(map[models.ClientType]*ResponsePowerType)
+	powers := common.GlobalHomePower.Snapshot()
+	data.HomePowerBalance = common.NewHomePowerBalance(powers)
+	for _, power := range powers {
+		data.Total += power.Power
+		t, existed := types[power.Type]
+		if !existed {
+			t = &ResponsePowerType{Type: power.Type}
+			types[power.Type] = t
+		}
+		t.Power += power.Power
+		t.Clients++
+		data.Clients = append(data.Clients, ResponsePowerClient{
+			LivePower: power,
+			Age:       now.Sub(power.ReceivedAt).Seconds(),
+		})
+	}
+	for _, t := range types {
+		data.Types = append(data.Types, *t)
+	}
+	sort.Slice(data.Types, func(i, j int) bool {
+		return data.Types[i].Type < data.Types[j].Type
+	})
+	c.JSON(http.StatusOK, data)
+}
@@ -1,3 +1,84 @@
 package command
 
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/controllers/user/power_mode"
+	"github.com/vistart/project20240227/server/models"
+)
+
 // 为能耗模式添加命令
+
+type RequestAddParams struct {
+	ClientID string `form:"client_id"`
+	Code     int    `form:"code"`
+	Data     string `form:"data"`
+}
+
+func (p *RequestAddParams) String() string {
+	// 定义输出字符串
+	var s string
+
+	// 添加字段值
+	s += fmt.Sprintf("client_id=%s ", p.ClientID)
+	s += fmt.Sprintf("code=%d ", p.Code)
+	s += fmt.Sprintf("data=%s", p.Data)
+
+	// 返回输出字符串
+	return s
+}
+
+// Check 检查参数。code 和 data 必须能构成 common/event.go 中的命令事件。
+func (p *RequestAddParams) Check() error {
+	if len(p.ClientID) == 0 {
+		return errors.New("client id not specified")
+	}
+	if _, err := common.NewEventCommandFromData(p.Code, p.Data); err != nil {
+		return err
+	}
+	return nil
+}
+
+func Add(c *gin.Context) {
+	mode, ok := power_mode.GetPowerMode(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, "invalid power mode")
+		return
+	}
+
+	params := RequestAddParams{}
+	if err := c.MustBindWith(&params, binding.Form); err == nil {
+		log.Println(params.String())
+	} else {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := params.Check(); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	client, err := models.GetClient(common.DB, params.ClientID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "client not found")
+		return
+	}
+
+	_, err = mode.AddCommand(common.DB, models.NewClientPreparedCommand(client, mode, params.Code, params.Data))
+	if errors.Is(err, models.ErrPowerModeCommandDuplicated) {
+		c.AbortWithStatusJSON(http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, "success")
+}
@@ -1,3 +1,42 @@
 package command
 
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/controllers/user/power_mode"
+)
+
 // 删除当前能耗模式某个命令
+
+func Delete(c *gin.Context) {
+	mode, ok := power_mode.GetPowerMode(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, "invalid power mode")
+		return
+	}
+
+	clientID := c.Query("client_id")
+	if len(clientID) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "client id not specified")
+		return
+	}
+
+	command := mode.GetCommand(common.DB, clientID)
+	if command == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, "command not found")
+		return
+	}
+
+	total, err := mode.RemoveCommand(common.DB, command)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if total == 0 {
+		c.JSON(http.StatusOK, "command not deleted")
+	} else {
+		c.JSON(http.StatusOK, "success")
+	}
+}
@@ -1,20 +1,103 @@
 package command
 
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	userClient "github.com/vistart/project20240227/server/controllers/user/client"
+	"github.com/vistart/project20240227/server/controllers/user/power_mode"
+	"github.com/vistart/project20240227/server/models"
+)
+
 // POST: 执行能耗模式
 // GET: 获取能耗模式执行历史
 
-// SendCommandPower 发送能耗命令。
-// 通过 client-id 拿到 Client。如果不存在，则报错。也意味着断开连接的客户端不执行。
-// 向 Client 发送命令。
-// 插入执行记录。
-//func (c *Client) SendCommandPower(db *gorm.DB, data int) (int64, error) {
-//	client := GlobalSessionManager.GetClient(c.ID)
-//	if client == nil {
-//		return 0, NewErrClientNotFound(c.ID)
-//	}
-//	command := NewEventCommandPower(data)
-//	now := time.Now()
-//	client.SendToSessionChannel(command)
-//	// 发送命令后，将刚才发送的内容记录到数据表中。
-//	return c.InsertNewCommandExecution(db, command.Code, command.MarshalData(), &now)
-//}
+type ResponseExecuteData struct {
+	Delivered int64 `json:"delivered"`
+	Skipped   int64 `json:"skipped"`
+}
+
+// Execute 执行能耗模式。
+// 向所有在线客户端发送预制命令，未连接的客户端被跳过。返回成功发送和未成功发送的命令数。
+func Execute(c *gin.Context) {
+	mode, ok := power_mode.GetPowerMode(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, "invalid power mode")
+		return
+	}
+
+	delivered, skipped, err := mode.Execute(common.DB, common.GlobalSessionManager, models.PowerModeExecutionTriggerManual)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, ResponseExecuteData{
+		Delivered: delivered,
+		Skipped:   skipped,
+	})
+}
+
+type ResponseExecutionClient struct {
+	ClientID   string                         `json:"client_id"`
+	Status     int8                           `json:"status"`
+	StatusName string                         `json:"status_name"`
+	Command    *models.ClientCommandExecution `json:"command,omitempty"`
+}
+
+type ResponseExecution struct {
+	ID        uint64                    `json:"id"`
+	Trigger   string                    `json:"trigger"`
+	CreatedAt *time.Time                `json:"created_at"`
+	Clients   []ResponseExecutionClient `json:"clients"`
+}
+
+type ResponseGetExecutionsData struct {
+	Executions []ResponseExecution `json:"executions"`
+}
+
+// GetExecutions 获取能耗模式执行历史，以及每次执行中各客户端的执行结果。
+func GetExecutions(c *gin.Context) {
+	mode, ok := power_mode.GetPowerMode(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, "invalid power mode")
+		return
+	}
+	p, ok := c.Get("page_size")
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "page and size not specified")
+		return
+	}
+	paramPageSize := p.(*userClient.RequestPageParams)
+
+	executions, count, err := mode.GetExecutions(common.DB, paramPageSize.Page, paramPageSize.Size)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	data := ResponseGetExecutionsData{Executions: make([]ResponseExecution, 0, len(executions))}
+	for _, execution := range executions {
+		e := ResponseExecution{
+			ID:        execution.ID,
+			Trigger:   execution.Trigger,
+			CreatedAt: execution.CreatedAt,
+			Clients:   make([]ResponseExecutionClient, 0, len(execution.Clients)),
+		}
+		for _, client := range execution.Clients {
+			e.Clients = append(e.Clients, ResponseExecutionClient{
+				ClientID:   client.ClientID,
+				Status:     client.Status,
+				StatusName: models.PowerModeExecutionClientStatusNames[client.Status],
+				Command:    client.ClientCommandExecution,
+			})
+		}
+		data.Executions = append(data.Executions, e)
+	}
+
+	c.JSON(http.StatusOK, power_mode.ResponseList{
+		Data:  data,
+		Count: count,
+	})
+}
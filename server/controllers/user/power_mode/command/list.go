@@ -1,3 +1,63 @@
 package command
 
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/controllers/user/power_mode"
+)
+
 // 查询具体能耗模式涉及的命令列表
+
+type ResponseCommand struct {
+	ID         uint64     `json:"id"`
+	ClientID   string     `json:"client_id"`
+	ClientName string     `json:"client_name"`
+	IsActive   bool       `json:"is_active"`
+	Code       int        `json:"code"`
+	Data       string     `json:"data"`
+	CreatedAt  *time.Time `json:"created_at"`
+	UpdatedAt  *time.Time `json:"updated_at"`
+}
+
+type ResponseListData struct {
+	Commands []ResponseCommand `json:"commands"`
+}
+
+func List(c *gin.Context) {
+	mode, ok := power_mode.GetPowerMode(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, "invalid power mode")
+		return
+	}
+
+	commands, count, err := mode.GetCommands(common.DB)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	data := ResponseListData{Commands: make([]ResponseCommand, 0, len(commands))}
+	for _, command := range commands {
+		r := ResponseCommand{
+			ID:        command.ID,
+			ClientID:  command.ClientID,
+			IsActive:  common.GlobalSessionManager.GetIsActive(command.ClientID),
+			Code:      command.Code,
+			Data:      command.Data,
+			CreatedAt: command.CreatedAt,
+			UpdatedAt: command.UpdatedAt,
+		}
+		if command.Client != nil {
+			r.ClientName = command.Client.Name
+		}
+		data.Commands = append(data.Commands, r)
+	}
+
+	c.JSON(http.StatusOK, power_mode.ResponseList{
+		Data:  data,
+		Count: count,
+	})
+}
@@ -0,0 +1,51 @@
+package power_mode
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// ResponseList 表示列表响应。
+type ResponseList struct {
+	Data  any   `json:"data"`
+	Count int64 `json:"count"`
+}
+
+// BindPowerMode 根据 power_mode_id 参数查找能耗模式。
+// 参数可以在表单或查询字符串中提交。如果能耗模式存在，则以 power_mode 为键保存，否则直接中止。
+func BindPowerMode(c *gin.Context) {
+	powerModeID := c.PostForm("power_mode_id")
+	if len(powerModeID) == 0 {
+		powerModeID = c.Query("power_mode_id")
+	}
+	if len(powerModeID) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "power mode id not specified")
+		return
+	}
+	id, err := strconv.ParseUint(powerModeID, 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "bad power mode id")
+		return
+	}
+	mode := models.GetPowerMode(common.DB, id)
+	if mode == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, "power mode not found")
+		return
+	}
+	c.Set("power_mode", mode)
+	c.Next()
+}
+
+// GetPowerMode 获取 BindPowerMode 保存的能耗模式。
+func GetPowerMode(c *gin.Context) (*models.PowerMode, bool) {
+	v, ok := c.Get("power_mode")
+	if !ok {
+		return nil, false
+	}
+	mode, ok := v.(*models.PowerMode)
+	return mode, ok
+}
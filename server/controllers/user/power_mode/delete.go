@@ -1,3 +1,35 @@
 package power_mode
 
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
 // 删除能耗模式（仅限删除未执行过的）
+
+func Delete(c *gin.Context) {
+	mode, ok := GetPowerMode(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, "invalid power mode")
+		return
+	}
+
+	total, err := mode.Delete(common.DB)
+	if errors.Is(err, models.ErrPowerModeExecuted) {
+		c.AbortWithStatusJSON(http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if total == 0 {
+		c.JSON(http.StatusOK, "power mode not deleted")
+	} else {
+		c.JSON(http.StatusOK, "success")
+	}
+}
@@ -1,3 +1,102 @@
 package power_mode
 
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
 // 添加、编辑能耗模式（仅限名称）
+
+type RequestEditParams struct {
+	PowerModeID string `form:"power_mode_id"`
+	Name        string `form:"name"`
+}
+
+func (p *RequestEditParams) String() string {
+	// 定义输出字符串
+	var s string
+
+	// 添加字段值
+	s += fmt.Sprintf("power_mode_id=%s ", p.PowerModeID)
+	s += fmt.Sprintf("name=%s", p.Name)
+
+	// 返回输出字符串
+	return s
+}
+
+func (p *RequestEditParams) Check() error {
+	if len(p.Name) == 0 {
+		return errors.New("name not specified")
+	}
+	if len(p.Name) > 255 {
+		return errors.New("name too long")
+	}
+	return nil
+}
+
+// Edit 添加或编辑能耗模式。
+// 未指定 power_mode_id 时添加新的能耗模式，否则修改指定能耗模式的名称。名称不能与其它能耗模式重复。
+func Edit(c *gin.Context) {
+	params := RequestEditParams{}
+	if err := c.MustBindWith(&params, binding.Form); err == nil {
+		log.Println(params.String())
+	} else {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := params.Check(); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	existed := models.GetPowerModeByName(common.DB, params.Name)
+
+	// 添加
+	if len(params.PowerModeID) == 0 {
+		if existed != nil {
+			c.AbortWithStatusJSON(http.StatusConflict, "power mode name duplicated")
+			return
+		}
+		if _, err := models.CreateNewPowerMode(common.DB, params.Name); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, models.GetPowerModeByName(common.DB, params.Name))
+		return
+	}
+
+	// 编辑
+	id, err := strconv.ParseUint(params.PowerModeID, 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "bad power mode id")
+		return
+	}
+	mode := models.GetPowerMode(common.DB, id)
+	if mode == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, "power mode not found")
+		return
+	}
+	if existed != nil && existed.ID != mode.ID {
+		c.AbortWithStatusJSON(http.StatusConflict, "power mode name duplicated")
+		return
+	}
+	updated, err := mode.UpdateName(common.DB, params.Name)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if updated == 0 {
+		c.JSON(http.StatusOK, "power mode name not changed")
+	} else {
+		c.JSON(http.StatusOK, "success")
+	}
+}
@@ -1,3 +1,18 @@
 package power_mode
 
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
 // 查询某个能耗模式信息
+
+func GetInfo(c *gin.Context) {
+	mode, ok := GetPowerMode(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, "invalid power mode")
+		return
+	}
+	c.JSON(http.StatusOK, mode)
+}
@@ -1,3 +1,37 @@
 package power_mode
 
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	userClient "github.com/vistart/project20240227/server/controllers/user/client"
+	"github.com/vistart/project20240227/server/models"
+)
+
 // 查询能耗模式列表
+
+type ResponseListData struct {
+	PowerModes []models.PowerMode `json:"power_modes"`
+}
+
+func List(c *gin.Context) {
+	p, ok := c.Get("page_size")
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "page and size not specified")
+		return
+	}
+	paramPageSize := p.(*userClient.RequestPageParams)
+
+	modes, count, err := models.GetPowerModes(common.DB, paramPageSize.Page, paramPageSize.Size)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response := ResponseList{
+		Data:  ResponseListData{PowerModes: modes},
+		Count: count,
+	}
+	c.JSON(http.StatusOK, response)
+}
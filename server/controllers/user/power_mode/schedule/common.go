@@ -0,0 +1,45 @@
+package schedule
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// BindSchedule 根据 schedule_id 参数查找执行计划。
+// 参数可以在表单或查询字符串中提交。如果执行计划存在，则以 power_mode_schedule 为键保存，否则直接中止。
+func BindSchedule(c *gin.Context) {
+	scheduleID := c.PostForm("schedule_id")
+	if len(scheduleID) == 0 {
+		scheduleID = c.Query("schedule_id")
+	}
+	if len(scheduleID) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "schedule id not specified")
+		return
+	}
+	id, err := strconv.ParseUint(scheduleID, 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "bad schedule id")
+		return
+	}
+	schedule := models.GetPowerModeSchedule(common.DB, id)
+	if schedule == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, "schedule not found")
+		return
+	}
+	c.Set("power_mode_schedule", schedule)
+	c.Next()
+}
+
+// GetSchedule 获取 BindSchedule 保存的执行计划。
+func GetSchedule(c *gin.Context) (*models.PowerModeSchedule, bool) {
+	v, ok := c.Get("power_mode_schedule")
+	if !ok {
+		return nil, false
+	}
+	schedule, ok := v.(*models.PowerModeSchedule)
+	return schedule, ok
+}
@@ -0,0 +1,29 @@
+package schedule
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+)
+
+// 删除能耗模式的执行计划
+
+func Delete(c *gin.Context) {
+	schedule, ok := GetSchedule(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, "invalid schedule")
+		return
+	}
+
+	total, err := schedule.Delete(common.DB)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if total == 0 {
+		c.JSON(http.StatusOK, "schedule not deleted")
+	} else {
+		c.JSON(http.StatusOK, "success")
+	}
+}
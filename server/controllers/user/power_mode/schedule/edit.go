@@ -0,0 +1,108 @@
+package schedule
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// 添加、编辑能耗模式的执行计划
+
+type RequestEditParams struct {
+	ScheduleID  string `form:"schedule_id"`
+	PowerModeID string `form:"power_mode_id"`
+	Cron        string `form:"cron"`
+	TimeZone    string `form:"time_zone"`
+	Enabled     *bool  `form:"enabled"`
+}
+
+func (p *RequestEditParams) String() string {
+	// 定义输出字符串
+	var s string
+
+	// 添加字段值
+	s += fmt.Sprintf("schedule_id=%s ", p.ScheduleID)
+	s += fmt.Sprintf("power_mode_id=%s ", p.PowerModeID)
+	s += fmt.Sprintf("cron=%s ", p.Cron)
+	s += fmt.Sprintf("time_zone=%s ", p.TimeZone)
+	if p.Enabled != nil {
+		s += fmt.Sprintf("enabled=%t", *p.Enabled)
+	}
+
+	// 返回输出字符串
+	return s
+}
+
+// Check 检查参数。未指定时区时使用服务端所在时区；未指定是否启用时默认启用。
+func (p *RequestEditParams) Check() error {
+	if len(p.TimeZone) == 0 {
+		p.TimeZone = "Local"
+	}
+	if p.Enabled == nil {
+		enabled := true
+		p.Enabled = &enabled
+	}
+	_, err := common.ParsePowerModeSchedule(p.Cron, p.TimeZone)
+	return err
+}
+
+// Edit 添加或编辑执行计划。
+// 未指定 schedule_id 时为 power_mode_id 指定的能耗模式添加执行计划，否则修改指定执行计划。
+func Edit(c *gin.Context) {
+	params := RequestEditParams{}
+	if err := c.MustBindWith(&params, binding.Form); err == nil {
+		log.Println(params.String())
+	} else {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := params.Check(); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 添加
+	if len(params.ScheduleID) == 0 {
+		id, err := strconv.ParseUint(params.PowerModeID, 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, "bad power mode id")
+			return
+		}
+		mode := models.GetPowerMode(common.DB, id)
+		if mode == nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, "power mode not found")
+			return
+		}
+		schedule := models.NewPowerModeSchedule(mode, params.Cron, params.TimeZone, *params.Enabled)
+		if _, err := models.CreateNewPowerModeSchedule(common.DB, schedule); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, schedule)
+		return
+	}
+
+	// 编辑
+	id, err := strconv.ParseUint(params.ScheduleID, 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "bad schedule id")
+		return
+	}
+	schedule := models.GetPowerModeSchedule(common.DB, id)
+	if schedule == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, "schedule not found")
+		return
+	}
+	if _, err := schedule.Update(common.DB, params.Cron, params.TimeZone, *params.Enabled); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
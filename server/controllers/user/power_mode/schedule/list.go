@@ -0,0 +1,53 @@
+package schedule
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/controllers/user/power_mode"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// 查询能耗模式的执行计划列表
+
+type ResponseSchedule struct {
+	models.PowerModeSchedule
+	NextFireAt *time.Time `json:"NextFireAt"`
+}
+
+type ResponseListData struct {
+	Schedules []ResponseSchedule `json:"schedules"`
+}
+
+func List(c *gin.Context) {
+	mode, ok := power_mode.GetPowerMode(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, "invalid power mode")
+		return
+	}
+
+	schedules, count, err := mode.GetSchedules(common.DB)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	now := time.Now()
+	data := ResponseListData{Schedules: make([]ResponseSchedule, 0, len(schedules))}
+	for _, schedule := range schedules {
+		r := ResponseSchedule{PowerModeSchedule: schedule}
+		if cron, err := common.ParsePowerModeSchedule(schedule.CronExpression, schedule.TimeZone); err == nil && schedule.Enabled {
+			if next := cron.Next(now); !next.IsZero() {
+				r.NextFireAt = &next
+			}
+		}
+		data.Schedules = append(data.Schedules, r)
+	}
+
+	c.JSON(http.StatusOK, power_mode.ResponseList{
+		Data:  data,
+		Count: count,
+	})
+}
@@ -0,0 +1,80 @@
+package schedule
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// 预览执行计划接下来的触发时刻
+
+type RequestPreviewParams struct {
+	ScheduleID string `form:"schedule_id"`
+	Cron       string `form:"cron"`
+	TimeZone   string `form:"time_zone"`
+	N          int    `form:"n"`
+}
+
+func (p *RequestPreviewParams) String() string {
+	// 定义输出字符串
+	var s string
+
+	// 添加字段值
+	s += fmt.Sprintf("schedule_id=%s ", p.ScheduleID)
+	s += fmt.Sprintf("cron=%s ", p.Cron)
+	s += fmt.Sprintf("time_zone=%s ", p.TimeZone)
+	s += fmt.Sprintf("n=%d", p.N)
+
+	// 返回输出字符串
+	return s
+}
+
+type ResponsePreviewData struct {
+	Times []time.Time `json:"times"`
+}
+
+// Preview 预览接下来 n 个触发时刻。
+// 指定 schedule_id 时预览已保存的执行计划，否则预览 cron 和 time_zone 指定的表达式。n 默认为 5，最大为 100。
+func Preview(c *gin.Context) {
+	params := RequestPreviewParams{N: 5}
+	if err := c.ShouldBindQuery(&params); err == nil {
+		log.Println(params.String())
+	} else {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if params.N <= 0 || params.N > 100 { // 设上限和下限。
+		params.N = 100
+	}
+
+	if len(params.ScheduleID) > 0 {
+		id, err := strconv.ParseUint(params.ScheduleID, 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, "bad schedule id")
+			return
+		}
+		schedule := models.GetPowerModeSchedule(common.DB, id)
+		if schedule == nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, "schedule not found")
+			return
+		}
+		params.Cron = schedule.CronExpression
+		params.TimeZone = schedule.TimeZone
+	}
+	if len(params.TimeZone) == 0 {
+		params.TimeZone = "Local"
+	}
+
+	cron, err := common.ParsePowerModeSchedule(params.Cron, params.TimeZone)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, ResponsePreviewData{Times: cron.NextN(time.Now(), params.N)})
+}
@@ -0,0 +1,54 @@
+package stream
+
+import (
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/controllers/user/auth"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// KeepAliveInterval 表示没有事件时发送心跳的间隔。
+const KeepAliveInterval = 15 * time.Second
+
+// Stream 以 SSE 形式向仪表盘推送客户端的功耗（consumption）、上下线（presence）和命令（command）事件。
+// 连接建立后，先为每个在线客户端推送一次 presence 事件，以便仪表盘获得初始状态。
+// 浏览器的 EventSource 不能设置请求头，因此可以通过 token 查询参数传递会话令牌。
+// 每次发送心跳前重新检查会话，会话因注销、修改密码或过期而失效时关闭事件流。
+func Stream(c *gin.Context) {
+	ch := common.GlobalDashboardHub.Subscribe()
+	defer common.GlobalDashboardHub.Unsubscribe(ch)
+
+	for _, client := range common.GlobalSessionManager.Clients() {
+		c.SSEvent(common.DashboardEventPresence, common.DashboardPresenceData{
+			ClientID: client.ID(),
+			Type:     client.Type(),
+			Online:   true,
+			At:       time.Now(),
+		})
+	}
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(KeepAliveInterval)
+	defer ticker.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Name, event.Data)
+			return true
+		case <-ticker.C:
+			if models.GetUserSessionByToken(common.DB, auth.GetToken(c)) == nil {
+				return false
+			}
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
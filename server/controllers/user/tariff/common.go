@@ -0,0 +1,66 @@
+package tariff
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// ResponseList 表示列表响应。
+type ResponseList struct {
+	Data  any   `json:"data"`
+	Count int64 `json:"count"`
+}
+
+// BindTariff 根据 tariff_id 参数查找电价方案。
+// 参数可以在表单或查询字符串中提交。如果电价方案存在，则以 tariff 为键保存，否则直接中止。
+func BindTariff(c *gin.Context) {
+	tariffID := c.PostForm("tariff_id")
+	if len(tariffID) == 0 {
+		tariffID = c.Query("tariff_id")
+	}
+	if len(tariffID) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "tariff id not specified")
+		return
+	}
+	id, err := strconv.ParseUint(tariffID, 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "bad tariff id")
+		return
+	}
+	tariff := models.GetTariff(common.DB, id)
+	if tariff == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, "tariff not found")
+		return
+	}
+	c.Set("tariff", tariff)
+	c.Next()
+}
+
+// BindTariffOrDefault 与 BindTariff 相同，但未指定 tariff_id 时使用默认电价方案。
+func BindTariffOrDefault(c *gin.Context) {
+	if len(c.PostForm("tariff_id")) > 0 || len(c.Query("tariff_id")) > 0 {
+		BindTariff(c)
+		return
+	}
+	tariff := models.GetDefaultTariff(common.DB)
+	if tariff == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, "default tariff not found")
+		return
+	}
+	c.Set("tariff", tariff)
+	c.Next()
+}
+
+// GetTariff 获取 BindTariff 保存的电价方案。
+func GetTariff(c *gin.Context) (*models.Tariff, bool) {
+	v, ok := c.Get("tariff")
+	if !ok {
+		return nil, false
+	}
+	tariff, ok := v.(*models.Tariff)
+	return tariff, ok
+}
@@ -0,0 +1,43 @@
+package tariff
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/analytics"
+	"github.com/vistart/project20240227/server/common"
+	userClient "github.com/vistart/project20240227/server/controllers/user/client"
+)
+
+// GetCost 按电价方案计算 [from, to] 内每个客户端、每种客户端类型及全屋的能耗和费用。
+// 未指定 tariff_id 时使用默认电价方案。每段时间按当时生效的版本计费。
+// 须读取所有客户端在时间范围内的功率记录，因此时间范围与能耗相同，不能超过 EnergyMaxSpan。
+func GetCost(c *gin.Context) {
+	from, to := userClient.GetTimeRange(c)
+	if to.Sub(from) > userClient.EnergyMaxSpan {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "time range too long")
+		return
+	}
+	tariff, ok := GetTariff(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, "invalid tariff")
+		return
+	}
+	versions, err := tariff.GetVersions(common.DB)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	schedule, err := analytics.NewTariffSchedule(tariff, versions)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	result, err := analytics.CalculateHouseholdCost(common.DB, schedule, from, to)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
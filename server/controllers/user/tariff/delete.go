@@ -0,0 +1,35 @@
+package tariff
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// 删除电价方案（默认电价方案及已有版本的电价方案不能删除）
+
+func Delete(c *gin.Context) {
+	tariff, ok := GetTariff(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, "invalid tariff")
+		return
+	}
+
+	total, err := tariff.Delete(common.DB)
+	if errors.Is(err, models.ErrTariffDefault) || errors.Is(err, models.ErrTariffVersioned) {
+		c.AbortWithStatusJSON(http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if total == 0 {
+		c.JSON(http.StatusOK, "tariff not deleted")
+	} else {
+		c.JSON(http.StatusOK, "success")
+	}
+}
@@ -0,0 +1,122 @@
+package tariff
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// 添加、编辑电价方案（仅限名称、货币及是否默认）。电价内容通过添加版本修改。
+
+type RequestEditParams struct {
+	TariffID string `form:"tariff_id"`
+	Name     string `form:"name"`
+	Currency string `form:"currency"`
+	Default  bool   `form:"default"`
+}
+
+func (p *RequestEditParams) String() string {
+	// 定义输出字符串
+	var s string
+
+	// 添加字段值
+	s += fmt.Sprintf("tariff_id=%s ", p.TariffID)
+	s += fmt.Sprintf("name=%s ", p.Name)
+	s += fmt.Sprintf("currency=%s ", p.Currency)
+	s += fmt.Sprintf("default=%t", p.Default)
+
+	// 返回输出字符串
+	return s
+}
+
+func (p *RequestEditParams) Check() error {
+	if len(p.Name) == 0 {
+		return errors.New("name not specified")
+	}
+	if len(p.Name) > 255 {
+		return errors.New("name too long")
+	}
+	if len(p.Currency) == 0 {
+		return errors.New("currency not specified")
+	}
+	if len(p.Currency) > 8 {
+		return errors.New("currency too long")
+	}
+	return nil
+}
+
+// Edit 添加或编辑电价方案。
+// 未指定 tariff_id 时添加新的电价方案，否则修改指定电价方案。名称不能与其它电价方案重复。
+// default 为 true 时，将该电价方案设为默认。
+func Edit(c *gin.Context) {
+	params := RequestEditParams{}
+	if err := c.MustBindWith(&params, binding.Form); err == nil {
+		log.Println(params.String())
+	} else {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := params.Check(); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	existed := models.GetTariffByName(common.DB, params.Name)
+
+	var tariff *models.Tariff
+	if len(params.TariffID) == 0 {
+		// 添加
+		if existed != nil {
+			c.AbortWithStatusJSON(http.StatusConflict, "tariff name duplicated")
+			return
+		}
+		tariff = models.NewTariff(params.Name, params.Currency)
+		if _, err := models.CreateNewTariff(common.DB, tariff); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+	} else {
+		// 编辑
+		id, err := strconv.ParseUint(params.TariffID, 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, "bad tariff id")
+			return
+		}
+		tariff = models.GetTariff(common.DB, id)
+		if tariff == nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, "tariff not found")
+			return
+		}
+		if existed != nil && existed.ID != tariff.ID {
+			c.AbortWithStatusJSON(http.StatusConflict, "tariff name duplicated")
+			return
+		}
+		_, err = tariff.Update(common.DB, params.Name, params.Currency)
+		if errors.Is(err, models.ErrTariffCurrencyVersioned) {
+			c.AbortWithStatusJSON(http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	if params.Default {
+		if err := tariff.SetDefault(common.DB); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+		// 默认电价方案变化后，可延后负载需要重新计划。
+		common.GlobalDeferrablePlanner.ReplanAll()
+	}
+	c.JSON(http.StatusOK, tariff)
+}
@@ -0,0 +1,33 @@
+package tariff
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// 查询某个电价方案信息及其所有版本
+
+type ResponseInfoData struct {
+	*models.Tariff
+	Versions []models.TariffVersion `json:"versions"`
+}
+
+func GetInfo(c *gin.Context) {
+	tariff, ok := GetTariff(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, "invalid tariff")
+		return
+	}
+	versions, err := tariff.GetVersions(common.DB)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, ResponseInfoData{
+		Tariff:   tariff,
+		Versions: versions,
+	})
+}
@@ -0,0 +1,36 @@
+package tariff
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vistart/project20240227/server/common"
+	userClient "github.com/vistart/project20240227/server/controllers/user/client"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// 查询电价方案列表
+
+type ResponseListData struct {
+	Tariffs []models.Tariff `json:"tariffs"`
+}
+
+func List(c *gin.Context) {
+	p, ok := c.Get("page_size")
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "page and size not specified")
+		return
+	}
+	paramPageSize := p.(*userClient.RequestPageParams)
+
+	tariffs, count, err := models.GetTariffs(common.DB, paramPageSize.Page, paramPageSize.Size)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponseList{
+		Data:  ResponseListData{Tariffs: tariffs},
+		Count: count,
+	})
+}
@@ -0,0 +1,104 @@
+package tariff
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/vistart/project20240227/server/analytics"
+	"github.com/vistart/project20240227/server/common"
+	userClient "github.com/vistart/project20240227/server/controllers/user/client"
+	"github.com/vistart/project20240227/server/models"
+)
+
+// 添加电价方案的新版本。已有版本不能修改或删除。
+
+type RequestAddVersionParams struct {
+	EffectiveFrom string `form:"effective_from"`
+	TimeZone      string `form:"time_zone"`
+	Definition    string `form:"definition"`
+}
+
+func (p *RequestAddVersionParams) String() string {
+	// 定义输出字符串
+	var s string
+
+	// 添加字段值
+	s += fmt.Sprintf("effective_from=%s ", p.EffectiveFrom)
+	s += fmt.Sprintf("time_zone=%s ", p.TimeZone)
+	s += fmt.Sprintf("definition=%s", p.Definition)
+
+	// 返回输出字符串
+	return s
+}
+
+// Check 检查参数。时区须为 IANA 时区名称，例如 Asia/Shanghai。
+// 不接受服务端本地时区（Local）：服务端迁移或更改时区后，已有版本的时段会随之改变。
+func (p *RequestAddVersionParams) Check() (time.Time, error) {
+	if len(p.EffectiveFrom) == 0 {
+		return time.Time{}, errors.New("effective from not specified")
+	}
+	effectiveFrom, err := userClient.ParseTime(p.EffectiveFrom)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(p.TimeZone) == 0 {
+		return time.Time{}, errors.New("time zone not specified")
+	}
+	if p.TimeZone == "Local" {
+		return time.Time{}, errors.New("time zone must be an IANA name")
+	}
+	if _, err := time.LoadLocation(p.TimeZone); err != nil {
+		return time.Time{}, err
+	}
+	if _, err := analytics.ParseTariffDefinition(p.Definition); err != nil {
+		return time.Time{}, err
+	}
+	// 时段以分钟划分，生效时间也按分钟对齐。
+	return effectiveFrom.Truncate(time.Minute), nil
+}
+
+// AddVersion 为电价方案添加新版本，自 effective_from 起生效。
+// 第一个版本可以在任意时间生效；之后的版本不能早于当前时间生效，以免改变已出的账单。
+func AddVersion(c *gin.Context) {
+	tariff, ok := GetTariff(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, "invalid tariff")
+		return
+	}
+	params := RequestAddVersionParams{}
+	if err := c.MustBindWith(&params, binding.Form); err == nil {
+		log.Println(params.String())
+	} else {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	effectiveFrom, err := params.Check()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	version := &models.TariffVersion{
+		EffectiveFrom: effectiveFrom,
+		TimeZone:      params.TimeZone,
+		Definition:    params.Definition,
+	}
+	_, err = tariff.AddVersion(common.DB, version, time.Now().Truncate(time.Minute))
+	if errors.Is(err, models.ErrTariffVersionBackdated) {
+		c.AbortWithStatusJSON(http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if tariff.IsDefault {
+		common.GlobalDeferrablePlanner.ReplanAll()
+	}
+	c.JSON(http.StatusOK, version)
+}
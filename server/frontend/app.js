@@ -0,0 +1,316 @@
+"use strict";
+
+// 仪表盘。所有数据均来自 /user 接口，实时数据来自 /user/stream 事件流。
+
+const SPARKLINE_POINTS = 120;
+
+const state = {
+    token: localStorage.getItem("token") || "",
+    clients: new Map(), // 键为客户端ID。
+    stream: null,
+};
+
+const $ = (id) => document.getElementById(id);
+
+// api 发起请求。GET 请求的参数放在查询字符串中，其余请求以表单提交。
+async function api(method, path, params) {
+    const body = new URLSearchParams(params || {});
+    let url = path;
+    const init = {method: method, headers: {"Authorization": "Bearer " + state.token}};
+    if (method === "GET") {
+        url += "?" + body.toString();
+    } else {
+        init.body = body;
+    }
+    const response = await fetch(url, init);
+    const data = await response.json().catch(() => null);
+    if (response.status === 401) {
+        showLogin();
+    }
+    if (!response.ok) {
+        throw new Error(typeof data === "string" ? data : response.statusText);
+    }
+    return data;
+}
+
+function showLogin() {
+    if (state.stream) {
+        state.stream.close();
+        state.stream = null;
+    }
+    state.token = "";
+    localStorage.removeItem("token");
+    $("dashboard").hidden = true;
+    $("login").hidden = false;
+}
+
+async function showDashboard() {
+    $("login").hidden = true;
+    $("dashboard").hidden = false;
+    const me = await api("GET", "/user/me");
+    $("user-name").textContent = me.user.Username + " (" + me.role + ")";
+    await loadClients();
+    await loadPower();
+    await loadPowerModes();
+    openStream();
+}
+
+function logEvent(text) {
+    const li = document.createElement("li");
+    li.textContent = new Date().toLocaleTimeString() + " " + text;
+    $("events").prepend(li);
+    while ($("events").children.length > 200) {
+        $("events").lastChild.remove();
+    }
+}
+
+// 客户端
+
+async function loadClients() {
+    const response = await api("GET", "/user/client/list", {size: 100});
+    state.clients.clear();
+    $("clients").replaceChildren();
+    for (const c of response.data.clients) {
+        const client = {
+            id: c.ID, name: c.Name, type: c.Type, online: c.IsActive,
+            power: null, temperature: null, soc: null, series: [], temperatures: [],
+        };
+        client.row = renderClient(client);
+        state.clients.set(client.id, client);
+        $("clients").append(client.row);
+        updateClient(client);
+        loadSparkline(client);
+    }
+}
+
+function renderClient(client) {
+    const row = document.createElement("tr");
+    row.innerHTML = "<td class=name></td><td class=type></td><td class=state></td><td class=power></td>" +
+        "<td class=temperature></td><td class=soc></td><td><canvas width=240 height=32></canvas></td>" +
+        "<td class=set-power><input type=number min=0 step=1> <button>设置</button></td>" +
+        "<td class=set-setpoint><input type=number min=10 max=32 step=0.5> <button>设置</button></td>" +
+        "<td class=set-battery><input type=number step=100 title=正数充电，负数放电，0待机> <button>设置</button></td>";
+    row.querySelector(".name").textContent = client.name;
+    row.querySelector(".name").title = client.id;
+    row.querySelector(".type").textContent = client.type;
+    bindCommand(client, row.querySelector(".set-power"), "power", "设置功率");
+    bindCommand(client, row.querySelector(".set-setpoint"), "setpoint", "设定温度");
+    bindCommand(client, row.querySelector(".set-battery"), "battery", "设置充放电");
+    return row;
+}
+
+// bindCommand 点击 cell 中的按钮时，以输入框的值向客户端下发 command 命令。
+function bindCommand(client, cell, command, label) {
+    cell.querySelector("button").addEventListener("click", () => {
+        const value = cell.querySelector("input").value;
+        if (value === "") {
+            return;
+        }
+        api("POST", "/user/client/command", {client_id: client.id, command: command, data: value})
+            .then((result) => {
+                // 客户端离线时，命令加入队列，返回队列中的命令。
+                if (result && result.ID) {
+                    logEvent(client.name + " 离线，命令已加入队列");
+                }
+            })
+            .catch((e) => logEvent(client.name + " " + label + "失败：" + e.message));
+    });
+}
+
+function updateClient(client) {
+    const status = client.row.querySelector(".state");
+    status.textContent = client.online ? "在线" : "离线";
+    status.className = "state " + (client.online ? "online" : "offline");
+    client.row.querySelector(".power").textContent = client.online && client.power !== null ? client.power.toFixed(1) : "-";
+    client.row.querySelector(".temperature").textContent = client.online && client.temperature !== null ? client.temperature.toFixed(1) : "-";
+    client.row.querySelector(".soc").textContent = client.online && client.soc !== null ? client.soc.toFixed(1) : "-";
+    drawSparkline(client);
+}
+
+// loadSparkline 加载近一小时每分钟的平均功率和平均室内温度。
+async function loadSparkline(client) {
+    const now = Math.floor(Date.now() / 1000);
+    const load = (field) => api("GET", "/user/client/consumption/aggregate", {
+        client_id: client.id, from: now - 3600, to: now, bucket: "1m", fn: "avg", field: field,
+    }).then((response) => response.data.buckets.map((b) => b.value)).catch(() => null);
+    const [series, temperatures] = await Promise.all([load("consumption"), load("temperature")]);
+    if (series !== null) {
+        client.series = series.concat(client.series).slice(-SPARKLINE_POINTS);
+    }
+    if (temperatures !== null) {
+        client.temperatures = temperatures.concat(client.temperatures).slice(-SPARKLINE_POINTS);
+    }
+    drawSparkline(client);
+}
+
+// drawSparkline 绘制功率曲线，客户端报告室内温度时叠加温度曲线。温度曲线按最小值和最大值缩放。
+function drawSparkline(client) {
+    const canvas = client.row.querySelector("canvas");
+    const ctx = canvas.getContext("2d");
+    ctx.clearRect(0, 0, canvas.width, canvas.height);
+    // 发电客户端的功率为负数。
+    drawLine(ctx, canvas, client.series, Math.min(...client.series, 0), Math.max(...client.series, 1), client.online ? "#1565c0" : "#9e9e9e");
+    if (client.temperatures.length > 0) {
+        const min = Math.min(...client.temperatures);
+        const max = Math.max(...client.temperatures, min + 1);
+        drawLine(ctx, canvas, client.temperatures, min, max, client.online ? "#e65100" : "#bdbdbd");
+    }
+}
+
+function drawLine(ctx, canvas, series, min, max, color) {
+    if (series.length < 2) {
+        return;
+    }
+    const step = canvas.width / (SPARKLINE_POINTS - 1);
+    const offset = SPARKLINE_POINTS - series.length;
+    ctx.beginPath();
+    series.forEach((v, i) => {
+        const x = (offset + i) * step;
+        const y = canvas.height - 1 - ((v - min) / (max - min)) * (canvas.height - 2);
+        i === 0 ? ctx.moveTo(x, y) : ctx.lineTo(x, y);
+    });
+    ctx.strokeStyle = color;
+    ctx.lineWidth = 1.5;
+    ctx.stroke();
+}
+
+// 全屋功率
+
+async function loadPower() {
+    const response = await api("GET", "/user/home/power");
+    for (const p of response.clients) {
+        const client = state.clients.get(p.client_id);
+        if (client) {
+            client.power = p.power;
+            updateClient(client);
+        }
+    }
+    updateTotal();
+}
+
+// updateTotal 更新全屋净功率，以及从电网取用、向电网输出和自用的功率。发电优先自用。
+function updateTotal() {
+    let load = 0;
+    let generation = 0;
+    for (const client of state.clients.values()) {
+        if (client.online && client.power !== null) {
+            if (client.power >= 0) {
+                load += client.power;
+            } else {
+                generation -= client.power;
+            }
+        }
+    }
+    $("total-power").textContent = (load - generation).toFixed(0);
+    $("import-power").textContent = Math.max(load - generation, 0).toFixed(0);
+    $("export-power").textContent = Math.max(generation - load, 0).toFixed(0);
+    $("self-power").textContent = Math.min(load, generation).toFixed(0);
+}
+
+// 能耗模式
+
+async function loadPowerModes() {
+    const response = await api("GET", "/user/power_mode/list", {size: 100});
+    $("power-modes").replaceChildren();
+    for (const mode of response.data.power_modes) {
+        const li = document.createElement("li");
+        const button = document.createElement("button");
+        button.textContent = "执行";
+        button.addEventListener("click", async () => {
+            try {
+                const result = await api("POST", "/user/power_mode/command/execute", {power_mode_id: mode.ID});
+                logEvent(mode.Name + " 已执行：送达 " + result.delivered + "，跳过 " + result.skipped);
+            } catch (e) {
+                logEvent(mode.Name + " 执行失败：" + e.message);
+            }
+        });
+        li.append(button, " " + mode.Name);
+        $("power-modes").append(li);
+    }
+}
+
+// 实时事件流
+
+function openStream() {
+    const stream = new EventSource("/user/stream?token=" + encodeURIComponent(state.token));
+    state.stream = stream;
+    stream.addEventListener("open", () => $("stream-status").className = "online");
+    stream.addEventListener("error", () => $("stream-status").className = "offline");
+    stream.addEventListener("consumption", (e) => {
+        const data = JSON.parse(e.data);
+        const client = state.clients.get(data.client_id);
+        if (!client) {
+            return;
+        }
+        client.online = true;
+        client.power = data.power;
+        client.series.push(data.power);
+        client.series = client.series.slice(-SPARKLINE_POINTS);
+        if (data.temperature !== undefined) {
+            client.temperature = data.temperature;
+            client.temperatures.push(data.temperature);
+            client.temperatures = client.temperatures.slice(-SPARKLINE_POINTS);
+        }
+        updateClient(client);
+        updateTotal();
+    });
+    stream.addEventListener("battery", (e) => {
+        const data = JSON.parse(e.data);
+        const client = state.clients.get(data.client_id);
+        if (!client) {
+            return;
+        }
+        client.soc = data.soc;
+        updateClient(client);
+    });
+    stream.addEventListener("presence", (e) => {
+        const data = JSON.parse(e.data);
+        const client = state.clients.get(data.client_id);
+        if (!client) {
+            loadClients().then(loadPower);
+            return;
+        }
+        if (client.online !== data.online) {
+            logEvent(client.name + (data.online ? " 上线" : " 离线"));
+        }
+        client.online = data.online;
+        if (!data.online) {
+            client.power = null;
+            client.temperature = null;
+            client.soc = null;
+        }
+        updateClient(client);
+        updateTotal();
+    });
+    stream.addEventListener("command", (e) => {
+        const data = JSON.parse(e.data);
+        const client = state.clients.get(data.client_id);
+        logEvent((client ? client.name : data.client_id) + " " + data.name + " " + data.data);
+    });
+}
+
+$("login-form").addEventListener("submit", async (e) => {
+    e.preventDefault();
+    $("login-error").textContent = "";
+    const form = new FormData(e.target);
+    const response = await fetch("/user/login", {method: "POST", body: new URLSearchParams(form)});
+    const data = await response.json().catch(() => null);
+    if (!response.ok) {
+        $("login-error").textContent = typeof data === "string" ? data : response.statusText;
+        return;
+    }
+    state.token = data.token;
+    localStorage.setItem("token", state.token);
+    showDashboard().catch((e) => logEvent(e.message));
+});
+
+$("logout").addEventListener("click", async () => {
+    await api("POST", "/user/logout").catch(() => null);
+    showLogin();
+});
+
+if (state.token) {
+    showDashboard().catch((e) => logEvent(e.message));
+} else {
+    showLogin();
+}
@@ -0,0 +1,16 @@
+package frontend
+
+import (
+	"embed"
+	"net/http"
+)
+
+// files 为内嵌的仪表盘静态文件。仪表盘不依赖任何外部资源，离线也可以使用。
+//
+//go:embed index.html app.js style.css
+var files embed.FS
+
+// FileSystem 返回内嵌的仪表盘静态文件系统。
+func FileSystem() http.FileSystem {
+	return http.FS(files)
+}
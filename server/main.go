@@ -3,11 +3,23 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net/http"
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
 	"github.com/vistart/project20240227/server/common"
 	controllerClient "github.com/vistart/project20240227/server/controllers/client"
+	controllerUserAccount "github.com/vistart/project20240227/server/controllers/user/account"
+	controllerUserAuth "github.com/vistart/project20240227/server/controllers/user/auth"
 	controllerUserClient "github.com/vistart/project20240227/server/controllers/user/client"
+	controllerUserHome "github.com/vistart/project20240227/server/controllers/user/home"
+	controllerUserPowerMode "github.com/vistart/project20240227/server/controllers/user/power_mode"
+	controllerUserPowerModeCommand "github.com/vistart/project20240227/server/controllers/user/power_mode/command"
+	controllerUserPowerModeSchedule "github.com/vistart/project20240227/server/controllers/user/power_mode/schedule"
+	controllerUserStream "github.com/vistart/project20240227/server/controllers/user/stream"
+	controllerUserTariff "github.com/vistart/project20240227/server/controllers/user/tariff"
+	"github.com/vistart/project20240227/server/frontend"
+	"github.com/vistart/project20240227/server/models"
 )
 
 func main() {
@@ -24,67 +36,184 @@ func main() {
 
 	// 访问命令行参数的值
 	config := common.LoadConfig(*inputPtr)
+	common.GlobalConfig = config
 	common.PrepareDatabase(config.Database.DSN)
-	router := gin.Default()
+	common.PrepareAdminUser(config.User.AdminUsername, config.User.AdminPassword)
+	// 与 gin.Default() 相同，但日志中隐去令牌等敏感的查询参数。
+	router := gin.New()
+	router.Use(common.Logger(), gin.Recovery())
 
-	common.GlobalSessionManager = common.NewSessionManager()
+	go common.GlobalNonceCache.Serve()
+	common.GlobalSessionManager = common.NewSessionManager(config.BroadcastTimestamp.ReplayBufferSize)
 	go common.GlobalSessionManager.Serve()
 	go common.GlobalSessionManager.BroadcastTimestamp(config.BroadcastTimestamp.BroadcastTimestampInterval)
+	common.GlobalCommandTracker = common.NewCommandTracker(config.Command, common.GlobalSessionManager)
+	go common.GlobalCommandTracker.Serve()
+	common.GlobalPendingCommandQueue = common.NewPendingCommandQueue(common.GlobalSessionManager)
+	go common.GlobalPendingCommandQueue.Serve()
+	common.GlobalPowerModeScheduler = common.NewPowerModeScheduler(common.GlobalSessionManager)
+	go common.GlobalPowerModeScheduler.Serve()
+	common.GlobalDeferrablePlanner = common.NewDeferrablePlanner(common.GlobalSessionManager)
+	go common.GlobalDeferrablePlanner.Serve()
+	if config.LoadShedding.Enabled {
+		common.GlobalLoadShedder = common.NewLoadShedder(config.LoadShedding, common.GlobalSessionManager)
+		go common.GlobalLoadShedder.Serve()
+	}
 
 	bindRouter(router)
 	router.Run(fmt.Sprintf(":%d", config.Port))
 }
 
 func bindRouter(e *gin.Engine) {
+	// 仪表盘。静态文件内嵌在程序中。
+	e.StaticFS("/dashboard", frontend.FileSystem())
+	e.GET("/", func(c *gin.Context) {
+		c.Redirect(http.StatusFound, "/dashboard/")
+	})
+
 	client := e.Group("/client")
+	// 客户端使用注册码换取客户端编号和密钥。
+	client.POST("/enroll", controllerClient.Enroll)
 	// 客户端向服务端注册，服务端向客户端发送命令。Server-sent event模式。
 	client.POST("/register", controllerClient.Authorize, common.GlobalSessionManager.SetHeadersHandler(), common.GlobalSessionManager.NewSessionChannelHandler(), controllerClient.Register)
 	// 客户端向服务端报告状态。
 	client.POST("/report", controllerClient.Authorize, common.GlobalSessionManager.GetClientHandler(), controllerClient.Report)
+	// 客户端批量补报服务端不可达期间的功耗。
+	client.POST("/report/batch", controllerClient.Authorize, controllerClient.ReportBatch)
+	// 客户端确认已执行服务端下发的命令。
+	client.POST("/ack", controllerClient.Authorize, controllerClient.Ack)
+
+	// 用户相关。除登录外，均需要先验证用户，并按角色限制访问。
+	user := e.Group("/user")
+	viewer := controllerUserAuth.RequireRole(models.UserRoleViewer)
+	operator := controllerUserAuth.RequireRole(models.UserRoleOperator)
+	admin := controllerUserAuth.RequireRole(models.UserRoleAdmin)
+	// 用户登录。
+	user.POST("/login", controllerUserAuth.Login)
+	authorized := user.Group("", controllerUserAuth.Authenticate)
+	// 用户注销。
+	authorized.POST("/logout", controllerUserAuth.Logout)
+	// 获取当前用户信息。
+	authorized.GET("/me", controllerUserAuth.Me)
+
+	// 用户管理
+	userAccount := authorized.Group("/account", admin)
+	// 用户列表。
+	userAccount.GET("/list", controllerUserClient.BindPageSize, controllerUserAccount.List)
+	// 添加/编辑用户。
+	userAccount.POST("", controllerUserAccount.Edit)
+	// 删除用户。
+	userAccount.DELETE("", controllerUserAccount.Delete)
 
 	// 用户客户端相关
-	userClient := e.Group("/user/client")
-	// 向客户端发送命令
-	userClient.POST("/command", controllerUserClient.Authorize, common.GlobalSessionManager.GetClientHandler(), controllerUserClient.SendCommand)
+	userClient := authorized.Group("/client")
+	// 向客户端发送命令。客户端离线时加入队列，待客户端连接后下发。
+	userClient.POST("/command", operator, controllerUserClient.Authorize, controllerUserClient.SendCommand)
+	// 客户端离线时等待下发的命令队列。
+	userClient.GET("/pending", viewer, controllerUserClient.Authorize, controllerUserClient.BindPageSize, controllerUserClient.GetPendingCommands)
+	// 取消等待下发的命令。未指定 id 时取消该客户端所有等待下发的命令。
+	userClient.DELETE("/pending", operator, controllerUserClient.Authorize, controllerUserClient.CancelPendingCommand)
 	// 客户端列表。
-	userClient.GET("/list", controllerUserClient.BindPageSize, controllerUserClient.List)
+	userClient.GET("/list", viewer, controllerUserClient.BindPageSize, controllerUserClient.List)
 	// 获取某个客户端信息。
-	userClient.GET("/info", controllerUserClient.Authorize, controllerUserClient.GetInfo)
+	userClient.GET("/info", viewer, controllerUserClient.Authorize, controllerUserClient.GetInfo)
 	// 编辑某个客户端信息。
-	userClient.POST("/info", controllerUserClient.Authorize, common.GlobalSessionManager.GetClientHandler(), controllerUserClient.EditInfo)
+	userClient.POST("/info", operator, controllerUserClient.Authorize, common.GlobalSessionManager.GetClientHandler(), controllerUserClient.EditInfo)
 	// 删除某个客户端信息。
-	userClient.DELETE("/info", controllerUserClient.Authorize, common.GlobalSessionManager.GetClientHandler(), controllerUserClient.DeleteInfo)
+	userClient.DELETE("/info", admin, controllerUserClient.Authorize, common.GlobalSessionManager.GetClientHandler(), controllerUserClient.DeleteInfo)
+	// 创建客户端注册码。
+	userClient.POST("/enrollment", admin, controllerUserClient.CreateEnrollment)
+	// 客户端注册码列表。
+	userClient.GET("/enrollment/list", admin, controllerUserClient.BindPageSize, controllerUserClient.GetEnrollments)
+	// 为某个客户端生成新的密钥。
+	userClient.POST("/secret", admin, controllerUserClient.Authorize, controllerUserClient.ResetSecret)
 	// 获取某个客户端的能耗列表。
-	userClient.GET("/consumption", controllerUserClient.Authorize, controllerUserClient.BindPageSize, controllerUserClient.GetConsumptions)
+	userClient.GET("/consumption", viewer, controllerUserClient.Authorize, controllerUserClient.BindPageSize, controllerUserClient.GetConsumptions)
+	// 按时间分桶聚合某个客户端的能耗记录。
+	userClient.GET("/consumption/aggregate", viewer, controllerUserClient.Authorize, controllerUserClient.BindTimeRange, controllerUserClient.AggregateConsumptions)
+	// 获取某个客户端在一段时间内的能耗（千瓦时）。
+	userClient.GET("/energy", viewer, controllerUserClient.Authorize, controllerUserClient.BindTimeRange, controllerUserClient.GetEnergy)
+	// 获取某个储能客户端在一段时间内的荷电状态。
+	userClient.GET("/battery", viewer, controllerUserClient.Authorize, controllerUserClient.BindTimeRange, controllerUserClient.GetBatteryStates)
+	// 设置可延后负载的用电需求。
+	userClient.POST("/deferrable", operator, controllerUserClient.Authorize, controllerUserClient.SetDeferrable)
+	// 取消可延后负载的用电需求。
+	userClient.DELETE("/deferrable", operator, controllerUserClient.Authorize, controllerUserClient.DeleteDeferrable)
+	// 获取可延后负载的运行计划。
+	userClient.GET("/plan", viewer, controllerUserClient.Authorize, controllerUserClient.GetPlan)
 	// 获取某个客户端的能耗模式历史。
-	userClient.GET("/command", controllerUserClient.Authorize, controllerUserClient.BindPageSize, controllerUserClient.GetPowerModeHistories)
+	userClient.GET("/command", viewer, controllerUserClient.Authorize, controllerUserClient.BindPageSize, controllerUserClient.GetPowerModeHistories)
+
+	// 仪表盘实时事件流。
+	authorized.GET("/stream", viewer, common.GlobalSessionManager.SetHeadersHandler(), controllerUserStream.Stream)
+
+	// 全屋相关
+	userHome := authorized.Group("/home")
+	// 全屋实时功率。
+	userHome.GET("/power", viewer, controllerUserHome.GetPower)
 
 	// 能耗模式
-	userPowerMode := e.Group("/user/power_mode")
+	userPowerMode := authorized.Group("/power_mode")
 	// 能耗模式列表。
-	userPowerMode.GET("/list")
+	userPowerMode.GET("/list", viewer, controllerUserClient.BindPageSize, controllerUserPowerMode.List)
 	// 获取某个能耗模式。
-	userPowerMode.GET("")
+	userPowerMode.GET("", viewer, controllerUserPowerMode.BindPowerMode, controllerUserPowerMode.GetInfo)
 	// 添加/编辑某个能耗模式。
-	userPowerMode.POST("")
+	userPowerMode.POST("", operator, controllerUserPowerMode.Edit)
 	// 删除某个能耗模式。
-	userPowerMode.DELETE("")
+	userPowerMode.DELETE("", operator, controllerUserPowerMode.BindPowerMode, controllerUserPowerMode.Delete)
 
 	// 能耗模式命令相关
 	userPowerModeCommand := userPowerMode.Group("/command")
 
-	// 获取能耗模式执行历史。
-	userPowerModeCommand.GET("")
+	// 获取指定能耗模式的命令列表。
+	userPowerModeCommand.GET("", viewer, controllerUserPowerMode.BindPowerMode, controllerUserPowerModeCommand.List)
 
 	// 为指定能耗模式添加命令
-	userPowerModeCommand.POST("")
+	userPowerModeCommand.POST("", operator, controllerUserPowerMode.BindPowerMode, controllerUserPowerModeCommand.Add)
 
 	// 删除指定能耗模式的具体命令。
-	userPowerModeCommand.DELETE("")
+	userPowerModeCommand.DELETE("", operator, controllerUserPowerMode.BindPowerMode, controllerUserPowerModeCommand.Delete)
 
 	// 执行指定能耗模式。
-	userPowerModeCommand.POST("/execute")
+	userPowerModeCommand.POST("/execute", operator, controllerUserPowerMode.BindPowerMode, controllerUserPowerModeCommand.Execute)
 
 	// 查询指定能耗模式执行历史。
-	userPowerModeCommand.GET("/executions")
+	userPowerModeCommand.GET("/executions", viewer, controllerUserPowerMode.BindPowerMode, controllerUserClient.BindPageSize, controllerUserPowerModeCommand.GetExecutions)
+
+	// 能耗模式执行计划相关
+	userPowerModeSchedule := userPowerMode.Group("/schedule")
+
+	// 获取指定能耗模式的执行计划列表。
+	userPowerModeSchedule.GET("", viewer, controllerUserPowerMode.BindPowerMode, controllerUserPowerModeSchedule.List)
+
+	// 添加/编辑执行计划。
+	userPowerModeSchedule.POST("", operator, controllerUserPowerModeSchedule.Edit)
+
+	// 删除执行计划。
+	userPowerModeSchedule.DELETE("", operator, controllerUserPowerModeSchedule.BindSchedule, controllerUserPowerModeSchedule.Delete)
+
+	// 预览执行计划接下来的触发时刻。
+	userPowerModeSchedule.GET("/preview", viewer, controllerUserPowerModeSchedule.Preview)
+
+	// 电价相关
+	userTariff := authorized.Group("/tariff")
+
+	// 查询电价方案列表。
+	userTariff.GET("/list", viewer, controllerUserClient.BindPageSize, controllerUserTariff.List)
+
+	// 查询指定电价方案及其所有版本。
+	userTariff.GET("", viewer, controllerUserTariff.BindTariff, controllerUserTariff.GetInfo)
+
+	// 添加/编辑电价方案。
+	userTariff.POST("", admin, controllerUserTariff.Edit)
+
+	// 删除电价方案。
+	userTariff.DELETE("", admin, controllerUserTariff.BindTariff, controllerUserTariff.Delete)
+
+	// 添加电价方案的新版本。
+	userTariff.POST("/version", admin, controllerUserTariff.BindTariff, controllerUserTariff.AddVersion)
+
+	// 计算每个客户端、每种客户端类型及全屋的费用。
+	userTariff.GET("/cost", viewer, controllerUserTariff.BindTariffOrDefault, controllerUserClient.BindTimeRange, controllerUserTariff.GetCost)
 }
@@ -1,15 +1,20 @@
 package models
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Client struct {
 	ID        string     `gorm:"primaryKey;size:255;column:id"`
 	Name      string     `gorm:"column:name;size:255;not null"`
 	Type      ClientType `gorm:"column:type;type:int:not null"`
+	Secret    string     `gorm:"column:secret;size:64;not null;default:''" json:"-"`
+	Priority  int32      `gorm:"column:priority;not null;default:0"` // 优先级。超出功率上限时，优先级低的客户端先被切除。
 	CreatedAt *time.Time `gorm:"column:created_at;autoCreateTime:milli;not null;default:current_timestamp(3)"`
 	UpdatedAt *time.Time `gorm:"column:updated_at;autoUpdateTime:milli;not null;default:current_timestamp(3);onUpdate:default:current_timestamp(3)"`
 
@@ -27,6 +32,15 @@ func NewClient(id string, name string, clientType ClientType) *Client {
 	}
 }
 
+// NewClientSecret 生成新的客户端密钥，用于 HMAC-SHA256 签名。
+func NewClientSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // RegisterNewClient registers a new Client in the database.
 // It accepts a database connection and a Client struct as input.
 // It returns the number of rows affected (should be 1 for a successful insertion)
@@ -206,8 +220,8 @@ func (c *Client) GetCommandExecutions(db *gorm.DB, page, pageSize int, code *int
 	return records, int(total), nil
 }
 
-// InsertNewCommandExecution 插入一条命令执行历史。
-func (c *Client) InsertNewCommandExecution(db *gorm.DB, code int, data string, sentAt *time.Time) (int64, error) {
+// InsertNewCommandExecution 插入一条命令执行历史。reason 表示发送命令的原因，例如 ClientCommandExecutionReasonManual。
+func (c *Client) InsertNewCommandExecution(db *gorm.DB, code int, data string, sentAt *time.Time, reason string) (int64, error) {
 	now := time.Now()
 	if sentAt == nil {
 		sentAt = &now
@@ -216,6 +230,7 @@ func (c *Client) InsertNewCommandExecution(db *gorm.DB, code int, data string, s
 		ClientID: c.ID,
 		Code:     code,
 		Data:     data,
+		Reason:   reason,
 		SentAt:   *sentAt,
 	}
 	tx := db.Save(record)
@@ -260,19 +275,73 @@ func (c *Client) GetConsumptions(db *gorm.DB, page, pageSize int) ([]ClientConsu
 	return records, total, nil
 }
 
-func (c *Client) InsertNewConsumption(db *gorm.DB, consumption float32, recordedAt time.Time) (int64, error) {
+// GetConsumptionsBetween 返回当前 Client 在 [from, to] 内的所有能耗记录，按记录时间正序排列。
+func (c *Client) GetConsumptionsBetween(db *gorm.DB, from, to time.Time) ([]ClientConsumption, error) {
+	var records []ClientConsumption
+	err := db.Where("client_id = ? AND recorded_at BETWEEN ? AND ?", c.ID, from, to).Order("recorded_at").Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// GetActivitiesBetween 返回当前 Client 在 [from, to] 内的所有 Activity，按时间正序排列。
+// 如果 from 之前存在 Activity，则最后一条也包含在内，用于确定 from 时刻的状态。
+func (c *Client) GetActivitiesBetween(db *gorm.DB, from, to time.Time) ([]ClientActivity, error) {
+	var records []ClientActivity
+	var last ClientActivity
+	tx := db.Where("client_id = ? AND created_at < ?", c.ID, from).Order("created_at desc").Limit(1).Find(&last)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected > 0 {
+		records = append(records, last)
+	}
+	var inRange []ClientActivity
+	err := db.Where("client_id = ? AND created_at BETWEEN ? AND ?", c.ID, from, to).Order("created_at").Find(&inRange).Error
+	if err != nil {
+		return nil, err
+	}
+	return append(records, inRange...), nil
+}
+
+// InsertNewConsumption 插入一条能耗记录。temperature 为报告的室内温度，未报告时为空。
+// 同一客户端同一记录时间的记录已存在时忽略，返回 0。
+func (c *Client) InsertNewConsumption(db *gorm.DB, consumption float32, temperature *float32, recordedAt time.Time) (int64, error) {
 	record := &ClientConsumption{
 		ClientID:    c.ID,
 		Consumption: consumption,
+		Temperature: temperature,
 		RecordedAt:  recordedAt,
 	}
-	tx := db.Save(record)
+	tx := db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
 	if tx.Error != nil {
 		return 0, tx.Error
 	}
 	return tx.RowsAffected, nil
 }
 
+// InsertNewConsumptions 在一个事务中插入多条能耗记录，任意一条失败时全部不保存。
+// 同一客户端同一记录时间的记录已存在时忽略，因此重复补报是安全的。返回实际插入的记录数。
+func (c *Client) InsertNewConsumptions(db *gorm.DB, consumptions []ClientConsumption) (int64, error) {
+	if len(consumptions) == 0 {
+		return 0, nil
+	}
+	for i := range consumptions {
+		consumptions[i].ClientID = c.ID
+	}
+	var affected int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&consumptions)
+		if result.Error != nil {
+			return result.Error
+		}
+		affected = result.RowsAffected
+		return nil
+	})
+	return affected, err
+}
+
 // UpdateName 更新当前客户端的名称。
 func (c *Client) UpdateName(db *gorm.DB, name string) (int64, error) {
 	c.Name = name
@@ -283,6 +352,42 @@ func (c *Client) UpdateName(db *gorm.DB, name string) (int64, error) {
 	return tx.RowsAffected, nil
 }
 
+// UpdatePriority 更新当前客户端的优先级。
+func (c *Client) UpdatePriority(db *gorm.DB, priority int32) (int64, error) {
+	c.Priority = priority
+	tx := db.Model(c).Update("priority", priority)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
+
+// GetClientPriorities 查询指定客户端的优先级。键为客户端ID，不存在的客户端不会出现在结果中。
+func GetClientPriorities(db *gorm.DB, ids []string) (map[string]int32, error) {
+	priorities := make(map[string]int32)
+	if len(ids) == 0 {
+		return priorities, nil
+	}
+	var clients []Client
+	if err := db.Select("id", "priority").Where("id in ?", ids).Find(&clients).Error; err != nil {
+		return nil, err
+	}
+	for _, client := range clients {
+		priorities[client.ID] = client.Priority
+	}
+	return priorities, nil
+}
+
+// UpdateSecret 更新当前客户端的密钥。
+func (c *Client) UpdateSecret(db *gorm.DB, secret string) (int64, error) {
+	c.Secret = secret
+	tx := db.Model(c).Update("secret", secret)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
+
 // Delete 删除自身。
 func (c *Client) Delete(db *gorm.DB) (int64, error) {
 	tx := db.Delete(c)
@@ -2,6 +2,13 @@ package models
 
 import "time"
 
+const (
+	// ClientActivityOff 表示设备断开连接。
+	ClientActivityOff = iota
+	// ClientActivityOn 表示设备连接。
+	ClientActivityOn
+)
+
 type ClientActivity struct {
 	ID        uint64     `gorm:"column:id;primaryKey;autoIncrement"`
 	ClientID  string     `gorm:"column:client_id;size:255;not null"`
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ClientBatteryState 表示储能客户端报告的荷电状态（SoC），与 ClientConsumption 中的功率一起用于评估充放电策略。
+type ClientBatteryState struct {
+	ID         uint64     `json:"-" gorm:"column:id;primaryKey;autoIncrement"`
+	ClientID   string     `json:"-" gorm:"column:client_id;size:255;not null"`
+	SoC        float32    `json:"soc" gorm:"column:soc;not null"` // 荷电状态，单位为百分比。
+	RecordedAt time.Time  `json:"recorded_at" gorm:"column:recorded_at;not null"`
+	CreatedAt  *time.Time `json:"-" gorm:"column:created_at;autoCreateTime:milli;not null;default:current_timestamp(3)"`
+}
+
+func (ClientBatteryState) TableName() string {
+	return "client_battery_state"
+}
+
+// InsertNewBatteryState 插入一条荷电状态记录。同一客户端同一记录时间的记录已存在时忽略，返回 0。
+func (c *Client) InsertNewBatteryState(db *gorm.DB, soc float32, recordedAt time.Time) (int64, error) {
+	record := &ClientBatteryState{
+		ClientID:   c.ID,
+		SoC:        soc,
+		RecordedAt: recordedAt,
+	}
+	tx := db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
+
+// InsertNewBatteryStates 在一个事务中插入多条荷电状态记录，任意一条失败时全部不保存。
+// 同一客户端同一记录时间的记录已存在时忽略。返回实际插入的记录数。
+func (c *Client) InsertNewBatteryStates(db *gorm.DB, states []ClientBatteryState) (int64, error) {
+	if len(states) == 0 {
+		return 0, nil
+	}
+	for i := range states {
+		states[i].ClientID = c.ID
+	}
+	var affected int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&states)
+		if result.Error != nil {
+			return result.Error
+		}
+		affected = result.RowsAffected
+		return nil
+	})
+	return affected, err
+}
+
+// GetBatteryStatesBetween 返回当前 Client 在 [from, to] 内的所有荷电状态记录，按记录时间正序排列。
+func (c *Client) GetBatteryStatesBetween(db *gorm.DB, from, to time.Time) ([]ClientBatteryState, error) {
+	var records []ClientBatteryState
+	err := db.Where("client_id = ? AND recorded_at BETWEEN ? AND ?", c.ID, from, to).Order("recorded_at").Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestClient_BatteryStates 测试保存和按时间范围查询荷电状态。
+func TestClient_BatteryStates(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	result, err := client.InsertNewBatteryState(db, 50, from)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+	result, err = client.InsertNewBatteryStates(db, []ClientBatteryState{
+		{SoC: 52.5, RecordedAt: from.Add(time.Minute)},
+		{SoC: 55, RecordedAt: from.Add(2 * time.Minute)},
+	})
+	assert.Equal(t, int64(2), result)
+	assert.Nil(t, err)
+	// 重复补报的记录被忽略。
+	result, err = client.InsertNewBatteryStates(db, []ClientBatteryState{{SoC: 55, RecordedAt: from.Add(2 * time.Minute)}})
+	assert.Equal(t, int64(0), result)
+	assert.Nil(t, err)
+
+	states, err := client.GetBatteryStatesBetween(db, from, from.Add(time.Minute))
+	assert.Nil(t, err)
+	assert.Len(t, states, 2)
+	assert.Equal(t, float32(50), states[0].SoC)
+	assert.Equal(t, float32(52.5), states[1].SoC)
+}
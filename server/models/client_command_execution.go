@@ -1,17 +1,43 @@
 package models
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ClientCommandExecutionReasonManual       = "manual"        // 用户手动发送。
+	ClientCommandExecutionReasonPowerMode    = "power_mode"    // 执行能耗模式。
+	ClientCommandExecutionReasonLoadShedding = "load_shedding" // 总功率超出上限，切除负载。
+	ClientCommandExecutionReasonLoadRestore  = "load_restore"  // 总功率恢复，恢复被切除的负载。
+	ClientCommandExecutionReasonDeferrable   = "deferrable"    // 按可延后负载的运行计划调整功率。
+)
+
+const (
+	ClientCommandExecutionStatusSent       = iota // 已发送，等待客户端确认。
+	ClientCommandExecutionStatusAcked             // 客户端已确认。
+	ClientCommandExecutionStatusUnacked           // 重试次数用尽后仍未确认。
+	ClientCommandExecutionStatusSuperseded        // 确认前被同一客户端的同类新命令取代，不再重新发送。
 )
 
 // ClientCommandExecution 表示客户端命令执行历史。
 type ClientCommandExecution struct {
-	ID        uint64     `gorm:"column:id;primaryKey;autoIncrement"`
-	ClientID  string     `gorm:"column:client_id;size:255;not null"`
-	Code      int        `gorm:"column:code;not null"`
-	Data      string     `gorm:"column:data;type:text;not null"`
-	SentAt    time.Time  `gorm:"column:sent_at;type:timestamp;not null"`
-	CreatedAt *time.Time `gorm:"column:created_at;autoCreateTime:milli;not null;default:current_timestamp(3)"`
+	ID           uint64     `gorm:"column:id;primaryKey;autoIncrement"`
+	ClientID     string     `gorm:"column:client_id;size:255;not null"`
+	CommandID    string     `gorm:"column:command_id;size:32;not null;default:''"` // 随命令下发的ID，客户端确认时回传。
+	Code         int        `gorm:"column:code;not null"`
+	Data         string     `gorm:"column:data;type:text;not null"`
+	Reason       string     `gorm:"column:reason;size:32;not null;default:''"`
+	Status       int8       `gorm:"column:status;not null;default:0"`
+	Retries      int        `gorm:"column:retries;not null;default:0"` // 未确认时重新发送的次数。
+	SentAt       time.Time  `gorm:"column:sent_at;type:timestamp;not null"`
+	AckedAt      *time.Time `gorm:"column:acked_at"`
+	AppliedValue *string    `gorm:"column:applied_value;size:255"` // 客户端确认时报告的实际生效值。
+	CreatedAt    *time.Time `gorm:"column:created_at;autoCreateTime:milli;not null;default:current_timestamp(3)"`
 
 	// 多对多关系
 	ClientActivities []ClientActivity `gorm:"many2many:Client"`
@@ -21,3 +47,102 @@ type ClientCommandExecution struct {
 func (ClientCommandExecution) TableName() string {
 	return "client_command_execution"
 }
+
+// ErrCommandAcknowledged 表示命令已经确认过。
+var ErrCommandAcknowledged = errors.New("command already acknowledged")
+
+// NewClientCommandExecution 实例化一条命令执行历史。reason 表示发送命令的原因。
+func NewClientCommandExecution(clientID string, code int, data string, sentAt time.Time, reason string) *ClientCommandExecution {
+	return &ClientCommandExecution{
+		ClientID: clientID,
+		Code:     code,
+		Data:     data,
+		Reason:   reason,
+		SentAt:   sentAt,
+	}
+}
+
+// NewCommandID 生成新的命令ID，由32位十六进制字符组成。
+func NewCommandID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GetClientCommandExecutionByCommandID 根据命令ID获取某个客户端的命令执行历史。不存在时返回 nil。
+func GetClientCommandExecutionByCommandID(db *gorm.DB, clientID string, commandID string) *ClientCommandExecution {
+	var record ClientCommandExecution
+	tx := db.Where("client_id = ? and command_id = ?", clientID, commandID).Take(&record)
+	if tx.Error != nil {
+		return nil
+	}
+	return &record
+}
+
+// GetLastAckedClientCommandExecution 获取某个客户端在 before 之前发送、已确认的最后一条 code 命令，不包括因 excludeReason 发送的命令。
+// 不存在时返回 nil。例如负载切除后恢复客户端时，据此恢复切除前生效的功率。
+func GetLastAckedClientCommandExecution(db *gorm.DB, clientID string, code int, before time.Time, excludeReason string) *ClientCommandExecution {
+	var record ClientCommandExecution
+	tx := db.Where("client_id = ? and code = ? and status = ? and sent_at < ? and reason <> ?",
+		clientID, code, ClientCommandExecutionStatusAcked, before, excludeReason).Order("sent_at desc").Order("id desc").Take(&record)
+	if tx.Error != nil {
+		return nil
+	}
+	return &record
+}
+
+// Ack 记录客户端对命令的确认及实际生效值。已标记为未确认的命令仍可确认，以记录迟到的确认；
+// 已确认过的命令返回 ErrCommandAcknowledged。
+func (e *ClientCommandExecution) Ack(db *gorm.DB, value string, ackedAt time.Time) (int64, error) {
+	tx := db.Model(e).Where("status <> ?", ClientCommandExecutionStatusAcked).Updates(map[string]any{
+		"status":        ClientCommandExecutionStatusAcked,
+		"acked_at":      ackedAt,
+		"applied_value": value,
+	})
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return 0, ErrCommandAcknowledged
+	}
+	e.Status = ClientCommandExecutionStatusAcked
+	e.AckedAt = &ackedAt
+	e.AppliedValue = &value
+	return tx.RowsAffected, nil
+}
+
+// UpdateRetries 更新重新发送的次数。
+func (e *ClientCommandExecution) UpdateRetries(db *gorm.DB, retries int) (int64, error) {
+	tx := db.Model(e).Update("retries", retries)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	e.Retries = retries
+	return tx.RowsAffected, nil
+}
+
+// MarkUnacked 将仍在等待确认的命令标记为未确认。
+func (e *ClientCommandExecution) MarkUnacked(db *gorm.DB) (int64, error) {
+	tx := db.Model(e).Where("status = ?", ClientCommandExecutionStatusSent).Update("status", ClientCommandExecutionStatusUnacked)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	if tx.RowsAffected > 0 {
+		e.Status = ClientCommandExecutionStatusUnacked
+	}
+	return tx.RowsAffected, nil
+}
+
+// MarkSuperseded 将仍在等待确认的命令标记为已被取代。
+func (e *ClientCommandExecution) MarkSuperseded(db *gorm.DB) (int64, error) {
+	tx := db.Model(e).Where("status = ?", ClientCommandExecutionStatusSent).Update("status", ClientCommandExecutionStatusSuperseded)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	if tx.RowsAffected > 0 {
+		e.Status = ClientCommandExecutionStatusSuperseded
+	}
+	return tx.RowsAffected, nil
+}
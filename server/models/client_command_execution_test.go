@@ -0,0 +1,103 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestClientCommandExecution_Ack 测试确认命令。命令只能确认一次，迟到的确认仍然记录。
+func TestClientCommandExecution_Ack(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	commandID, err := NewCommandID()
+	assert.Nil(t, err)
+	assert.Len(t, commandID, 32)
+
+	now := time.Now()
+	execution := NewClientCommandExecution(client.ID, 2, "{\"power\":0}", now, ClientCommandExecutionReasonManual)
+	execution.CommandID = commandID
+	assert.Nil(t, db.Create(execution).Error)
+
+	assert.Nil(t, GetClientCommandExecutionByCommandID(db, "unknown", commandID))
+	record := GetClientCommandExecutionByCommandID(db, client.ID, commandID)
+	assert.NotNil(t, record)
+	assert.Equal(t, int8(ClientCommandExecutionStatusSent), record.Status)
+
+	_, err = record.UpdateRetries(db, 1)
+	assert.Nil(t, err)
+	result, err := record.MarkUnacked(db)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+	result, err = record.Ack(db, "0", now)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+	_, err = record.Ack(db, "0", now)
+	assert.ErrorIs(t, err, ErrCommandAcknowledged)
+
+	record = GetClientCommandExecutionByCommandID(db, client.ID, commandID)
+	assert.Equal(t, int8(ClientCommandExecutionStatusAcked), record.Status)
+	assert.Equal(t, 1, record.Retries)
+	assert.Equal(t, "0", *record.AppliedValue)
+
+	// 已确认的命令不会被标记为未确认。
+	result, err = record.MarkUnacked(db)
+	assert.Equal(t, int64(0), result)
+	assert.Nil(t, err)
+}
+
+// TestClientCommandExecution_MarkSuperseded 测试将等待确认的命令标记为已被取代。已确认的命令不受影响。
+func TestClientCommandExecution_MarkSuperseded(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	now := time.Now()
+	older := NewClientCommandExecution(client.ID, 2, "{\"power\":0}", now, ClientCommandExecutionReasonManual)
+	older.CommandID, _ = NewCommandID()
+	assert.Nil(t, db.Create(older).Error)
+	acked := NewClientCommandExecution(client.ID, 2, "{\"power\":100}", now, ClientCommandExecutionReasonManual)
+	acked.CommandID, _ = NewCommandID()
+	assert.Nil(t, db.Create(acked).Error)
+	_, err := acked.Ack(db, "100", now)
+	assert.Nil(t, err)
+
+	result, err := older.MarkSuperseded(db)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+	assert.Equal(t, int8(ClientCommandExecutionStatusSuperseded), GetClientCommandExecutionByCommandID(db, client.ID, older.CommandID).Status)
+
+	result, err = acked.MarkSuperseded(db)
+	assert.Equal(t, int64(0), result)
+	assert.Nil(t, err)
+	assert.Equal(t, int8(ClientCommandExecutionStatusAcked), GetClientCommandExecutionByCommandID(db, client.ID, acked.CommandID).Status)
+}
+
+// TestGetLastAckedClientCommandExecution 测试获取最后一条已确认的命令，不包括指定原因的命令。
+func TestGetLastAckedClientCommandExecution(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	now := time.Now().Truncate(time.Second)
+	create := func(data string, reason string, sentAt time.Time, value string) {
+		execution := NewClientCommandExecution(client.ID, 2, data, sentAt, reason)
+		execution.CommandID, _ = NewCommandID()
+		assert.Nil(t, db.Create(execution).Error)
+		if len(value) > 0 {
+			_, err := execution.Ack(db, value, sentAt)
+			assert.Nil(t, err)
+		}
+	}
+	assert.Nil(t, GetLastAckedClientCommandExecution(db, client.ID, 2, now, ClientCommandExecutionReasonLoadShedding))
+
+	create("{\"power\":500}", ClientCommandExecutionReasonManual, now.Add(-3*time.Minute), "500")
+	create("{\"power\":800}", ClientCommandExecutionReasonManual, now.Add(-2*time.Minute), "")
+	create("{\"power\":0}", ClientCommandExecutionReasonLoadShedding, now.Add(-time.Minute), "0")
+
+	record := GetLastAckedClientCommandExecution(db, client.ID, 2, now, ClientCommandExecutionReasonLoadShedding)
+	if assert.NotNil(t, record) {
+		assert.Equal(t, "500", *record.AppliedValue)
+	}
+	assert.Nil(t, GetLastAckedClientCommandExecution(db, client.ID, 2, now.Add(-3*time.Minute), ClientCommandExecutionReasonLoadShedding))
+}
@@ -1,11 +1,17 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type ClientConsumption struct {
 	ID          uint64     `json:"-" gorm:"column:id;primaryKey;autoIncrement"`
 	ClientID    string     `json:"-" gorm:"column:client_id;size:255;not null"`
-	Consumption float32    `gorm:"column:consumption;not null"`
+	Consumption float32    `gorm:"column:consumption;not null"`          // 单位为瓦。为负数时表示发电。
+	Temperature *float32   `json:",omitempty" gorm:"column:temperature"` // 室内温度，单位为摄氏度。未报告温度的客户端为空。
 	RecordedAt  time.Time  `gorm:"column:recorded_at;not null"`
 	CreatedAt   *time.Time `json:"-" gorm:"column:created_at;autoCreateTime:milli;not null;default:current_timestamp(3)"`
 }
@@ -14,3 +20,76 @@ type ClientConsumption struct {
 func (ClientConsumption) TableName() string {
 	return "client_consumption"
 }
+
+// ClientConsumptionAggregateFunctions 表示支持的聚合函数及对应的 SQL 表达式，%s 为聚合的字段。
+var ClientConsumptionAggregateFunctions = map[string]string{
+	"avg":   "AVG(%s)",
+	"min":   "MIN(%s)",
+	"max":   "MAX(%s)",
+	"sum":   "SUM(%s)",
+	"count": "COUNT(%s)",
+}
+
+// ClientConsumptionAggregateFields 表示支持聚合的字段。
+var ClientConsumptionAggregateFields = map[string]string{
+	"consumption": "consumption",
+	"temperature": "temperature",
+}
+
+// ClientConsumptionBucket 表示一个时间桶内的聚合结果。
+type ClientConsumptionBucket struct {
+	Start time.Time `json:"start"`
+	Value float64   `json:"value"`
+	Count int64     `json:"count"`
+}
+
+// AggregateConsumptions 将当前 Client 在 [from, to) 内的能耗记录按 bucket 分桶聚合，fn 为聚合函数名称。
+// 分桶在数据库中完成，利用 recorded_at 索引；桶的起点按服务端所在时区对齐，例如按天聚合时从当地零点开始。
+func (c *Client) AggregateConsumptions(db *gorm.DB, from, to time.Time, bucket time.Duration, fn string) ([]ClientConsumptionBucket, error) {
+	return c.AggregateConsumptionField(db, "consumption", from, to, bucket, fn)
+}
+
+// AggregateConsumptionField 与 AggregateConsumptions 相同，但聚合 field 指定的字段。字段为空的记录不参与聚合。
+func (c *Client) AggregateConsumptionField(db *gorm.DB, field string, from, to time.Time, bucket time.Duration, fn string) ([]ClientConsumptionBucket, error) {
+	column, ok := ClientConsumptionAggregateFields[field]
+	if !ok {
+		return nil, fmt.Errorf("aggregate field not supported: %s", field)
+	}
+	expression, ok := ClientConsumptionAggregateFunctions[fn]
+	if !ok {
+		return nil, fmt.Errorf("aggregate function not supported: %s", fn)
+	}
+	expression = fmt.Sprintf(expression, column)
+	seconds := int64(bucket / time.Second)
+	if seconds <= 0 {
+		return nil, fmt.Errorf("bad bucket: %s", bucket)
+	}
+	_, offset := from.Zone()
+
+	type row struct {
+		Bucket int64
+		Value  float64
+		Count  int64
+	}
+	var rows []row
+	err := db.Model(&ClientConsumption{}).
+		Select(fmt.Sprintf("FLOOR((UNIX_TIMESTAMP(recorded_at) + ?) / ?) * ? - ? AS bucket, %s AS value, COUNT(%s) AS count", expression, column), offset, seconds, seconds, offset).
+		Where("client_id = ? AND recorded_at >= ? AND recorded_at < ?", c.ID, from, to).
+		Where(fmt.Sprintf("%s IS NOT NULL", column)).
+		Group("bucket").
+		Order("bucket").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]ClientConsumptionBucket, 0, len(rows))
+	for _, r := range rows {
+		buckets = append(buckets, ClientConsumptionBucket{
+			Start: time.Unix(r.Bucket, 0),
+			Value: r.Value,
+			Count: r.Count,
+		})
+	}
+	return buckets, nil
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ClientDeferrable 表示可延后运行的客户端（例如热水器、电动汽车充电桩）的用电需求：
+// 自 StartedAt 起，在 Deadline 之前以不超过 MaxPower 瓦的功率消耗 Energy 千瓦时。每个客户端最多一条。
+type ClientDeferrable struct {
+	ClientID  string     `gorm:"column:client_id;primaryKey;size:255"`
+	Energy    float64    `gorm:"column:energy;not null"`    // 所需能量，单位：千瓦时
+	Deadline  time.Time  `gorm:"column:deadline;not null"`  // 截止时间
+	MaxPower  int        `gorm:"column:max_power;not null"` // 最大功率，单位：瓦
+	StartedAt time.Time  `gorm:"column:started_at;not null"`
+	CreatedAt *time.Time `gorm:"column:created_at;autoCreateTime:milli;not null;default:current_timestamp(3)"`
+	UpdatedAt *time.Time `gorm:"column:updated_at;autoUpdateTime:milli;not null;default:current_timestamp(3);onUpdate:default:current_timestamp(3)"`
+}
+
+func (ClientDeferrable) TableName() string {
+	return "client_deferrable"
+}
+
+// GetDeferrable 获取当前客户端的用电需求。不存在时返回 nil。
+func (c *Client) GetDeferrable(db *gorm.DB) *ClientDeferrable {
+	var deferrable ClientDeferrable
+	tx := db.Where("client_id = ?", c.ID).Take(&deferrable)
+	if tx.Error != nil {
+		return nil
+	}
+	return &deferrable
+}
+
+// SetDeferrable 设置当前客户端的用电需求，已有的需求将被替换。所需能量自 startedAt 起计算。
+func (c *Client) SetDeferrable(db *gorm.DB, energy float64, deadline time.Time, maxPower int, startedAt time.Time) (*ClientDeferrable, error) {
+	deferrable := &ClientDeferrable{
+		ClientID:  c.ID,
+		Energy:    energy,
+		Deadline:  deadline,
+		MaxPower:  maxPower,
+		StartedAt: startedAt,
+	}
+	if err := db.Save(deferrable).Error; err != nil {
+		return nil, err
+	}
+	return deferrable, nil
+}
+
+// Delete 删除用电需求。
+func (d *ClientDeferrable) Delete(db *gorm.DB) (int64, error) {
+	tx := db.Delete(d)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
+
+// GetClientDeferrables 获取所有用电需求。
+func GetClientDeferrables(db *gorm.DB) ([]ClientDeferrable, error) {
+	var deferrables []ClientDeferrable
+	tx := db.Order("client_id").Find(&deferrables)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return deferrables, nil
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestClient_SetDeferrable 测试设置、替换及删除可延后负载的用电需求。
+func TestClient_SetDeferrable(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	assert.Nil(t, client.GetDeferrable(db))
+
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	_, err := client.SetDeferrable(db, 5, now.Add(8*time.Hour), 2000, now)
+	assert.Nil(t, err)
+	_, err = client.SetDeferrable(db, 3, now.Add(6*time.Hour), 1000, now)
+	assert.Nil(t, err)
+
+	deferrable := client.GetDeferrable(db)
+	assert.NotNil(t, deferrable)
+	assert.Equal(t, float64(3), deferrable.Energy)
+	assert.Equal(t, 1000, deferrable.MaxPower)
+
+	deferrables, err := GetClientDeferrables(db)
+	assert.Nil(t, err)
+	assert.Len(t, deferrables, 1)
+
+	result, err := deferrable.Delete(db)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+	assert.Nil(t, client.GetDeferrable(db))
+}
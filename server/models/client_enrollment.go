@@ -0,0 +1,155 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrClientEnrollmentInvalid 表示注册码不存在。
+	ErrClientEnrollmentInvalid = errors.New("enrollment code invalid")
+	// ErrClientEnrollmentRedeemed 表示注册码已被使用。
+	ErrClientEnrollmentRedeemed = errors.New("enrollment code redeemed")
+	// ErrClientEnrollmentExpired 表示注册码已过期。
+	ErrClientEnrollmentExpired = errors.New("enrollment code expired")
+)
+
+// ClientEnrollment 表示由管理员创建的一次性客户端注册码。
+// 设备使用注册码换取客户端编号和密钥，之后注册码失效。数据库中仅保存注册码的 SHA256 摘要。
+type ClientEnrollment struct {
+	ID         uint64     `gorm:"column:id;primaryKey"`
+	CodeHash   string     `gorm:"column:code_hash;size:64;not null" json:"-"`
+	Name       string     `gorm:"column:name;size:255;not null"`
+	Type       ClientType `gorm:"column:type;not null"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at;not null"`
+	RedeemedAt *time.Time `gorm:"column:redeemed_at"`
+	ClientID   *string    `gorm:"column:client_id;size:255"`
+	CreatedAt  *time.Time `gorm:"column:created_at;autoCreateTime:milli;not null;default:current_timestamp(3)"`
+}
+
+func (ClientEnrollment) TableName() string {
+	return "client_enrollment"
+}
+
+const clientIDCharacters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// NewClientID 生成新的客户端编号，由32位字母和数字组成。
+func NewClientID() (string, error) {
+	b := make([]byte, 32)
+	max := big.NewInt(int64(len(clientIDCharacters)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = clientIDCharacters[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// CreateNewClientEnrollment 创建新的注册码，并返回注册码。注册码仅在此时可以获得。
+func CreateNewClientEnrollment(db *gorm.DB, name string, clientType ClientType, ttl time.Duration) (string, *ClientEnrollment, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	code := hex.EncodeToString(b)
+	enrollment := &ClientEnrollment{
+		CodeHash:  hashToken(code),
+		Name:      name,
+		Type:      clientType,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := db.Create(enrollment).Error; err != nil {
+		return "", nil, err
+	}
+	return code, enrollment, nil
+}
+
+// GetClientEnrollments 获取注册码列表，按创建时间倒序排列。
+func GetClientEnrollments(db *gorm.DB, page, pageSize int) ([]ClientEnrollment, int64, error) {
+	tx := db.Model(&ClientEnrollment{})
+
+	var total int64
+	err := tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 0
+	}
+	if pageSize > 0 {
+		// 分页
+		offset := (page - 1) * pageSize
+		tx = tx.Limit(pageSize).Offset(offset)
+	}
+
+	// 查询结果
+	var records []ClientEnrollment
+	err = tx.Order("created_at desc").Find(&records).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return records, total, nil
+}
+
+// RedeemClientEnrollment 使用注册码创建新的客户端，并返回该客户端（含密钥）。
+// 注册码只能使用一次，且必须在有效期内使用。
+func RedeemClientEnrollment(db *gorm.DB, code string) (*Client, error) {
+	var client *Client
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var enrollment ClientEnrollment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&enrollment, "code_hash = ?", hashToken(code)).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrClientEnrollmentInvalid
+		}
+		if err != nil {
+			return err
+		}
+		if enrollment.RedeemedAt != nil {
+			return ErrClientEnrollmentRedeemed
+		}
+		if enrollment.ExpiresAt.Before(time.Now()) {
+			return ErrClientEnrollmentExpired
+		}
+
+		id, err := NewClientID()
+		if err != nil {
+			return err
+		}
+		secret, err := NewClientSecret()
+		if err != nil {
+			return err
+		}
+		name := enrollment.Name
+		if len(name) == 0 {
+			name = id
+		}
+		client = NewClient(id, name, enrollment.Type)
+		client.Secret = secret
+		if err := tx.Create(client).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Model(&enrollment).Updates(map[string]interface{}{
+			"redeemed_at": now,
+			"client_id":   id,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRedeemClientEnrollment 测试注册码只能在有效期内使用一次，同时使用同一注册码时只有一次成功。
+func TestRedeemClientEnrollment(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	code, enrollment, err := CreateNewClientEnrollment(db, "test-enrollment", 1, time.Hour)
+	assert.Nil(t, err)
+	assert.NotEqual(t, code, enrollment.CodeHash)
+
+	redeemed, err := RedeemClientEnrollment(db, code)
+	assert.Nil(t, err)
+	assert.Len(t, redeemed.ID, 32)
+	assert.NotEmpty(t, redeemed.Secret)
+	assert.Equal(t, "test-enrollment", redeemed.Name)
+	assert.Equal(t, ClientType(1), redeemed.Type)
+
+	// 注册码只能使用一次。
+	_, err = RedeemClientEnrollment(db, code)
+	assert.ErrorIs(t, err, ErrClientEnrollmentRedeemed)
+	_, err = RedeemClientEnrollment(db, code+"0")
+	assert.ErrorIs(t, err, ErrClientEnrollmentInvalid)
+
+	// 过期的注册码不能使用。
+	expired, expiredEnrollment, err := CreateNewClientEnrollment(db, "", 1, -time.Second)
+	assert.Nil(t, err)
+	_, err = RedeemClientEnrollment(db, expired)
+	assert.ErrorIs(t, err, ErrClientEnrollmentExpired)
+
+	// 同时使用同一注册码时，锁定注册码记录，只有一次成功，只创建一个客户端。
+	concurrent, concurrentEnrollment, err := CreateNewClientEnrollment(db, "", 1, time.Hour)
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	results := make([]*Client, 5)
+	errs := make([]error, len(results))
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = RedeemClientEnrollment(db, concurrent)
+		}(i)
+	}
+	wg.Wait()
+	var succeeded []*Client
+	for i := range results {
+		if errs[i] == nil {
+			succeeded = append(succeeded, results[i])
+		} else {
+			assert.ErrorIs(t, errs[i], ErrClientEnrollmentRedeemed)
+		}
+	}
+	if assert.Len(t, succeeded, 1) {
+		// 未指定名称时以客户端编号为名称。
+		assert.Equal(t, succeeded[0].ID, succeeded[0].Name)
+	}
+
+	assert.Nil(t, db.Delete(&ClientEnrollment{}, []uint64{enrollment.ID, expiredEnrollment.ID, concurrentEnrollment.ID}).Error)
+	for _, c := range append(succeeded, redeemed) {
+		_, err = c.Delete(db)
+		assert.Nil(t, err)
+	}
+}
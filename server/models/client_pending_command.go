@@ -0,0 +1,197 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ClientPendingCommandStatusPending    = iota // 等待客户端连接。
+	ClientPendingCommandStatusDelivered         // 已在客户端连接后下发。
+	ClientPendingCommandStatusExpired           // 客户端在有效期内未连接。
+	ClientPendingCommandStatusCancelled         // 已被用户取消。
+	ClientPendingCommandStatusDelivering        // 已取出，正在下发。下发失败时恢复为等待下发。
+)
+
+// ClientPendingCommand 表示等待离线客户端连接后下发的命令。
+// 客户端连接后，按 ID 顺序下发仍在有效期内的命令。
+type ClientPendingCommand struct {
+	ID                       uint64     `gorm:"column:id;primaryKey;autoIncrement"`
+	ClientID                 string     `gorm:"column:client_id;size:255;not null"`
+	Code                     int        `gorm:"column:code;not null"`
+	Data                     string     `gorm:"column:data;type:text;not null"`
+	Reason                   string     `gorm:"column:reason;size:32;not null;default:''"`
+	Status                   int8       `gorm:"column:status;not null;default:0"`
+	ExpiresAt                *time.Time `gorm:"column:expires_at"` // 为空时不过期。
+	DeliveredAt              *time.Time `gorm:"column:delivered_at"`
+	ClientCommandExecutionID *uint64    `gorm:"column:client_command_execution_id"` // 下发后对应的命令执行历史。
+	CreatedAt                *time.Time `gorm:"column:created_at;autoCreateTime:milli;not null;default:current_timestamp(3)"`
+}
+
+func (ClientPendingCommand) TableName() string {
+	return "client_pending_command"
+}
+
+// ErrPendingCommandNotPending 表示命令已下发、正在下发、已过期或已取消。
+var ErrPendingCommandNotPending = errors.New("command not pending")
+
+// ErrPendingCommandNotDelivering 表示命令不在下发中。
+var ErrPendingCommandNotDelivering = errors.New("command not delivering")
+
+// NewClientPendingCommand 实例化一条等待下发的命令。expiresAt 为空时不过期。
+func NewClientPendingCommand(clientID string, code int, data string, reason string, expiresAt *time.Time) *ClientPendingCommand {
+	return &ClientPendingCommand{
+		ClientID:  clientID,
+		Code:      code,
+		Data:      data,
+		Reason:    reason,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// EnqueueClientPendingCommand 将命令加入等待下发的队列。
+func EnqueueClientPendingCommand(db *gorm.DB, command *ClientPendingCommand) (int64, error) {
+	if command == nil {
+		return 0, gorm.ErrRecordNotFound
+	}
+	tx := db.Create(command)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
+
+func GetClientPendingCommand(db *gorm.DB, id uint64) *ClientPendingCommand {
+	var command ClientPendingCommand
+	tx := db.Take(&command, id)
+	if tx.Error != nil {
+		return nil
+	}
+	return &command
+}
+
+// GetPendingCommands 返回当前客户端的队列，包括已下发、已过期和已取消的命令，按加入队列的顺序排列。
+func (c *Client) GetPendingCommands(db *gorm.DB, page, pageSize int) ([]ClientPendingCommand, int64, error) {
+	tx := db.Model(&ClientPendingCommand{}).Where("client_id = ?", c.ID)
+
+	var total int64
+	err := tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize > 0 {
+		// 分页
+		offset := (page - 1) * pageSize
+		tx = tx.Limit(pageSize).Offset(offset)
+	}
+
+	var records []ClientPendingCommand
+	err = tx.Order("id").Find(&records).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return records, total, nil
+}
+
+// GetDuePendingCommands 返回当前客户端所有等待下发的命令，按加入队列的顺序排列。其中可能包含已过期但尚未标记的命令。
+func (c *Client) GetDuePendingCommands(db *gorm.DB) ([]ClientPendingCommand, error) {
+	var records []ClientPendingCommand
+	tx := db.Where("client_id = ? and status = ?", c.ID, ClientPendingCommandStatusPending).Order("id").Find(&records)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return records, nil
+}
+
+// CancelPendingCommands 取消当前客户端所有等待下发的命令。
+func (c *Client) CancelPendingCommands(db *gorm.DB) (int64, error) {
+	tx := db.Model(&ClientPendingCommand{}).Where("client_id = ? and status = ?", c.ID, ClientPendingCommandStatusPending).
+		Update("status", ClientPendingCommandStatusCancelled)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
+
+// ExpireClientPendingCommands 将所有在 now 之前过期、仍在等待下发的命令标记为已过期。
+func ExpireClientPendingCommands(db *gorm.DB, now time.Time) (int64, error) {
+	tx := db.Model(&ClientPendingCommand{}).Where("status = ? and expires_at <= ?", ClientPendingCommandStatusPending, now).
+		Update("status", ClientPendingCommandStatusExpired)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
+
+// IsExpired 表示命令在 now 时是否已过期。
+func (p *ClientPendingCommand) IsExpired(now time.Time) bool {
+	return p.ExpiresAt != nil && !p.ExpiresAt.After(now)
+}
+
+// updateStatus 将状态为 from 的命令更新为 status。状态已不是 from 时返回 ErrPendingCommandNotPending 或 ErrPendingCommandNotDelivering。
+// 以条件更新保证并发时只有一方成功。
+func (p *ClientPendingCommand) updateStatus(db *gorm.DB, from int8, status int8, values map[string]any) (int64, error) {
+	values["status"] = status
+	tx := db.Model(p).Where("status = ?", from).Updates(values)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		if from == ClientPendingCommandStatusDelivering {
+			return 0, ErrPendingCommandNotDelivering
+		}
+		return 0, ErrPendingCommandNotPending
+	}
+	p.Status = status
+	return tx.RowsAffected, nil
+}
+
+// Claim 将等待下发的命令标记为正在下发。只有标记成功时才能下发，以免同一命令被重复下发，或在下发时被取消。
+func (p *ClientPendingCommand) Claim(db *gorm.DB) (int64, error) {
+	return p.updateStatus(db, ClientPendingCommandStatusPending, ClientPendingCommandStatusDelivering, map[string]any{})
+}
+
+// Release 将正在下发的命令恢复为等待下发，例如下发失败时，留待客户端下次连接时下发。
+func (p *ClientPendingCommand) Release(db *gorm.DB) (int64, error) {
+	return p.updateStatus(db, ClientPendingCommandStatusDelivering, ClientPendingCommandStatusPending, map[string]any{})
+}
+
+// ReleaseClientPendingCommands 将所有正在下发的命令恢复为等待下发。服务端启动时调用，恢复上次运行时下发中断的命令。
+func ReleaseClientPendingCommands(db *gorm.DB) (int64, error) {
+	tx := db.Model(&ClientPendingCommand{}).Where("status = ?", ClientPendingCommandStatusDelivering).
+		Update("status", ClientPendingCommandStatusPending)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
+
+// MarkDelivered 将正在下发的命令标记为已下发，并关联下发时的命令执行历史。
+func (p *ClientPendingCommand) MarkDelivered(db *gorm.DB, execution *ClientCommandExecution, deliveredAt time.Time) (int64, error) {
+	result, err := p.updateStatus(db, ClientPendingCommandStatusDelivering, ClientPendingCommandStatusDelivered, map[string]any{
+		"delivered_at":                deliveredAt,
+		"client_command_execution_id": execution.ID,
+	})
+	if err != nil {
+		return result, err
+	}
+	p.DeliveredAt = &deliveredAt
+	p.ClientCommandExecutionID = &execution.ID
+	return result, nil
+}
+
+// MarkExpired 将命令标记为已过期。
+func (p *ClientPendingCommand) MarkExpired(db *gorm.DB) (int64, error) {
+	return p.updateStatus(db, ClientPendingCommandStatusPending, ClientPendingCommandStatusExpired, map[string]any{})
+}
+
+// Cancel 取消等待下发的命令。
+func (p *ClientPendingCommand) Cancel(db *gorm.DB) (int64, error) {
+	return p.updateStatus(db, ClientPendingCommandStatusPending, ClientPendingCommandStatusCancelled, map[string]any{})
+}
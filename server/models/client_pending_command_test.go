@@ -0,0 +1,96 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestClient_PendingCommands 测试离线命令队列：按顺序取出、标记已下发、过期及取消。
+func TestClient_PendingCommands(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	now := time.Now()
+	expired := now.Add(-time.Minute)
+	commands := []*ClientPendingCommand{
+		NewClientPendingCommand(client.ID, 2, "{\"power\":100}", ClientCommandExecutionReasonManual, nil),
+		NewClientPendingCommand(client.ID, 2, "{\"power\":200}", ClientCommandExecutionReasonManual, &expired),
+		NewClientPendingCommand(client.ID, 2, "{\"power\":300}", ClientCommandExecutionReasonManual, nil),
+	}
+	for _, command := range commands {
+		result, err := EnqueueClientPendingCommand(db, command)
+		assert.Equal(t, int64(1), result)
+		assert.Nil(t, err)
+	}
+
+	due, err := client.GetDuePendingCommands(db)
+	assert.Nil(t, err)
+	assert.Len(t, due, 3)
+	assert.Equal(t, commands[0].ID, due[0].ID)
+	assert.False(t, due[0].IsExpired(now))
+	assert.True(t, due[1].IsExpired(now))
+
+	execution := NewClientCommandExecution(client.ID, 2, due[0].Data, now, due[0].Reason)
+	assert.Nil(t, db.Create(execution).Error)
+	// 未取出的命令不能标记为已下发。
+	_, err = due[0].MarkDelivered(db, execution, now)
+	assert.ErrorIs(t, err, ErrPendingCommandNotDelivering)
+	result, err := due[0].Claim(db)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+	// 只有一方能取出命令，正在下发的命令不能取消。
+	_, err = GetClientPendingCommand(db, due[0].ID).Claim(db)
+	assert.ErrorIs(t, err, ErrPendingCommandNotPending)
+	_, err = due[0].Cancel(db)
+	assert.ErrorIs(t, err, ErrPendingCommandNotPending)
+	_, err = due[0].MarkDelivered(db, execution, now)
+	assert.Nil(t, err)
+
+	result, err = ExpireClientPendingCommands(db, now)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+
+	result, err = client.CancelPendingCommands(db)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+
+	records, count, err := client.GetPendingCommands(db, 1, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.Equal(t, int8(ClientPendingCommandStatusDelivered), records[0].Status)
+	assert.Equal(t, execution.ID, *records[0].ClientCommandExecutionID)
+	assert.Equal(t, int8(ClientPendingCommandStatusExpired), records[1].Status)
+	assert.Equal(t, int8(ClientPendingCommandStatusCancelled), records[2].Status)
+}
+
+// TestClientPendingCommand_Release 测试下发失败或中断时恢复为等待下发。
+func TestClientPendingCommand_Release(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	commands := []*ClientPendingCommand{
+		NewClientPendingCommand(client.ID, 2, "{\"power\":100}", ClientCommandExecutionReasonManual, nil),
+		NewClientPendingCommand(client.ID, 2, "{\"power\":200}", ClientCommandExecutionReasonManual, nil),
+	}
+	for _, command := range commands {
+		_, err := EnqueueClientPendingCommand(db, command)
+		assert.Nil(t, err)
+		_, err = command.Claim(db)
+		assert.Nil(t, err)
+	}
+
+	result, err := commands[0].Release(db)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+	_, err = commands[0].Release(db)
+	assert.ErrorIs(t, err, ErrPendingCommandNotDelivering)
+
+	result, err = ReleaseClientPendingCommands(db)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+	due, err := client.GetDuePendingCommands(db)
+	assert.Nil(t, err)
+	assert.Len(t, due, 2)
+}
@@ -14,6 +14,8 @@ type ClientPreparedCommand struct {
 	Data        string     `gorm:"column:data;not null"`
 	CreatedAt   *time.Time `gorm:"column:created_at;autoCreateTime:milli;not null;default:current_timestamp(3)"`
 	UpdatedAt   *time.Time `gorm:"column:updated_at;autoUpdateTime:milli;not null;default:current_timestamp(3);onUpdate:default:current_timestamp(3)"`
+
+	Client *Client `gorm:"foreignKey:ClientID"`
 }
 
 func (ClientPreparedCommand) TableName() string {
@@ -3,6 +3,7 @@ package models
 import (
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/mysql"
@@ -39,10 +40,20 @@ func TestMain(m *testing.M) {
 	dbPrepared.Do(prepareDatabase)
 	db.Begin()
 	db.Exec("DELETE FROM `power_mode_client_prepared_command`")
+	db.Exec("DELETE FROM `client_pending_command`")
+	db.Exec("DELETE FROM `client_battery_state`")
+	db.Exec("DELETE FROM `client_deferrable`")
+	db.Exec("DELETE FROM `tariff_version`")
+	db.Exec("DELETE FROM `tariff`")
+	db.Exec("DELETE FROM `user_session`")
+	db.Exec("DELETE FROM `user`")
+	db.Exec("DELETE FROM `power_mode_schedule`")
+	db.Exec("DELETE FROM `power_mode_execution_client`")
 	db.Exec("DELETE FROM `power_mode_execution`")
 	db.Exec("DELETE FROM `power_mode`")
 	db.Exec("DELETE FROM `client_prepared_command`")
 	db.Exec("DELETE FROM `client_command_execution`")
+	db.Exec("DELETE FROM `client_enrollment`")
 	db.Exec("DELETE FROM `client_activity`")
 	db.Exec("DELETE FROM `client`")
 	clientPrepared.Do(prepareClient)
@@ -108,6 +119,19 @@ func TestUpdateClient(t *testing.T) {
 	assert.Equal(t, "Newly Updated Client Name", client2.Name)
 }
 
+// TestClient_UpdatePriority 测试更新客户端优先级。
+func TestClient_UpdatePriority(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	_, err := client.UpdatePriority(db, 5)
+	assert.Nil(t, err)
+
+	priorities, err := GetClientPriorities(db, []string{client.ID, "not-existed"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int32{client.ID: 5}, priorities)
+}
+
 func TestClient_GetActivities(t *testing.T) {
 	setUpAll(t)
 	defer tearDown(t)
@@ -120,7 +144,111 @@ func TestClient_GetActivities(t *testing.T) {
 func TestClient_GetCommandExecutions(t *testing.T) {
 	setUpAll(t)
 	defer tearDown(t)
-	count, err := client.InsertNewCommandExecution(db, 1, "0", nil)
+	count, err := client.InsertNewCommandExecution(db, 1, "0", nil, ClientCommandExecutionReasonManual)
 	assert.Equal(t, int64(1), count)
 	assert.Nil(t, err)
 }
+
+// TestClient_InsertNewConsumptions 测试在一个事务中插入多条能耗记录。
+func TestClient_InsertNewConsumptions(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	from := time.Date(2024, 3, 2, 0, 0, 0, 0, time.Local)
+	consumptions := []ClientConsumption{
+		{Consumption: 10, RecordedAt: from},
+		{Consumption: 20, RecordedAt: from.Add(time.Second)},
+		{Consumption: 30, RecordedAt: from.Add(2 * time.Second)},
+	}
+	count, err := client.InsertNewConsumptions(db, consumptions)
+	assert.Equal(t, int64(3), count)
+	assert.Nil(t, err)
+
+	records, err := client.GetConsumptionsBetween(db, from, from.Add(time.Minute))
+	assert.Nil(t, err)
+	assert.Len(t, records, 3)
+
+	// 重复补报的记录被忽略。
+	count, err = client.InsertNewConsumptions(db, []ClientConsumption{
+		{Consumption: 30, RecordedAt: from.Add(2 * time.Second)},
+		{Consumption: 40, RecordedAt: from.Add(3 * time.Second)},
+	})
+	assert.Equal(t, int64(1), count)
+	assert.Nil(t, err)
+	count, err = client.InsertNewConsumption(db, 10, nil, from)
+	assert.Equal(t, int64(0), count)
+	assert.Nil(t, err)
+	records, err = client.GetConsumptionsBetween(db, from, from.Add(time.Minute))
+	assert.Nil(t, err)
+	assert.Len(t, records, 4)
+
+	count, err = client.InsertNewConsumptions(db, nil)
+	assert.Equal(t, int64(0), count)
+	assert.Nil(t, err)
+}
+
+// TestClient_AggregateConsumptions 测试按时间分桶聚合能耗记录。
+func TestClient_AggregateConsumptions(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	for i, consumption := range []float32{10, 20, 30, 40} {
+		_, err := client.InsertNewConsumption(db, consumption, nil, from.Add(time.Duration(i)*30*time.Minute))
+		assert.Nil(t, err)
+	}
+
+	buckets, err := client.AggregateConsumptions(db, from, from.Add(2*time.Hour), time.Hour, "avg")
+	assert.Nil(t, err)
+	assert.Len(t, buckets, 2)
+	assert.Equal(t, from, buckets[0].Start)
+	assert.Equal(t, float64(15), buckets[0].Value)
+	assert.Equal(t, int64(2), buckets[0].Count)
+	assert.Equal(t, float64(35), buckets[1].Value)
+
+	_, err = client.AggregateConsumptions(db, from, from.Add(2*time.Hour), time.Hour, "median")
+	assert.NotNil(t, err)
+}
+
+// TestClient_InsertNewConsumption_Generation 测试保存发电客户端报告的负功率。
+func TestClient_InsertNewConsumption_Generation(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	from := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	_, err := client.InsertNewConsumption(db, -1500, nil, from)
+	assert.Nil(t, err)
+
+	buckets, err := client.AggregateConsumptions(db, from, from.Add(time.Hour), time.Hour, "sum")
+	assert.Nil(t, err)
+	assert.Len(t, buckets, 1)
+	assert.Equal(t, float64(-1500), buckets[0].Value)
+}
+
+// TestClient_AggregateConsumptionField 测试按时间分桶聚合室内温度。未报告温度的记录不参与聚合。
+func TestClient_AggregateConsumptionField(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	temperatures := []float32{20, 22}
+	_, err := client.InsertNewConsumption(db, 1000, &temperatures[0], from)
+	assert.Nil(t, err)
+	_, err = client.InsertNewConsumption(db, 1000, &temperatures[1], from.Add(10*time.Minute))
+	assert.Nil(t, err)
+	_, err = client.InsertNewConsumption(db, 1000, nil, from.Add(20*time.Minute))
+	assert.Nil(t, err)
+
+	buckets, err := client.AggregateConsumptionField(db, "temperature", from, from.Add(time.Hour), time.Hour, "avg")
+	assert.Nil(t, err)
+	assert.Len(t, buckets, 1)
+	assert.Equal(t, float64(21), buckets[0].Value)
+	assert.Equal(t, int64(2), buckets[0].Count)
+
+	buckets, err = client.AggregateConsumptionField(db, "consumption", from, from.Add(time.Hour), time.Hour, "count")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), buckets[0].Count)
+
+	_, err = client.AggregateConsumptionField(db, "client_id", from, from.Add(time.Hour), time.Hour, "avg")
+	assert.NotNil(t, err)
+}
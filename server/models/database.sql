@@ -6,6 +6,8 @@ create table client
         primary key,
     name       varchar(255)                              not null comment '名称',
     type       int                                       not null comment '客户端类型',
+    secret     varchar(64)  default ''                   not null comment '客户端密钥，用于请求签名',
+    priority   int          default 0                    not null comment '优先级。超出功率上限时，优先级低的客户端先被切除',
     created_at timestamp(3) default CURRENT_TIMESTAMP(3) not null comment '加入时间',
     updated_at timestamp(3) default CURRENT_TIMESTAMP(3) not null on update CURRENT_TIMESTAMP(3) comment '上次更新时间'
 )
@@ -31,11 +33,17 @@ create table client_command_execution
 (
     id         bigint auto_increment comment '编号'
         primary key,
-    client_id  varchar(255)                              not null comment '客户端ID',
-    code       int                                       not null comment '命令代码',
-    data       text                                      not null comment '命令内容',
-    sent_at    timestamp(3)                              not null comment '发送时间',
-    created_at timestamp(3) default CURRENT_TIMESTAMP(3) not null comment '创建时间',
+    client_id     varchar(255)                              not null comment '客户端ID',
+    command_id    varchar(32)  default ''                   not null comment '命令ID。随命令下发，客户端确认时回传',
+    code          int                                       not null comment '命令代码',
+    data          text                                      not null comment '命令内容',
+    reason        varchar(32)  default ''                   not null comment '发送原因：manual手动，power_mode能耗模式，load_shedding切除负载，load_restore恢复负载，deferrable可延后负载计划',
+    status        tinyint      default 0                    not null comment '状态：0已发送，1已确认，2未确认（重试次数用尽），3已被同类新命令取代',
+    retries       int          default 0                    not null comment '重新发送次数',
+    sent_at       timestamp(3)                              not null comment '发送时间',
+    acked_at      timestamp(3)                              null comment '确认时间',
+    applied_value varchar(255)                              null comment '客户端确认时报告的实际生效值',
+    created_at    timestamp(3) default CURRENT_TIMESTAMP(3) not null comment '创建时间',
     constraint client_command_execution_client_id_fk
         foreign key (client_id) references client (id)
             on update cascade on delete cascade
@@ -45,12 +53,16 @@ create table client_command_execution
 create index client_command_execution_code_index
     on client_command_execution (code);
 
+create index client_command_execution_command_id_index
+    on client_command_execution (command_id);
+
 create table client_consumption
 (
     id          bigint auto_increment comment '编号'
         primary key,
     client_id   varchar(255)                                not null comment '客户端编号',
-    consumption float unsigned default '0'                  not null comment '功耗',
+    consumption float          default '0'                  not null comment '功率（瓦）。为负数时表示发电，例如光伏',
+    temperature float                                       null comment '室内温度（摄氏度）。未报告温度的客户端为空',
     recorded_at timestamp(3)                                not null comment '记录功耗时间',
     created_at  timestamp(3)   default CURRENT_TIMESTAMP(3) not null on update CURRENT_TIMESTAMP(3) comment '保存时间',
     constraint client_consumption_client_id_fk
@@ -59,8 +71,8 @@ create table client_consumption
 )
     comment '客户端功耗记录';
 
-create index client_consumption_client_id_index
-    on client_consumption (client_id);
+create unique index client_consumption_client_id_recorded_at_uindex
+    on client_consumption (client_id, recorded_at);
 
 create index client_consumption_recorded_at_index
     on client_consumption (recorded_at);
@@ -86,6 +98,7 @@ create table client_prepared_command
     code          int                                       not null comment '命令代码',
     data          text                                      not null comment '命令内容',
     created_at    timestamp(3) default CURRENT_TIMESTAMP(3) null comment '创建时间',
+    updated_at    timestamp(3) default CURRENT_TIMESTAMP(3) not null on update CURRENT_TIMESTAMP(3) comment '最后更新时间',
     constraint client_prepared_command_pk
         unique (power_mode_id, client_id),
     constraint client_prepared_command_client_id_fk
@@ -105,6 +118,7 @@ create table power_mode_execution
     id            bigint auto_increment comment '编号'
         primary key,
     power_mode_id bigint                                    not null comment '能耗模式编号',
+    `trigger`     varchar(32)  default 'manual'             not null comment '触发来源：manual手动，schedule执行计划',
     created_at    timestamp(3) default CURRENT_TIMESTAMP(3) not null comment '创建时间',
     constraint power_mode_execution_power_mode_id_fk
         foreign key (power_mode_id) references power_mode (id)
@@ -112,4 +126,178 @@ create table power_mode_execution
 )
     comment '能耗模式执行历史';
 
+create table power_mode_execution_client
+(
+    id                          bigint auto_increment comment '编号'
+        primary key,
+    power_mode_execution_id     bigint                                    not null comment '能耗模式执行编号',
+    client_id                   varchar(255)                              not null comment '客户端编号',
+    client_command_execution_id bigint                                    null comment '客户端命令执行编号。仅送达时有值',
+    status                      tinyint                                   not null comment '执行结果：0送达，1离线跳过，2拒绝，3超时',
+    created_at                  timestamp(3) default CURRENT_TIMESTAMP(3) not null comment '创建时间',
+    constraint power_mode_execution_client_execution_id_fk
+        foreign key (power_mode_execution_id) references power_mode_execution (id)
+            on update cascade on delete cascade,
+    constraint power_mode_execution_client_client_id_fk
+        foreign key (client_id) references client (id)
+            on update cascade on delete cascade,
+    constraint power_mode_execution_client_command_execution_id_fk
+        foreign key (client_command_execution_id) references client_command_execution (id)
+            on update cascade on delete set null
+)
+    comment '能耗模式执行中每个客户端的执行结果';
+
+create table power_mode_schedule
+(
+    id              bigint auto_increment comment '编号'
+        primary key,
+    power_mode_id   bigint                                    not null comment '能耗模式编号',
+    cron_expression varchar(255)                              not null comment 'cron 表达式',
+    time_zone       varchar(64)                               not null comment '时区',
+    enabled         tinyint(1)   default 1                    not null comment '是否启用',
+    last_fired_at   timestamp(3)                              null comment '上次触发时间',
+    created_at      timestamp(3) default CURRENT_TIMESTAMP(3) not null comment '创建时间',
+    updated_at      timestamp(3) default CURRENT_TIMESTAMP(3) not null on update CURRENT_TIMESTAMP(3) comment '最后更新时间',
+    constraint power_mode_schedule_power_mode_id_fk
+        foreign key (power_mode_id) references power_mode (id)
+            on update cascade
+)
+    comment '能耗模式执行计划';
+
+create table user
+(
+    id            bigint auto_increment comment '编号'
+        primary key,
+    username      varchar(255)                              not null comment '用户名',
+    password_hash varchar(255)                              not null comment '密码散列',
+    role          tinyint                                   not null comment '角色：1只读，2操作员，3管理员',
+    created_at    timestamp(3) default CURRENT_TIMESTAMP(3) not null comment '创建时间',
+    updated_at    timestamp(3) default CURRENT_TIMESTAMP(3) not null on update CURRENT_TIMESTAMP(3) comment '最后更新时间',
+    constraint user_pk
+        unique (username)
+)
+    comment '用户';
+
+create table user_session
+(
+    id         bigint auto_increment comment '编号'
+        primary key,
+    token_hash varchar(64)                               not null comment '令牌 SHA256 摘要',
+    user_id    bigint                                    not null comment '用户编号',
+    expires_at timestamp(3)                              not null comment '过期时间',
+    created_at timestamp(3) default CURRENT_TIMESTAMP(3) not null comment '创建时间',
+    constraint user_session_pk
+        unique (token_hash),
+    constraint user_session_user_id_fk
+        foreign key (user_id) references user (id)
+            on update cascade on delete cascade
+)
+    comment '用户会话';
+
+create table client_enrollment
+(
+    id          bigint auto_increment comment '编号'
+        primary key,
+    code_hash   varchar(64)                               not null comment '注册码 SHA256 摘要',
+    name        varchar(255)                              not null comment '客户端名称',
+    type        int                                       not null comment '客户端类型',
+    expires_at  timestamp(3)                              not null comment '过期时间',
+    redeemed_at timestamp(3)                              null comment '使用时间',
+    client_id   varchar(255)                              null comment '使用后创建的客户端编号',
+    created_at  timestamp(3) default CURRENT_TIMESTAMP(3) not null comment '创建时间',
+    constraint client_enrollment_pk
+        unique (code_hash),
+    constraint client_enrollment_client_id_fk
+        foreign key (client_id) references client (id)
+            on update cascade on delete set null
+)
+    comment '客户端注册码';
+
+create table tariff
+(
+    id         bigint auto_increment comment '编号'
+        primary key,
+    name       varchar(255)                              not null comment '名称',
+    currency   varchar(8)                                not null comment '货币代码，例如 CNY',
+    is_default tinyint(1)   default 0                    not null comment '是否为默认电价',
+    created_at timestamp(3) default CURRENT_TIMESTAMP(3) not null comment '创建时间',
+    updated_at timestamp(3) default CURRENT_TIMESTAMP(3) not null on update CURRENT_TIMESTAMP(3) comment '最后更新时间',
+    constraint tariff_pk
+        unique (name)
+)
+    comment '电价方案';
+
+create table tariff_version
+(
+    id             bigint auto_increment comment '编号'
+        primary key,
+    tariff_id      bigint                                    not null comment '电价方案编号',
+    effective_from timestamp(3)                              not null comment '生效时间',
+    time_zone      varchar(64)                               not null comment '时区。时段按该时区的本地时间划分',
+    definition     text                                      not null comment '季节与时段定义（JSON）',
+    created_at     timestamp(3) default CURRENT_TIMESTAMP(3) not null comment '创建时间',
+    constraint tariff_version_pk
+        unique (tariff_id, effective_from),
+    constraint tariff_version_tariff_id_fk
+        foreign key (tariff_id) references tariff (id)
+            on update cascade on delete cascade
+)
+    comment '电价方案版本';
+
+create table client_deferrable
+(
+    client_id  varchar(255)                              not null comment '客户端编号'
+        primary key,
+    energy     double                                    not null comment '所需能量，单位：千瓦时',
+    deadline   timestamp(3)                              not null comment '截止时间',
+    max_power  int                                       not null comment '最大功率，单位：瓦',
+    started_at timestamp(3)                              not null comment '开始计算所需能量的时间',
+    created_at timestamp(3) default CURRENT_TIMESTAMP(3) not null comment '创建时间',
+    updated_at timestamp(3) default CURRENT_TIMESTAMP(3) not null on update CURRENT_TIMESTAMP(3) comment '最后更新时间',
+    constraint client_deferrable_client_id_fk
+        foreign key (client_id) references client (id)
+            on update cascade on delete cascade
+)
+    comment '可延后负载的用电需求';
+
+create table client_pending_command
+(
+    id                          bigint auto_increment comment '编号'
+        primary key,
+    client_id                   varchar(255)                              not null comment '客户端编号',
+    code                        int                                       not null comment '命令代码',
+    data                        text                                      not null comment '命令内容',
+    reason                      varchar(32)  default ''                   not null comment '发送原因，同 client_command_execution.reason',
+    status                      tinyint      default 0                    not null comment '状态：0等待下发，1已下发，2已过期，3已取消，4正在下发',
+    expires_at                  timestamp(3)                              null comment '过期时间。为空时不过期',
+    delivered_at                timestamp(3)                              null comment '下发时间',
+    client_command_execution_id bigint                                    null comment '下发后对应的客户端命令执行编号',
+    created_at                  timestamp(3) default CURRENT_TIMESTAMP(3) not null comment '创建时间',
+    constraint client_pending_command_client_id_fk
+        foreign key (client_id) references client (id)
+            on update cascade on delete cascade,
+    constraint client_pending_command_command_execution_id_fk
+        foreign key (client_command_execution_id) references client_command_execution (id)
+            on update cascade on delete set null
+)
+    comment '等待离线客户端连接后下发的命令';
+
+create index client_pending_command_client_id_status_index
+    on client_pending_command (client_id, status);
+
+create table client_battery_state
+(
+    id          bigint auto_increment comment '编号'
+        primary key,
+    client_id   varchar(255)                              not null comment '客户端编号',
+    soc         float                                     not null comment '荷电状态（百分比）',
+    recorded_at timestamp(3)                              not null comment '记录时间',
+    created_at  timestamp(3) default CURRENT_TIMESTAMP(3) not null comment '保存时间',
+    constraint client_battery_state_client_id_fk
+        foreign key (client_id) references client (id)
+            on update cascade on delete cascade
+)
+    comment '储能客户端的荷电状态记录';
 
+create unique index client_battery_state_client_id_recorded_at_uindex
+    on client_battery_state (client_id, recorded_at);
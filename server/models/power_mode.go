@@ -1,6 +1,8 @@
 package models
 
 import (
+	"errors"
+	"log"
 	"time"
 
 	"gorm.io/gorm"
@@ -12,7 +14,7 @@ type PowerMode struct {
 	CreatedAt *time.Time `gorm:"column:created_at;autoCreateTime:milli;not null;default:current_timestamp(3)"`
 	UpdatedAt *time.Time `gorm:"column:updated_at;autoUpdateTime:milli;not null;default:current_timestamp(3);onUpdate:default:current_timestamp(3)"`
 
-	ClientPreparedCommands []*ClientPreparedCommand `gorm:"many2many:power_mode_client_prepared_command"`
+	ClientPreparedCommands []*ClientPreparedCommand `gorm:"foreignKey:PowerModeID" json:"-"`
 }
 
 func (*PowerMode) TableName() string {
@@ -94,6 +96,49 @@ func (m *PowerMode) UpdateName(db *gorm.DB, name string) (int64, error) {
 	return tx.RowsAffected, nil
 }
 
+// ErrPowerModeExecuted 表示能耗模式已有执行记录，不能删除。
+var ErrPowerModeExecuted = errors.New("power mode has been executed")
+
+// CountExecutions 返回当前能耗模式的执行记录数。
+func (m *PowerMode) CountExecutions(db *gorm.DB) (int64, error) {
+	var count int64
+	err := db.Model(&PowerModeExecution{}).Where("power_mode_id = ?", m.ID).Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Delete 删除自身及其预制命令、执行计划。若已存在执行记录，则返回 ErrPowerModeExecuted。
+func (m *PowerMode) Delete(db *gorm.DB) (int64, error) {
+	var rows int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		count, err := m.CountExecutions(tx)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrPowerModeExecuted
+		}
+		if err := tx.Where("power_mode_id = ?", m.ID).Delete(&ClientPreparedCommand{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("power_mode_id = ?", m.ID).Delete(&PowerModeSchedule{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(m)
+		if result.Error != nil {
+			return result.Error
+		}
+		rows = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rows, nil
+}
+
 func RemovePowerMode(db *gorm.DB, mode *PowerMode) (int64, error) {
 	if mode == nil {
 		return 0, gorm.ErrRecordNotFound
@@ -105,46 +150,111 @@ func RemovePowerMode(db *gorm.DB, mode *PowerMode) (int64, error) {
 	return tx.RowsAffected, nil
 }
 
-// Execute 执行能耗模式。TODO:
+var (
+	// ErrClientOffline 表示客户端未连接。
+	ErrClientOffline = errors.New("client offline")
+	// ErrCommandTimeout 表示客户端未在规定时间内接收命令。
+	ErrCommandTimeout = errors.New("command timeout")
+)
+
+// CommandDispatcher 表示可以向客户端下发命令的对象，例如 common.SessionManager。
+type CommandDispatcher interface {
+	// DispatchCommand 向指定客户端发送命令，并以 reason 为原因记录命令执行历史。返回该记录，客户端确认后更新其状态。
+	// 客户端未连接时返回 ErrClientOffline，客户端未及时接收时返回 ErrCommandTimeout，命令不合法时返回其它错误。
+	DispatchCommand(clientID string, code int, data string, reason string) (*ClientCommandExecution, error)
+}
+
+// dispatchStatus 将 CommandDispatcher 返回的错误转换为客户端执行结果。
+func dispatchStatus(err error) int8 {
+	switch {
+	case err == nil:
+		return PowerModeExecutionClientStatusDelivered
+	case errors.Is(err, ErrClientOffline):
+		return PowerModeExecutionClientStatusSkippedOffline
+	case errors.Is(err, ErrCommandTimeout):
+		return PowerModeExecutionClientStatusTimedOut
+	default:
+		return PowerModeExecutionClientStatusRejected
+	}
+}
+
+// Execute 执行能耗模式。
 // 1. 获取当前能耗模式的预制命令列表。
 // 2. 分别执行每个命令；如果客户端不存在，则跳过。
-// 3. 记录成功执行的命令。并返回执行的总数。
-func (m *PowerMode) Execute(db *gorm.DB) (int64, error) {
-
-	return 0, nil
-}
-
-//
-//func (m *PowerMode) AddCommand(db *gorm.DB, command *ClientPreparedCommand) (int64, error) {
-//	record := NewPowerModeClientPreparedCommand(db, m, command)
-//	if record == nil {
-//		return 0, gorm.ErrRecordNotFound
-//	}
-//
-//	tx := db.Save(record)
-//	if tx.Error != nil {
-//		return 0, tx.Error
-//	}
-//	return tx.RowsAffected, nil
-//}
-//
-//func (m *PowerMode) RemoveCommand(db *gorm.DB, command *ClientPreparedCommand) (int64, error) {
-//	if command == nil {
-//		return 0, gorm.ErrRecordNotFound
-//	}
-//	tx := db.Where("command_id = ?", command.ID).Where("power_mode_id = ?", m.ID).Delete(&PowerModeClientPreparedCommand{})
-//	if tx.Error != nil {
-//		return 0, tx.Error
-//	}
-//	return tx.RowsAffected, nil
-//}
-//
-//func (m *PowerMode) GetCommands(db *gorm.DB) ([]ClientPreparedCommand, int64, error) {
-//	var commands []ClientPreparedCommand
-//	var relations []PowerModeClientPreparedCommand
-//	tx := db.Find(&relations, "power_mode_id = ?", m.ID)
-//	if tx.Error != nil {
-//		return commands, 0, tx.Error
-//	}
-//	return commands, tx.RowsAffected, nil
-//}
+// 3. 记录本次执行、成功执行的命令及每个客户端的执行结果。并返回成功执行和未成功执行的总数。
+// trigger 表示触发来源，例如 PowerModeExecutionTriggerManual。
+func (m *PowerMode) Execute(db *gorm.DB, dispatcher CommandDispatcher, trigger string) (int64, int64, error) {
+	var commands []ClientPreparedCommand
+	if err := db.Where("power_mode_id = ?", m.ID).Order("id").Find(&commands).Error; err != nil {
+		return 0, 0, err
+	}
+
+	execution := NewPowerModeExecution(db, m)
+	execution.Trigger = trigger
+	if err := db.Create(execution).Error; err != nil {
+		return 0, 0, err
+	}
+
+	var delivered, skipped int64
+	for _, command := range commands {
+		commandExecution, err := dispatcher.DispatchCommand(command.ClientID, command.Code, command.Data, ClientCommandExecutionReasonPowerMode)
+		status := dispatchStatus(err)
+		if err == nil {
+			delivered++
+		} else {
+			log.Printf("Power mode[%d] skipped client[%s]: %s", m.ID, command.ClientID, err.Error())
+			skipped++
+		}
+		if _, err := CreateNewPowerModeExecutionClient(db, execution, command.ClientID, commandExecution, status); err != nil {
+			return delivered, skipped, err
+		}
+	}
+	return delivered, skipped, nil
+}
+
+// ErrPowerModeCommandDuplicated 表示能耗模式中已存在该客户端的命令。每个能耗模式中每个客户端仅能有一条命令。
+var ErrPowerModeCommandDuplicated = errors.New("power mode command of client duplicated")
+
+// AddCommand 向当前能耗模式添加预制命令。如果该客户端已有命令，则返回 ErrPowerModeCommandDuplicated。
+func (m *PowerMode) AddCommand(db *gorm.DB, command *ClientPreparedCommand) (int64, error) {
+	if command == nil {
+		return 0, gorm.ErrRecordNotFound
+	}
+	if m.GetCommand(db, command.ClientID) != nil {
+		return 0, ErrPowerModeCommandDuplicated
+	}
+	command.PowerModeID = m.ID
+	return CreateNewClientPreparedCommand(db, command)
+}
+
+// RemoveCommand 从当前能耗模式删除预制命令。
+func (m *PowerMode) RemoveCommand(db *gorm.DB, command *ClientPreparedCommand) (int64, error) {
+	if command == nil {
+		return 0, gorm.ErrRecordNotFound
+	}
+	tx := db.Where("power_mode_id = ?", m.ID).Delete(command)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
+
+// GetCommand 获取当前能耗模式中指定客户端的预制命令。不存在时返回 nil。
+func (m *PowerMode) GetCommand(db *gorm.DB, clientID string) *ClientPreparedCommand {
+	var command ClientPreparedCommand
+	tx := db.Take(&command, "power_mode_id = ? AND client_id = ?", m.ID, clientID)
+	if tx.Error != nil {
+		return nil
+	}
+	return &command
+}
+
+// GetCommands 获取当前能耗模式的所有预制命令，并附带对应的客户端。
+func (m *PowerMode) GetCommands(db *gorm.DB) ([]ClientPreparedCommand, int64, error) {
+	var commands []ClientPreparedCommand
+	tx := db.Preload("Client").Where("power_mode_id = ?", m.ID).Order("id").Find(&commands)
+	if tx.Error != nil {
+		return nil, 0, tx.Error
+	}
+	return commands, tx.RowsAffected, nil
+}
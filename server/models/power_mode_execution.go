@@ -6,10 +6,20 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	// PowerModeExecutionTriggerManual 表示通过接口手动执行。
+	PowerModeExecutionTriggerManual = "manual"
+	// PowerModeExecutionTriggerSchedule 表示由执行计划自动执行。
+	PowerModeExecutionTriggerSchedule = "schedule"
+)
+
 type PowerModeExecution struct {
 	ID          uint64     `gorm:"column:id;primaryKey"`
 	PowerModeID uint64     `gorm:"column:power_mode_id;not null"`
+	Trigger     string     `gorm:"column:trigger;size:32;not null;default:manual"`
 	CreatedAt   *time.Time `gorm:"column:created_at;autoCreateTime:milli;not null;default:current_timestamp(3)"`
+
+	Clients []PowerModeExecutionClient `gorm:"foreignKey:PowerModeExecutionID"`
 }
 
 func (PowerModeExecution) TableName() string {
@@ -22,6 +32,7 @@ func NewPowerModeExecution(db *gorm.DB, mode *PowerMode) *PowerModeExecution {
 	}
 	return &PowerModeExecution{
 		PowerModeID: mode.ID,
+		Trigger:     PowerModeExecutionTriggerManual,
 	}
 }
 
@@ -69,3 +80,35 @@ func GetPowerModeExecutions(db *gorm.DB, page, pageSize int) ([]PowerModeExecuti
 
 	return records, total, nil
 }
+
+// GetExecutions 获取当前能耗模式的执行记录列表，按时间倒序排列，并附带每个客户端的执行结果。
+func (m *PowerMode) GetExecutions(db *gorm.DB, page, pageSize int) ([]PowerModeExecution, int64, error) {
+	tx := db.Model(&PowerModeExecution{}).Where("power_mode_id = ?", m.ID)
+
+	var total int64
+	err := tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 0
+	}
+	if pageSize > 0 {
+		// 分页
+		offset := (page - 1) * pageSize
+		tx = tx.Limit(pageSize).Offset(offset)
+	}
+
+	// 查询结果
+	var records []PowerModeExecution
+	err = tx.Preload("Clients").Preload("Clients.ClientCommandExecution").Order("created_at desc").Find(&records).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return records, total, nil
+}
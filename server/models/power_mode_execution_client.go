@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// PowerModeExecutionClientStatusDelivered 表示命令已送达客户端。
+	PowerModeExecutionClientStatusDelivered = iota
+	// PowerModeExecutionClientStatusSkippedOffline 表示客户端未连接，跳过。
+	PowerModeExecutionClientStatusSkippedOffline
+	// PowerModeExecutionClientStatusRejected 表示命令无法发送，例如命令内容不合法。
+	PowerModeExecutionClientStatusRejected
+	// PowerModeExecutionClientStatusTimedOut 表示客户端未在规定时间内接收命令。
+	PowerModeExecutionClientStatusTimedOut
+)
+
+var PowerModeExecutionClientStatusNames = map[int8]string{
+	PowerModeExecutionClientStatusDelivered:      "delivered",
+	PowerModeExecutionClientStatusSkippedOffline: "skipped-offline",
+	PowerModeExecutionClientStatusRejected:       "rejected",
+	PowerModeExecutionClientStatusTimedOut:       "timed-out",
+}
+
+// PowerModeExecutionClient 表示某次能耗模式执行中，某个客户端的执行结果。
+// 仅当命令送达时，ClientCommandExecutionID 才不为空。
+type PowerModeExecutionClient struct {
+	ID                       uint64     `gorm:"column:id;primaryKey"`
+	PowerModeExecutionID     uint64     `gorm:"column:power_mode_execution_id;not null"`
+	ClientID                 string     `gorm:"column:client_id;size:255;not null"`
+	ClientCommandExecutionID *uint64    `gorm:"column:client_command_execution_id"`
+	Status                   int8       `gorm:"column:status;not null"`
+	CreatedAt                *time.Time `gorm:"column:created_at;autoCreateTime:milli;not null;default:current_timestamp(3)"`
+
+	ClientCommandExecution *ClientCommandExecution `gorm:"foreignKey:ClientCommandExecutionID"`
+}
+
+func (PowerModeExecutionClient) TableName() string {
+	return "power_mode_execution_client"
+}
+
+// CreateNewPowerModeExecutionClient 记录某个客户端的执行结果。
+func CreateNewPowerModeExecutionClient(db *gorm.DB, execution *PowerModeExecution, clientID string, commandExecution *ClientCommandExecution, status int8) (int64, error) {
+	if execution == nil {
+		return 0, gorm.ErrRecordNotFound
+	}
+	record := &PowerModeExecutionClient{
+		PowerModeExecutionID: execution.ID,
+		ClientID:             clientID,
+		Status:               status,
+	}
+	if commandExecution != nil {
+		record.ClientCommandExecutionID = &commandExecution.ID
+	}
+	tx := db.Save(record)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
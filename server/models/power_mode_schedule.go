@@ -0,0 +1,118 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PowerModeSchedule 表示能耗模式的定时执行计划。
+// CronExpression 为五段式 cron 表达式，TimeZone 为 IANA 时区名称，例如 Asia/Shanghai。
+type PowerModeSchedule struct {
+	ID             uint64     `gorm:"column:id;primaryKey"`
+	PowerModeID    uint64     `gorm:"column:power_mode_id;not null"`
+	CronExpression string     `gorm:"column:cron_expression;size:255;not null"`
+	TimeZone       string     `gorm:"column:time_zone;size:64;not null"`
+	Enabled        bool       `gorm:"column:enabled;not null"`
+	LastFiredAt    *time.Time `gorm:"column:last_fired_at"`
+	CreatedAt      *time.Time `gorm:"column:created_at;autoCreateTime:milli;not null;default:current_timestamp(3)"`
+	UpdatedAt      *time.Time `gorm:"column:updated_at;autoUpdateTime:milli;not null;default:current_timestamp(3);onUpdate:default:current_timestamp(3)"`
+
+	PowerMode *PowerMode `gorm:"foreignKey:PowerModeID" json:"-"`
+}
+
+func (PowerModeSchedule) TableName() string {
+	return "power_mode_schedule"
+}
+
+func NewPowerModeSchedule(mode *PowerMode, cronExpression string, timeZone string, enabled bool) *PowerModeSchedule {
+	if mode == nil {
+		return nil
+	}
+	return &PowerModeSchedule{
+		PowerModeID:    mode.ID,
+		CronExpression: cronExpression,
+		TimeZone:       timeZone,
+		Enabled:        enabled,
+	}
+}
+
+// CreateNewPowerModeSchedule 保存新的执行计划。
+func CreateNewPowerModeSchedule(db *gorm.DB, schedule *PowerModeSchedule) (int64, error) {
+	if schedule == nil {
+		return 0, gorm.ErrRecordNotFound
+	}
+	tx := db.Save(schedule)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
+
+func GetPowerModeSchedule(db *gorm.DB, id uint64) *PowerModeSchedule {
+	var schedule PowerModeSchedule
+	tx := db.Take(&schedule, id)
+	if tx.Error != nil {
+		return nil
+	}
+	return &schedule
+}
+
+// GetEnabledPowerModeSchedules 获取所有启用的执行计划，并附带对应的能耗模式。
+func GetEnabledPowerModeSchedules(db *gorm.DB) ([]PowerModeSchedule, error) {
+	var schedules []PowerModeSchedule
+	tx := db.Preload("PowerMode").Where("enabled = ?", true).Order("id").Find(&schedules)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return schedules, nil
+}
+
+// GetSchedules 获取当前能耗模式的所有执行计划。
+func (m *PowerMode) GetSchedules(db *gorm.DB) ([]PowerModeSchedule, int64, error) {
+	var schedules []PowerModeSchedule
+	tx := db.Where("power_mode_id = ?", m.ID).Order("id").Find(&schedules)
+	if tx.Error != nil {
+		return nil, 0, tx.Error
+	}
+	return schedules, tx.RowsAffected, nil
+}
+
+// Update 更新 cron 表达式、时区及是否启用。
+func (s *PowerModeSchedule) Update(db *gorm.DB, cronExpression string, timeZone string, enabled bool) (int64, error) {
+	s.CronExpression = cronExpression
+	s.TimeZone = timeZone
+	s.Enabled = enabled
+	tx := db.Model(s).Select("cron_expression", "time_zone", "enabled").Updates(s)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
+
+// ErrPowerModeScheduleFired 表示执行计划已在该时刻或之后触发过。
+var ErrPowerModeScheduleFired = errors.New("power mode schedule has been fired")
+
+// UpdateLastFiredAt 更新上次触发时刻。仅当上次触发时刻早于 firedAt 时更新，否则返回 ErrPowerModeScheduleFired，
+// 因此同一时刻的触发只有一次能够更新成功。
+func (s *PowerModeSchedule) UpdateLastFiredAt(db *gorm.DB, firedAt time.Time) (int64, error) {
+	tx := db.Model(s).Where("last_fired_at is null or last_fired_at < ?", firedAt).Update("last_fired_at", firedAt)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return 0, ErrPowerModeScheduleFired
+	}
+	s.LastFiredAt = &firedAt
+	return tx.RowsAffected, nil
+}
+
+// Delete 删除自身。
+func (s *PowerModeSchedule) Delete(db *gorm.DB) (int64, error) {
+	tx := db.Delete(s)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
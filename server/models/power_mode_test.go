@@ -2,6 +2,7 @@ package models
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -74,6 +75,43 @@ func TestGetPowerModeByName(t *testing.T) {
 	assert.Nil(t, modeDeleted)
 }
 
+// TestPowerMode_Delete 测试删除能耗模式。已执行过的能耗模式不能删除。
+func TestPowerMode_Delete(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	result, err := CreateNewPowerMode(db, "test-power-mode-delete")
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+
+	mode := GetPowerModeByName(db, "test-power-mode-delete")
+	assert.NotNil(t, mode)
+
+	count, err := mode.CountExecutions(db)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+
+	result, err = mode.Delete(db)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+	assert.Nil(t, GetPowerModeByName(db, "test-power-mode-delete"))
+
+	// 执行过的能耗模式不能删除。
+	result, err = CreateNewPowerMode(db, "test-power-mode-delete-executed")
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+
+	mode = GetPowerModeByName(db, "test-power-mode-delete-executed")
+	assert.NotNil(t, mode)
+	result, err = CreateNewPowerModeExecution(db, mode)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+
+	result, err = mode.Delete(db)
+	assert.Equal(t, int64(0), result)
+	assert.ErrorIs(t, err, ErrPowerModeExecuted)
+}
+
 // TestPowerModeAndClientPreparedCommand 测试能耗模式，及添加、删除预制命令。
 func TestPowerModeAndClientPreparedCommand(t *testing.T) {
 	setUpAll(t)
@@ -93,31 +131,132 @@ func TestPowerModeAndClientPreparedCommand(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, int64(1), result)
 
-	// 注册后添加预制命令。
-	prepared := NewClientPreparedCommand(client, mode, 1, "data")
-	result, err = CreateNewClientPreparedCommand(db, prepared)
-	assert.Equal(t, int64(1), result)
-	assert.Nil(t, err)
-
-	//// 加入前，获取命令数，应当为0。
-	//commands, total, err := mode.GetCommands(db)
-	//assert.Nil(t, err)
-	//assert.Equal(t, int64(0), total)
-	//assert.Len(t, commands, 0)
-	//
-	//// 准备好预制命令后，加入到能耗模式中。
-	//result, err = mode.AddCommand(db, prepared)
-	//assert.Equal(t, int64(1), result)
-	//assert.Nil(t, err)
-	//
-	//// 再次加入会报错
-	//result, err = mode.AddCommand(db, prepared)
-	//assert.Equal(t, int64(0), result)
-	//assert.NotNil(t, err)
-	//log.Print(err) // 报 duplicate entry
-	//
-	//// 获取命令
-	//_, total, err = mode.GetCommands(db)
-	//assert.Nil(t, err)
-	//assert.Equal(t, int64(1), total)
+	// 加入前，获取命令数，应当为0。
+	commands, total, err := mode.GetCommands(db)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), total)
+	assert.Len(t, commands, 0)
+
+	// 准备好预制命令后，加入到能耗模式中。
+	prepared := NewClientPreparedCommand(client, mode, 2, "{\"power\":0}")
+	result, err = mode.AddCommand(db, prepared)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+
+	// 同一客户端再次加入会报错
+	result, err = mode.AddCommand(db, NewClientPreparedCommand(client, mode, 2, "{\"power\":1}"))
+	assert.Equal(t, int64(0), result)
+	assert.ErrorIs(t, err, ErrPowerModeCommandDuplicated)
+
+	// 获取命令
+	commands, total, err = mode.GetCommands(db)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, client.Name, commands[0].Client.Name)
+
+	// 删除命令
+	result, err = mode.RemoveCommand(db, mode.GetCommand(db, client.ID))
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+	assert.Nil(t, mode.GetCommand(db, client.ID))
+}
+
+// fakeDispatcher 仅向 online 中的客户端发送命令，并记录命令执行历史。
+type fakeDispatcher struct {
+	online map[string]bool
+	sent   []string
+}
+
+func (d *fakeDispatcher) DispatchCommand(clientID string, code int, data string, reason string) (*ClientCommandExecution, error) {
+	if !d.online[clientID] {
+		return nil, ErrClientOffline
+	}
+	d.sent = append(d.sent, clientID)
+	execution := NewClientCommandExecution(clientID, code, data, time.Now(), reason)
+	if err := db.Create(execution).Error; err != nil {
+		return nil, err
+	}
+	return execution, nil
+}
+
+// TestPowerMode_Execute 测试执行能耗模式。离线客户端被跳过。
+func TestPowerMode_Execute(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	result, err := CreateNewPowerMode(db, "test-power-mode-execute")
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+	mode := GetPowerModeByName(db, "test-power-mode-execute")
+	assert.NotNil(t, mode)
+
+	online := NewClient("id-client-execute-online", "online", 1)
+	offline := NewClient("id-client-execute-offline", "offline", 1)
+	for _, c := range []*Client{online, offline} {
+		_, err = RegisterNewClient(db, c)
+		assert.Nil(t, err)
+		_, err = CreateNewClientPreparedCommand(db, NewClientPreparedCommand(c, mode, 2, "{\"power\":0}"))
+		assert.Nil(t, err)
+	}
+
+	dispatcher := &fakeDispatcher{online: map[string]bool{online.ID: true}}
+	delivered, skipped, err := mode.Execute(db, dispatcher, PowerModeExecutionTriggerManual)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), delivered)
+	assert.Equal(t, int64(1), skipped)
+	assert.Equal(t, []string{online.ID}, dispatcher.sent)
+
+	executions, count, err := mode.GetExecutions(db, 1, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+	assert.Len(t, executions[0].Clients, 2)
+	for _, c := range executions[0].Clients {
+		if c.ClientID == online.ID {
+			assert.Equal(t, int8(PowerModeExecutionClientStatusDelivered), c.Status)
+			assert.NotNil(t, c.ClientCommandExecution)
+		} else {
+			assert.Equal(t, int8(PowerModeExecutionClientStatusSkippedOffline), c.Status)
+			assert.Nil(t, c.ClientCommandExecution)
+		}
+	}
+}
+
+// TestPowerModeSchedule_UpdateLastFiredAt 测试同一时刻的触发只能更新一次，不能回退到更早的时刻。
+func TestPowerModeSchedule_UpdateLastFiredAt(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	_, err := CreateNewPowerMode(db, "test-power-mode-schedule-fired")
+	assert.Nil(t, err)
+	mode := GetPowerModeByName(db, "test-power-mode-schedule-fired")
+	assert.NotNil(t, mode)
+	schedule := NewPowerModeSchedule(mode, "* * * * *", "UTC", true)
+	result, err := CreateNewPowerModeSchedule(db, schedule)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+
+	at := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		firedAt time.Time
+		err     error
+	}{
+		{at, nil},
+		{at, ErrPowerModeScheduleFired},
+		{at.Add(-time.Minute), ErrPowerModeScheduleFired},
+		{at.Add(time.Minute), nil},
+	}
+	for _, tt := range tests {
+		// 每次以重新读取的记录更新，模拟同时运行的多个触发。
+		s := GetPowerModeSchedule(db, schedule.ID)
+		_, err := s.UpdateLastFiredAt(db, tt.firedAt)
+		assert.ErrorIs(t, err, tt.err)
+	}
+	s := GetPowerModeSchedule(db, schedule.ID)
+	assert.True(t, s.LastFiredAt.Equal(at.Add(time.Minute)))
+
+	result, err = schedule.Delete(db)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+	_, err = RemovePowerMode(db, mode)
+	assert.Nil(t, err)
 }
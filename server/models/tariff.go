@@ -0,0 +1,239 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Tariff 表示电价方案。电价的具体内容保存在 TariffVersion 中，每次修改都新增一个版本，历史版本不可修改，
+// 以便按当时的电价重新计算过去的费用。
+type Tariff struct {
+	ID        uint64     `gorm:"column:id;primaryKey"`
+	Name      string     `gorm:"column:name;size:255;not null"`
+	Currency  string     `gorm:"column:currency;size:8;not null"` // 货币代码，例如 CNY。
+	IsDefault bool       `gorm:"column:is_default;not null"`      // 是否为默认电价。未指定电价时使用默认电价。
+	CreatedAt *time.Time `gorm:"column:created_at;autoCreateTime:milli;not null;default:current_timestamp(3)"`
+	UpdatedAt *time.Time `gorm:"column:updated_at;autoUpdateTime:milli;not null;default:current_timestamp(3);onUpdate:default:current_timestamp(3)"`
+
+	Versions []TariffVersion `gorm:"foreignKey:TariffID" json:"-"`
+}
+
+func (Tariff) TableName() string {
+	return "tariff"
+}
+
+// TariffVersion 表示电价方案的一个版本，自 EffectiveFrom 起生效，直到下一个版本生效为止。
+// Definition 为 JSON 格式的季节与时段定义，由 analytics.ParseTariffDefinition 解析。
+type TariffVersion struct {
+	ID            uint64     `gorm:"column:id;primaryKey"`
+	TariffID      uint64     `gorm:"column:tariff_id;not null"`
+	EffectiveFrom time.Time  `gorm:"column:effective_from;not null"`
+	TimeZone      string     `gorm:"column:time_zone;size:64;not null"` // 时段按该时区的本地时间划分。
+	Definition    string     `gorm:"column:definition;type:text;not null"`
+	CreatedAt     *time.Time `gorm:"column:created_at;autoCreateTime:milli;not null;default:current_timestamp(3)"`
+}
+
+func (TariffVersion) TableName() string {
+	return "tariff_version"
+}
+
+var (
+	// ErrTariffDefault 表示默认电价不能删除。
+	ErrTariffDefault = errors.New("default tariff cannot be deleted")
+	// ErrTariffVersioned 表示已有版本的电价方案不能删除。版本用于计算过去的费用，不能删除。
+	ErrTariffVersioned = errors.New("tariff with versions cannot be deleted")
+	// ErrTariffVersionBackdated 表示新版本的生效时间早于当前时间。已有版本的电价方案不能追溯修改，以免改变已出的账单。
+	ErrTariffVersionBackdated = errors.New("tariff version cannot take effect in the past")
+	// ErrTariffCurrencyVersioned 表示已有版本的电价方案不能修改货币。货币用于所有版本，修改后过去的账单将改变。
+	ErrTariffCurrencyVersioned = errors.New("currency of tariff with versions cannot be changed")
+)
+
+func NewTariff(name string, currency string) *Tariff {
+	return &Tariff{
+		Name:     name,
+		Currency: currency,
+	}
+}
+
+// CreateNewTariff 保存电价方案。
+func CreateNewTariff(db *gorm.DB, tariff *Tariff) (int64, error) {
+	if tariff == nil {
+		return 0, gorm.ErrRecordNotFound
+	}
+	tx := db.Save(tariff)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
+
+func GetTariff(db *gorm.DB, id uint64) *Tariff {
+	var tariff Tariff
+	tx := db.Take(&tariff, id)
+	if tx.Error != nil {
+		return nil
+	}
+	return &tariff
+}
+
+func GetTariffByName(db *gorm.DB, name string) *Tariff {
+	var tariff Tariff
+	tx := db.Where("name = ?", name).Take(&tariff)
+	if tx.Error != nil {
+		return nil
+	}
+	return &tariff
+}
+
+// GetDefaultTariff 获取默认电价。没有默认电价时返回 nil。
+func GetDefaultTariff(db *gorm.DB) *Tariff {
+	var tariff Tariff
+	tx := db.Where("is_default = ?", true).Take(&tariff)
+	if tx.Error != nil {
+		return nil
+	}
+	return &tariff
+}
+
+func GetTariffs(db *gorm.DB, page, pageSize int) ([]Tariff, int64, error) {
+	tx := db.Model(&Tariff{})
+
+	var total int64
+	err := tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize > 0 {
+		// 分页
+		offset := (page - 1) * pageSize
+		tx = tx.Limit(pageSize).Offset(offset)
+	}
+
+	var records []Tariff
+	err = tx.Order("id").Find(&records).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return records, total, nil
+}
+
+// Update 更新电价方案的名称和货币。已有版本的电价方案不能修改货币，返回 ErrTariffCurrencyVersioned。
+func (t *Tariff) Update(db *gorm.DB, name string, currency string) (int64, error) {
+	var affected int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 锁定电价方案，以免与添加版本并发。
+		var current Tariff
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&current, t.ID).Error; err != nil {
+			return err
+		}
+		if current.Currency != currency {
+			var count int64
+			if err := tx.Model(&TariffVersion{}).Where("tariff_id = ?", t.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrTariffCurrencyVersioned
+			}
+		}
+		result := tx.Model(t).Updates(map[string]any{"name": name, "currency": currency})
+		if result.Error != nil {
+			return result.Error
+		}
+		affected = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	t.Name = name
+	t.Currency = currency
+	return affected, nil
+}
+
+// SetDefault 将当前电价方案设为默认，并取消其他电价方案的默认状态。
+func (t *Tariff) SetDefault(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Tariff{}).Where("id <> ? and is_default = ?", t.ID, true).Update("is_default", false).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(t).Update("is_default", true).Error; err != nil {
+			return err
+		}
+		t.IsDefault = true
+		return nil
+	})
+}
+
+// Delete 删除电价方案。默认电价不能删除，返回 ErrTariffDefault；已有版本的电价方案不能删除，返回 ErrTariffVersioned。
+func (t *Tariff) Delete(db *gorm.DB) (int64, error) {
+	if t.IsDefault {
+		return 0, ErrTariffDefault
+	}
+	var affected int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&TariffVersion{}).Where("tariff_id = ?", t.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrTariffVersioned
+		}
+		result := tx.Delete(t)
+		if result.Error != nil {
+			return result.Error
+		}
+		affected = result.RowsAffected
+		return nil
+	})
+	return affected, err
+}
+
+// GetVersions 获取当前电价方案的所有版本，按生效时间正序排列。
+func (t *Tariff) GetVersions(db *gorm.DB) ([]TariffVersion, error) {
+	var versions []TariffVersion
+	tx := db.Where("tariff_id = ?", t.ID).Order("effective_from").Find(&versions)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return versions, nil
+}
+
+// AddVersion 为当前电价方案添加新版本。
+// 第一个版本可以在任意时间生效，以便为已有的能耗记录计费；之后的版本只能自 now 或之后生效。
+func (t *Tariff) AddVersion(db *gorm.DB, version *TariffVersion, now time.Time) (int64, error) {
+	if version == nil {
+		return 0, gorm.ErrRecordNotFound
+	}
+	var affected int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 锁定电价方案，以免并发添加的版本都被当作第一个版本。
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&Tariff{}, t.ID).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&TariffVersion{}).Where("tariff_id = ?", t.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 && version.EffectiveFrom.Before(now) {
+			return ErrTariffVersionBackdated
+		}
+		version.TariffID = t.ID
+		result := tx.Create(version)
+		if result.Error != nil {
+			return result.Error
+		}
+		affected = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
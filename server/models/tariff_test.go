@@ -0,0 +1,70 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTariff 测试电价方案及其版本。
+func TestTariff(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	tariff := NewTariff("test-tariff", "CNY")
+	result, err := CreateNewTariff(db, tariff)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	// 第一个版本可以追溯生效。
+	_, err = tariff.AddVersion(db, &TariffVersion{EffectiveFrom: now.AddDate(-1, 0, 0), TimeZone: "UTC", Definition: "{}"}, now)
+	assert.Nil(t, err)
+	// 之后的版本不能追溯生效。
+	_, err = tariff.AddVersion(db, &TariffVersion{EffectiveFrom: now.Add(-time.Minute), TimeZone: "UTC", Definition: "{}"}, now)
+	assert.ErrorIs(t, err, ErrTariffVersionBackdated)
+	_, err = tariff.AddVersion(db, &TariffVersion{EffectiveFrom: now, TimeZone: "UTC", Definition: "{}"}, now)
+	assert.Nil(t, err)
+
+	versions, err := tariff.GetVersions(db)
+	assert.Nil(t, err)
+	assert.Len(t, versions, 2)
+
+	// 已有版本的电价方案可以修改名称，不能修改货币。
+	_, err = tariff.Update(db, "test-tariff-renamed", "CNY")
+	assert.Nil(t, err)
+	assert.Equal(t, "test-tariff-renamed", GetTariff(db, tariff.ID).Name)
+	_, err = tariff.Update(db, "test-tariff-renamed", "USD")
+	assert.ErrorIs(t, err, ErrTariffCurrencyVersioned)
+	assert.Equal(t, "CNY", tariff.Currency)
+	assert.Equal(t, "CNY", GetTariff(db, tariff.ID).Currency)
+
+	assert.Nil(t, tariff.SetDefault(db))
+	assert.Equal(t, tariff.ID, GetDefaultTariff(db).ID)
+	_, err = tariff.Delete(db)
+	assert.ErrorIs(t, err, ErrTariffDefault)
+
+	other := NewTariff("test-tariff-other", "CNY")
+	_, err = CreateNewTariff(db, other)
+	assert.Nil(t, err)
+	assert.Nil(t, other.SetDefault(db))
+	assert.Equal(t, other.ID, GetDefaultTariff(db).ID)
+	assert.False(t, GetTariff(db, tariff.ID).IsDefault)
+
+	// 已有版本的电价方案不能删除。
+	tariff.IsDefault = false
+	_, err = tariff.Delete(db)
+	assert.ErrorIs(t, err, ErrTariffVersioned)
+	assert.NotNil(t, GetTariff(db, tariff.ID))
+	versions, err = tariff.GetVersions(db)
+	assert.Nil(t, err)
+	assert.Len(t, versions, 2)
+
+	unused := NewTariff("test-tariff-unused", "CNY")
+	_, err = CreateNewTariff(db, unused)
+	assert.Nil(t, err)
+	result, err = unused.Delete(db)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+}
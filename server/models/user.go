@@ -0,0 +1,218 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	UserRoleNone = iota
+	// UserRoleViewer 只读：查看客户端、能耗及能耗模式。
+	UserRoleViewer
+	// UserRoleOperator 操作员：在只读基础上，可以发送命令、执行及编辑能耗模式。
+	UserRoleOperator
+	// UserRoleAdmin 管理员：在操作员基础上，可以删除客户端、管理用户。
+	UserRoleAdmin
+)
+
+var UserRoleNames = map[int8]string{
+	UserRoleViewer:   "viewer",
+	UserRoleOperator: "operator",
+	UserRoleAdmin:    "admin",
+}
+
+// GetUserRoleByName 根据名称获取角色。名称不存在时返回 UserRoleNone。
+func GetUserRoleByName(name string) int8 {
+	for role, n := range UserRoleNames {
+		if n == name {
+			return role
+		}
+	}
+	return UserRoleNone
+}
+
+// User 表示使用 /user 接口的用户。
+type User struct {
+	ID           uint64     `gorm:"column:id;primaryKey"`
+	Username     string     `gorm:"column:username;size:255;not null"`
+	PasswordHash string     `gorm:"column:password_hash;size:255;not null" json:"-"`
+	Role         int8       `gorm:"column:role;not null"`
+	CreatedAt    *time.Time `gorm:"column:created_at;autoCreateTime:milli;not null;default:current_timestamp(3)"`
+	UpdatedAt    *time.Time `gorm:"column:updated_at;autoUpdateTime:milli;not null;default:current_timestamp(3);onUpdate:default:current_timestamp(3)"`
+}
+
+func (*User) TableName() string {
+	return "user"
+}
+
+// NewUser 实例化一个新的用户。密码以 bcrypt 散列后保存。
+func NewUser(username string, password string, role int8) (*User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	return &User{
+		Username:     username,
+		PasswordHash: string(hash),
+		Role:         role,
+	}, nil
+}
+
+// CheckPassword 检查密码是否正确。
+func (u *User) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
+
+// HasRole 检查当前用户是否具备指定角色的权限。角色权限逐级包含。
+func (u *User) HasRole(role int8) bool {
+	return u.Role >= role
+}
+
+func CreateNewUser(db *gorm.DB, user *User) (int64, error) {
+	if user == nil {
+		return 0, gorm.ErrRecordNotFound
+	}
+	tx := db.Save(user)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
+
+func GetUser(db *gorm.DB, id uint64) *User {
+	var user User
+	tx := db.Take(&user, id)
+	if tx.Error != nil {
+		return nil
+	}
+	return &user
+}
+
+// GetUserByUsername 根据用户名查找用户。
+func GetUserByUsername(db *gorm.DB, username string) *User {
+	var user User
+	tx := db.Take(&user, "username = ?", username)
+	if tx.Error != nil {
+		return nil
+	}
+	return &user
+}
+
+// CountUsers 返回用户总数。
+func CountUsers(db *gorm.DB) (int64, error) {
+	var count int64
+	err := db.Model(&User{}).Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func GetUsers(db *gorm.DB, page, pageSize int) ([]User, int64, error) {
+	tx := db.Model(&User{})
+
+	var total int64
+	err := tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 0
+	}
+	if pageSize > 0 {
+		// 分页
+		offset := (page - 1) * pageSize
+		tx = tx.Limit(pageSize).Offset(offset)
+	}
+
+	// 查询结果
+	var records []User
+	err = tx.Order("id").Find(&records).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return records, total, nil
+}
+
+// UpdatePassword 更新密码，并删除其所有会话。
+func (u *User) UpdatePassword(db *gorm.DB, password string) (int64, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+	u.PasswordHash = string(hash)
+	var rows int64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(u).Update("password_hash", u.PasswordHash)
+		if result.Error != nil {
+			return result.Error
+		}
+		rows = result.RowsAffected
+		// 修改密码后，使用旧密码登录的会话全部失效。
+		return tx.Where("user_id = ?", u.ID).Delete(&UserSession{}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rows, nil
+}
+
+// ErrUserLastAdmin 表示修改角色后将没有管理员。
+var ErrUserLastAdmin = errors.New("last admin")
+
+// UpdateRole 更新角色。自身是最后一个管理员时不能改为其他角色，返回 ErrUserLastAdmin。
+func (u *User) UpdateRole(db *gorm.DB, role int8) (int64, error) {
+	var rows int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if role != UserRoleAdmin {
+			// 锁定所有管理员，以免并发修改后没有管理员。
+			var admins []uint64
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&User{}).Where("role = ?", UserRoleAdmin).Pluck("id", &admins).Error; err != nil {
+				return err
+			}
+			if len(admins) == 1 && admins[0] == u.ID {
+				return ErrUserLastAdmin
+			}
+		}
+		result := tx.Model(u).Update("role", role)
+		if result.Error != nil {
+			return result.Error
+		}
+		rows = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	u.Role = role
+	return rows, nil
+}
+
+// Delete 删除自身及其所有会话。
+func (u *User) Delete(db *gorm.DB) (int64, error) {
+	var rows int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", u.ID).Delete(&UserSession{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(u)
+		if result.Error != nil {
+			return result.Error
+		}
+		rows = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rows, nil
+}
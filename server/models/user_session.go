@@ -0,0 +1,72 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserSession 表示用户登录后的会话。数据库中仅保存令牌的 SHA256 摘要。
+type UserSession struct {
+	ID        uint64     `gorm:"column:id;primaryKey"`
+	TokenHash string     `gorm:"column:token_hash;size:64;not null"`
+	UserID    uint64     `gorm:"column:user_id;not null"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;not null"`
+	CreatedAt *time.Time `gorm:"column:created_at;autoCreateTime:milli;not null;default:current_timestamp(3)"`
+
+	User *User `gorm:"foreignKey:UserID"`
+}
+
+func (UserSession) TableName() string {
+	return "user_session"
+}
+
+// hashToken 计算令牌的 SHA256 摘要。数据库中仅保存摘要，避免令牌泄露。
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateNewUserSession 为用户创建新的会话，并返回令牌。令牌仅在此时可以获得。
+func CreateNewUserSession(db *gorm.DB, user *User, ttl time.Duration) (string, *UserSession, error) {
+	if user == nil {
+		return "", nil, gorm.ErrRecordNotFound
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(b)
+	session := &UserSession{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(ttl),
+		User:      user,
+	}
+	if err := db.Omit("User").Create(session).Error; err != nil {
+		return "", nil, err
+	}
+	return token, session, nil
+}
+
+// GetUserSessionByToken 根据令牌查找未过期的会话，并附带对应的用户。
+func GetUserSessionByToken(db *gorm.DB, token string) *UserSession {
+	var session UserSession
+	tx := db.Preload("User").Take(&session, "token_hash = ? AND expires_at > ?", hashToken(token), time.Now())
+	if tx.Error != nil || session.User == nil {
+		return nil
+	}
+	return &session
+}
+
+// Delete 删除自身，即注销。
+func (s *UserSession) Delete(db *gorm.DB) (int64, error) {
+	tx := db.Delete(s)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNewUser 测试密码散列及角色权限。
+func TestNewUser(t *testing.T) {
+	user, err := NewUser("test-user", "password", UserRoleOperator)
+	assert.Nil(t, err)
+	assert.NotEqual(t, "password", user.PasswordHash)
+	assert.True(t, user.CheckPassword("password"))
+	assert.False(t, user.CheckPassword("password1"))
+
+	assert.True(t, user.HasRole(UserRoleViewer))
+	assert.True(t, user.HasRole(UserRoleOperator))
+	assert.False(t, user.HasRole(UserRoleAdmin))
+
+	assert.Equal(t, int8(UserRoleAdmin), GetUserRoleByName("admin"))
+	assert.Equal(t, int8(UserRoleNone), GetUserRoleByName("root"))
+}
+
+// TestCreateNewUserSession 测试登录会话。
+func TestCreateNewUserSession(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	user, err := NewUser("test-user-session", "password", UserRoleViewer)
+	assert.Nil(t, err)
+	result, err := CreateNewUser(db, user)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+
+	token, _, err := CreateNewUserSession(db, user, time.Hour)
+	assert.Nil(t, err)
+	session := GetUserSessionByToken(db, token)
+	assert.NotNil(t, session)
+	assert.Equal(t, user.ID, session.User.ID)
+	assert.Nil(t, GetUserSessionByToken(db, token+"0"))
+
+	// 过期的会话无效。
+	expired, _, err := CreateNewUserSession(db, user, -time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, GetUserSessionByToken(db, expired))
+
+	// 注销后无效。
+	result, err = session.Delete(db)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+	assert.Nil(t, GetUserSessionByToken(db, token))
+
+	// 修改密码后所有会话无效。
+	token, _, err = CreateNewUserSession(db, user, time.Hour)
+	assert.Nil(t, err)
+	result, err = user.UpdatePassword(db, "password1")
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+	assert.Nil(t, GetUserSessionByToken(db, token))
+	assert.True(t, user.CheckPassword("password1"))
+
+	result, err = user.Delete(db)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+}
+
+// TestUser_UpdateRole 测试最后一个管理员不能改为其他角色。
+func TestUser_UpdateRole(t *testing.T) {
+	setUpAll(t)
+	defer tearDown(t)
+
+	first, err := NewUser("test-user-admin-1", "password", UserRoleAdmin)
+	assert.Nil(t, err)
+	_, err = CreateNewUser(db, first)
+	assert.Nil(t, err)
+	second, err := NewUser("test-user-admin-2", "password", UserRoleAdmin)
+	assert.Nil(t, err)
+	_, err = CreateNewUser(db, second)
+	assert.Nil(t, err)
+
+	result, err := first.UpdateRole(db, UserRoleViewer)
+	assert.Equal(t, int64(1), result)
+	assert.Nil(t, err)
+	assert.Equal(t, int8(UserRoleViewer), first.Role)
+
+	_, err = second.UpdateRole(db, UserRoleOperator)
+	assert.ErrorIs(t, err, ErrUserLastAdmin)
+	assert.Equal(t, int8(UserRoleAdmin), second.Role)
+	assert.Equal(t, int8(UserRoleAdmin), GetUser(db, second.ID).Role)
+
+	// 其他用户成为管理员后可以修改。
+	_, err = first.UpdateRole(db, UserRoleAdmin)
+	assert.Nil(t, err)
+	_, err = second.UpdateRole(db, UserRoleOperator)
+	assert.Nil(t, err)
+
+	_, err = first.Delete(db)
+	assert.Nil(t, err)
+	_, err = second.Delete(db)
+	assert.Nil(t, err)
+}